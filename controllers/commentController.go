@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -8,14 +9,36 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/merinovvvv/momentic-backend/models"
 	"github.com/merinovvvv/momentic-backend/repository"
+	"github.com/merinovvvv/momentic-backend/service"
+	"github.com/merinovvvv/momentic-backend/storage"
 )
 
 type CommentController struct {
-	Repo repository.CommentRepository
+	Repo        repository.CommentRepository
+	Friendships repository.FriendshipRepository
+	Videos      service.VideoService
+	Signer      *storage.URLSigner
 }
 
-func NewCommentController(repo repository.CommentRepository) *CommentController {
-	return &CommentController{Repo: repo}
+func NewCommentController(repo repository.CommentRepository, friendships repository.FriendshipRepository, videos service.VideoService, signer *storage.URLSigner) *CommentController {
+	return &CommentController{Repo: repo, Friendships: friendships, Videos: videos, Signer: signer}
+}
+
+// checkUnlocked отвечает 403/404, если видео закрыто зрителю правилом
+// post-to-see или не существует; false - ответ уже записан
+func (cc *CommentController) checkUnlocked(c *gin.Context, viewerID, videoID int64) bool {
+	err := cc.Videos.CheckUnlocked(c.Request.Context(), viewerID, videoID)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Post today's moment to see comments"})
+	case errors.Is(err, service.ErrVideoNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check video access"})
+	}
+	return false
 }
 
 // GET /videos/:video_id/comments
@@ -26,11 +49,23 @@ func (cc *CommentController) GetCommentsByVideoID(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video_id"})
 		return
 	}
-	comments, err := cc.Repo.GetByVideoID(c.Request.Context(), videoID)
+	viewerID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+	if !cc.checkUnlocked(c, viewerID, videoID) {
+		return
+	}
+	comments, err := cc.Repo.GetByVideoID(c.Request.Context(), videoID, viewerID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
 		return
 	}
+	// Аватары авторов - подписанными ссылками для текущего зрителя
+	for _, comment := range comments {
+		comment.AvatarURL = signedOptionalURL(c.Request.Context(), cc.Signer, viewerID, comment.User.AvatarFilepath)
+	}
 	c.JSON(http.StatusOK, comments)
 }
 
@@ -71,19 +106,11 @@ func (cc *CommentController) GetCommentsByVideoID(c *gin.Context) {
 func (cc *CommentController) PostComment(c *gin.Context) {
 	videoIDStr := c.Param("video_id")
 
-	// 1. Получаем ID пользователя из JWT (обычно кладется в контекст в AuthMiddleware)
-	// Если авторизации пока нет, для теста можно взять константу или передавать в JSON
-	// Default test user ID
-	var uid uint64 = 1
-	if u, exists := c.Get("userID"); exists && u != nil {
-		switch v := u.(type) {
-		case uint64:
-			uid = v
-		case int64:
-			uid = uint64(v)
-		case int:
-			uid = uint64(v)
-		}
+	// 1. Получаем ID пользователя, выставленный middleware.RequireAuth
+	uid, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
 	}
 
 	var input struct {
@@ -101,10 +128,24 @@ func (cc *CommentController) PostComment(c *gin.Context) {
 		return
 	}
 
+	// Заблокированный автором видео (или заблокировавший его) не комментирует
+	blocked, err := cc.Friendships.HasBlockWithVideoAuthor(c.Request.Context(), uid, videoID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save comment"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot comment on this video"})
+		return
+	}
+	if !cc.checkUnlocked(c, uid, videoID) {
+		return
+	}
+
 	// 2. Создаем объект комментария
 	comment := models.Comment{
 		VideoID:   videoID,
-		UserID:    uid,
+		UserID:    uint64(uid),
 		Content:   input.Text,
 		CreatedAt: time.Now(),
 	}
@@ -117,5 +158,6 @@ func (cc *CommentController) PostComment(c *gin.Context) {
 	}
 
 	// 4. Теперь comment содержит и ID, и подтянутый Nickname автора
+	comment.AvatarURL = signedOptionalURL(c.Request.Context(), cc.Signer, uid, comment.User.AvatarFilepath)
 	c.JSON(http.StatusCreated, comment)
 }
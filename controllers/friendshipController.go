@@ -1 +1,292 @@
 package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/service"
+	"github.com/merinovvvv/momentic-backend/storage"
+)
+
+type FriendshipController struct {
+	service service.FriendshipService
+	signer  *storage.URLSigner
+}
+
+func NewFriendshipController(s service.FriendshipService, signer *storage.URLSigner) *FriendshipController {
+	return &FriendshipController{service: s, signer: signer}
+}
+
+// POST /friends/requests/:user_id
+func (fc *FriendshipController) SendRequest(c *gin.Context) {
+	userID, targetID, ok := fc.parsePair(c)
+	if !ok {
+		return
+	}
+
+	status, err := fc.service.SendRequest(c.Request.Context(), userID, targetID)
+	if err != nil {
+		fc.handleError(c, err, "Could not send friend request")
+		return
+	}
+
+	if status == models.StatusFriends {
+		c.JSON(http.StatusOK, gin.H{"message": "Friend request accepted", "status": "friends"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Friend request sent", "status": "pending"})
+}
+
+// POST /friends/requests/:user_id/accept
+func (fc *FriendshipController) AcceptRequest(c *gin.Context) {
+	userID, requesterID, ok := fc.parsePair(c)
+	if !ok {
+		return
+	}
+
+	if err := fc.service.AcceptRequest(c.Request.Context(), userID, requesterID); err != nil {
+		fc.handleError(c, err, "Could not accept friend request")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Friend request accepted"})
+}
+
+// POST /friends/requests/:user_id/decline
+func (fc *FriendshipController) DeclineRequest(c *gin.Context) {
+	userID, requesterID, ok := fc.parsePair(c)
+	if !ok {
+		return
+	}
+
+	if err := fc.service.DeclineRequest(c.Request.Context(), userID, requesterID); err != nil {
+		fc.handleError(c, err, "Could not decline friend request")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Friend request declined"})
+}
+
+// DELETE /friends/requests/:user_id
+func (fc *FriendshipController) CancelRequest(c *gin.Context) {
+	userID, targetID, ok := fc.parsePair(c)
+	if !ok {
+		return
+	}
+
+	if err := fc.service.CancelRequest(c.Request.Context(), userID, targetID); err != nil {
+		fc.handleError(c, err, "Could not cancel friend request")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DELETE /friends/:user_id
+func (fc *FriendshipController) Unfriend(c *gin.Context) {
+	userID, friendID, ok := fc.parsePair(c)
+	if !ok {
+		return
+	}
+
+	if err := fc.service.Unfriend(c.Request.Context(), userID, friendID); err != nil {
+		fc.handleError(c, err, "Could not remove friend")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GET /friends/requests/incoming
+func (fc *FriendshipController) ListIncoming(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	users, err := fc.service.ListIncoming(c.Request.Context(), userID)
+	if err != nil {
+		fc.handleError(c, err, "Could not fetch friend requests")
+		return
+	}
+	c.JSON(http.StatusOK, fc.withAvatarURLs(c, userID, users))
+}
+
+// GET /friends/requests/outgoing
+func (fc *FriendshipController) ListOutgoing(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	users, err := fc.service.ListOutgoing(c.Request.Context(), userID)
+	if err != nil {
+		fc.handleError(c, err, "Could not fetch friend requests")
+		return
+	}
+	c.JSON(http.StatusOK, fc.withAvatarURLs(c, userID, users))
+}
+
+// GET /friends?limit=20&offset=0
+func (fc *FriendshipController) ListFriends(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultFriendsPageSize)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	page, err := fc.service.ListFriends(c.Request.Context(), userID, limit, offset)
+	if err != nil {
+		fc.handleError(c, err, "Could not fetch friends")
+		return
+	}
+	page.Items = fc.withAvatarURLs(c, userID, page.Items)
+	c.JSON(http.StatusOK, page)
+}
+
+// POST /users/:user_id/block
+func (fc *FriendshipController) Block(c *gin.Context) {
+	userID, targetID, ok := fc.parsePair(c)
+	if !ok {
+		return
+	}
+
+	if err := fc.service.Block(c.Request.Context(), userID, targetID); err != nil {
+		fc.handleError(c, err, "Could not block user")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked"})
+}
+
+// DELETE /users/:user_id/block
+func (fc *FriendshipController) Unblock(c *gin.Context) {
+	userID, targetID, ok := fc.parsePair(c)
+	if !ok {
+		return
+	}
+
+	if err := fc.service.Unblock(c.Request.Context(), userID, targetID); err != nil {
+		fc.handleError(c, err, "Could not unblock user")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GET /users/me/blocked
+func (fc *FriendshipController) ListBlocked(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	users, err := fc.service.ListBlocked(c.Request.Context(), userID)
+	if err != nil {
+		fc.handleError(c, err, "Could not fetch blocked users")
+		return
+	}
+	c.JSON(http.StatusOK, fc.withAvatarURLs(c, userID, users))
+}
+
+// GET /users/me/friend-suggestions?limit=20
+func (fc *FriendshipController) ListSuggestions(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultSuggestionsLimit)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	suggestions, err := fc.service.Suggestions(c.Request.Context(), userID, limit)
+	if err != nil {
+		fc.handleError(c, err, "Could not fetch friend suggestions")
+		return
+	}
+	if suggestions == nil {
+		suggestions = []models.FriendSuggestion{}
+	}
+	for i := range suggestions {
+		suggestions[i].AvatarURL = signedOptionalURL(c.Request.Context(), fc.signer, userID, suggestions[i].AvatarFilepath)
+	}
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// POST /users/me/friend-suggestions/:user_id/dismiss
+func (fc *FriendshipController) DismissSuggestion(c *gin.Context) {
+	userID, targetID, ok := fc.parsePair(c)
+	if !ok {
+		return
+	}
+
+	if err := fc.service.DismissSuggestion(c.Request.Context(), userID, targetID); err != nil {
+		fc.handleError(c, err, "Could not dismiss suggestion")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// parsePair возвращает текущего пользователя и пользователя из :user_id.
+// При ошибке ответ уже записан.
+func (fc *FriendshipController) parsePair(c *gin.Context) (int64, int64, bool) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return 0, 0, false
+	}
+	otherID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || otherID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return 0, 0, false
+	}
+	return userID, otherID, true
+}
+
+func (fc *FriendshipController) handleError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrCannotBefriendSelf),
+		errors.Is(err, service.ErrCannotBlockSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUserBlocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrUserNotFound),
+		errors.Is(err, service.ErrFriendRequestNotFound),
+		errors.Is(err, service.ErrNotFriends),
+		errors.Is(err, service.ErrNotBlocked):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAlreadyFriends),
+		errors.Is(err, service.ErrFriendRequestExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("FATAL: Service error in FriendshipController: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// withAvatarURLs выдает зрителю подписанные ссылки на аватары
+func (fc *FriendshipController) withAvatarURLs(c *gin.Context, viewerID int64, users []models.FriendUser) []models.FriendUser {
+	if users == nil {
+		return []models.FriendUser{}
+	}
+	for i := range users {
+		users[i].AvatarURL = signedOptionalURL(c.Request.Context(), fc.signer, viewerID, users[i].AvatarFilepath)
+	}
+	return users
+}
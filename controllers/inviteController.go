@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/util"
+)
+
+const defaultInviteStatsLimit = 50
+
+// POST /invites - выпускает приглашение текущего пользователя
+func CreateInvite(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	var body struct {
+		MaxUses        int `json:"max_uses"`
+		ExpiresInHours int `json:"expires_in_hours"`
+	}
+	// Тело необязательно: без него используются значения по умолчанию
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+			return
+		}
+	}
+
+	maxUses := body.MaxUses
+	if maxUses == 0 {
+		maxUses = util.DefaultInviteMaxUses
+	}
+	if maxUses < 1 || maxUses > util.MaxInviteMaxUses {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_uses must be between 1 and " + strconv.Itoa(util.MaxInviteMaxUses)})
+		return
+	}
+	ttl := util.DefaultInviteTTL
+	if body.ExpiresInHours != 0 {
+		ttl = time.Duration(body.ExpiresInHours) * time.Hour
+	}
+	if ttl <= 0 || ttl > util.MaxInviteTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_hours must be between 1 and " + strconv.Itoa(int(util.MaxInviteTTL/time.Hour))})
+		return
+	}
+
+	invite, code, err := util.CreateInvite(userID, maxUses, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, inviteResponse(invite, code))
+}
+
+// GET /invites - приглашения текущего пользователя
+func ListInvites(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	invites, err := util.ListInvites(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+		return
+	}
+
+	response := make([]gin.H, len(invites))
+	for i, invite := range invites {
+		response[i] = inviteResponse(invite, util.SignInviteCode(invite.InviteID, invite.ExpiresAt))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DELETE /invites/:invite_id - отзывает приглашение
+func RevokeInvite(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	inviteID, err := strconv.ParseInt(c.Param("invite_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite_id"})
+		return
+	}
+
+	if err := util.RevokeInvite(userID, inviteID); err != nil {
+		if errors.Is(err, util.ErrInviteNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GET /admin/invites/stats - кто из пользователей приводит больше всего регистраций
+func GetInviteStats(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultInviteStatsLimit)))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	stats, err := util.InviteStats(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invite stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+func inviteResponse(invite models.Invite, code string) gin.H {
+	active := invite.RevokedAt == nil && invite.ExpiresAt.After(time.Now()) && invite.Uses < invite.MaxUses
+	return gin.H{
+		"invite_id":  invite.InviteID,
+		"code":       code,
+		"max_uses":   invite.MaxUses,
+		"uses":       invite.Uses,
+		"expires_at": invite.ExpiresAt,
+		"revoked_at": invite.RevokedAt,
+		"created_at": invite.CreatedAt,
+		"active":     active,
+	}
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/service"
+	"github.com/merinovvvv/momentic-backend/storage"
+)
+
+type LeaderboardController struct {
+	service service.LeaderboardService
+	signer  *storage.URLSigner
+}
+
+func NewLeaderboardController(s service.LeaderboardService, signer *storage.URLSigner) *LeaderboardController {
+	return &LeaderboardController{service: s, signer: signer}
+}
+
+// GET /leaderboards/:scope?cursor=&limit=
+// scope: global, weekly или friends; next_cursor передается в cursor
+func (lc *LeaderboardController) GetLeaderboard(c *gin.Context) {
+	viewerID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultLeaderboardPageSize)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	scope := models.LeaderboardScope(c.Param("scope"))
+	page, err := lc.service.GetPage(c.Request.Context(), scope, viewerID, c.Query("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidLeaderboardScope) || errors.Is(err, service.ErrInvalidLeaderboardCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("ERROR: Failed to fetch %s leaderboard: %v", scope, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch leaderboard"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	for i := range page.Items {
+		user := &page.Items[i].User
+		user.AvatarURL = signedOptionalURL(ctx, lc.signer, viewerID, user.AvatarFilepath)
+	}
+	if page.Me != nil {
+		page.Me.User.AvatarURL = signedOptionalURL(ctx, lc.signer, viewerID, page.Me.User.AvatarFilepath)
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/storage"
+)
+
+// maxPlaylistSize - плейлисты пишет наш ffmpeg, больше мегабайта не бывает
+const maxPlaylistSize = 1 << 20
+
+// MediaController отдает объекты хранилища по подписанным ссылкам.
+// Подпись проверяется без БД и без middleware.RequireAuth: ссылку выдает
+// API только тому, кто имеет право видеть объект.
+type MediaController struct {
+	Signer *storage.URLSigner
+	Store  storage.BlobStore
+}
+
+func NewMediaController(signer *storage.URLSigner, store storage.BlobStore) *MediaController {
+	return &MediaController{Signer: signer, Store: store}
+}
+
+// GET /media/*key?uid=&exp=&sig=
+func (mc *MediaController) ServeMedia(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	viewerID, expiresAt, err := mc.Signer.Verify(key, c.Request.URL.Query())
+	if err != nil {
+		if errors.Is(err, storage.ErrURLExpired) {
+			c.JSON(http.StatusGone, gin.H{"error": "Media URL expired"})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid media URL"})
+		return
+	}
+
+	// Кешировать можно не дольше, чем живет ссылка
+	maxAge := int(time.Until(expiresAt) / time.Second)
+	if storage.IsPlaylist(key) {
+		mc.servePlaylist(c, key, viewerID, maxAge)
+		return
+	}
+
+	info, err := mc.Store.Stat(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open media"})
+		return
+	}
+	content := storage.NewSeeker(c.Request.Context(), mc.Store, key, info.Size)
+	defer content.Close()
+
+	c.Header("Content-Type", storage.ContentTypeByKey(key))
+	c.Header("ETag", storage.ETag(key, info))
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime, content)
+}
+
+// servePlaylist отдает HLS-плейлист, подписав ссылки на сегменты и
+// вложенные плейлисты для того же зрителя
+func (mc *MediaController) servePlaylist(c *gin.Context, key string, viewerID int64, maxAge int) {
+	ctx := c.Request.Context()
+	r, err := mc.Store.Open(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open media"})
+		return
+	}
+	playlist, err := io.ReadAll(io.LimitReader(r, maxPlaylistSize))
+	r.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open media"})
+		return
+	}
+	signed, err := mc.Signer.SignPlaylist(ctx, key, viewerID, playlist)
+	if err != nil {
+		log.Printf("ERROR: Failed to sign playlist %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open media"})
+		return
+	}
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+	c.Data(http.StatusOK, storage.ContentTypeByKey(key), signed)
+}
+
+// signedURL выдает зрителю ссылку на объект; при ошибке возвращает ""
+func signedURL(ctx context.Context, signer *storage.URLSigner, viewerID int64, key string) string {
+	url, err := signer.URL(ctx, key, viewerID)
+	if err != nil {
+		log.Printf("ERROR: Failed to sign media url for %s: %v", key, err)
+		return ""
+	}
+	return url
+}
+
+// signedOptionalURL - ссылка на необязательный объект (аватар, постер) или nil, если его нет
+func signedOptionalURL(ctx context.Context, signer *storage.URLSigner, viewerID int64, path *string) *string {
+	if path == nil || *path == "" {
+		return nil
+	}
+	url := signedURL(ctx, signer, viewerID, *path)
+	if url == "" {
+		return nil
+	}
+	return &url
+}
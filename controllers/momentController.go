@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/service"
+)
+
+type MomentController struct {
+	service service.MomentService
+}
+
+func NewMomentController(s service.MomentService) *MomentController {
+	return &MomentController{service: s}
+}
+
+// GET /moments/today
+// Окно момента текущего локального дня пользователя. До window_start
+// клиент не знает точного времени: уведомление приходит по /ws/events и push.
+func (mc *MomentController) GetToday(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	moment, err := mc.service.Today(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load today's moment for user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch today's moment"})
+		return
+	}
+
+	now := time.Now()
+	if now.Before(moment.StartsAt) {
+		// Время момента не раскрывается заранее
+		c.JSON(http.StatusOK, gin.H{
+			"date":     moment.Date(),
+			"timezone": moment.Timezone,
+			"status":   "pending",
+		})
+		return
+	}
+	status := "open"
+	if now.After(moment.Deadline) {
+		status = "closed"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"date":         moment.Date(),
+		"timezone":     moment.Timezone,
+		"status":       status,
+		"window_start": moment.StartsAt,
+		"deadline":     moment.Deadline,
+	})
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/util"
+)
+
+// Одинаковый ответ для существующих и несуществующих email,
+// чтобы по эндпоинту нельзя было проверить наличие аккаунта
+const passwordForgotMessage = "If an account with this email exists, a reset code has been sent"
+
+// POST /auth/password/forgot
+func ForgotPassword(c *gin.Context) {
+	var body struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	email := strings.TrimSpace(body.Email)
+
+	if !util.QueuePasswordReset(email) {
+		log.Printf("WARNING: Password reset queue is full, request dropped")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": passwordForgotMessage})
+}
+
+// POST /auth/password/reset
+func ResetPassword(c *gin.Context) {
+	var body struct {
+		Email    string `json:"email" binding:"required,email"`
+		Code     string `json:"code" binding:"required"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	if err := util.ResetPassword(strings.TrimSpace(body.Email), body.Code, body.Password); err != nil {
+		if errors.Is(err, util.ErrInvalidResetCode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset code"})
+			return
+		}
+		log.Printf("ERROR: Password reset failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/service"
+)
+
+type RatingController struct {
+	service service.RatingService
+}
+
+func NewRatingController(s service.RatingService) *RatingController {
+	return &RatingController{service: s}
+}
+
+// POST /admin/ratings/recompute?user_id=
+// Пересчитывает рейтинг из журнала rating_events: всех пользователей или одного.
+// Таблицы рейтинга подхватят результат при следующем обновлении.
+func (rc *RatingController) Recompute(c *gin.Context) {
+	var userID int64
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+			return
+		}
+		userID = id
+	}
+
+	updated, err := rc.service.Recompute(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("ERROR: Rating recompute failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not recompute ratings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"updated_users": updated})
+}
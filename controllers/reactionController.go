@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/middleware"
 	"github.com/merinovvvv/momentic-backend/models"
 	"github.com/merinovvvv/momentic-backend/service"
 )
@@ -19,9 +20,28 @@ func NewReactionController(s service.ReactionService) *ReactionController {
 	return &ReactionController{service: s}
 }
 
-func assumedGetUserID(c *gin.Context) (int64, error) {
-	//TODO
-	return 1, nil
+var errNoUserInContext = errors.New("no authenticated user in context")
+
+// currentUserID возвращает ID пользователя, установленный middleware.RequireAuth
+func currentUserID(c *gin.Context) (int64, error) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		return 0, errNoUserInContext
+	}
+	return userID, nil
+}
+
+// currentActor возвращает ID и роль пользователя из access-токена
+func currentActor(c *gin.Context) (service.Actor, error) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return service.Actor{}, err
+	}
+	role, ok := middleware.GetRole(c)
+	if !ok {
+		role = models.RoleUser
+	}
+	return service.Actor{UserID: userID, Role: role}, nil
 }
 
 // POST /videos/{video_id}/reaction
@@ -32,7 +52,7 @@ func (rc *ReactionController) HandleReaction(c *gin.Context) {
 		return
 	}
 
-	userID, err := assumedGetUserID(c)
+	userID, err := currentUserID(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
 		return
@@ -50,6 +70,18 @@ func (rc *ReactionController) HandleReaction(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if errors.Is(err, service.ErrUserBlocked) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot react to this video"})
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Post today's moment to react to this video"})
+			return
+		}
+		if errors.Is(err, service.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
 		log.Printf("FATAL: Service error during HandleReaction: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not set reaction"})
 		return
@@ -66,7 +98,7 @@ func (rc *ReactionController) RemoveReaction(c *gin.Context) {
 		return
 	}
 
-	userID, err := assumedGetUserID(c)
+	userID, err := currentUserID(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
 		return
@@ -94,8 +126,22 @@ func (rc *ReactionController) GetVideoReactions(c *gin.Context) {
 		return
 	}
 
-	users, err := rc.service.GetVideoReactions(c.Request.Context(), videoID)
+	viewerID, err := currentUserID(c)
 	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	users, err := rc.service.GetVideoReactions(c.Request.Context(), videoID, viewerID)
+	if err != nil {
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Post today's moment to see reactions"})
+			return
+		}
+		if errors.Is(err, service.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+			return
+		}
 		log.Printf("FATAL: Service error during GetVideoReactions: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch reactions"})
 		return
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/middleware"
+	"github.com/merinovvvv/momentic-backend/util"
+)
+
+// POST /auth/logout - закрывает сессию, которой принадлежит refresh-токен
+func Logout(c *gin.Context) {
+	var body struct {
+		Refresh string `json:"refresh" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	if err := util.RevokeSessionByRefreshToken(body.Refresh); err != nil {
+		if errors.Is(err, util.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// POST /auth/logout-all - закрывает все сессии текущего пользователя
+func LogoutAll(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	if err := util.RevokeAllSessions(uint64(userID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
+}
+
+// GET /auth/sessions - активные сессии (устройства) текущего пользователя
+func GetSessions(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	sessions, err := util.ListSessions(uint64(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	currentSessionID := middleware.GetSessionID(c)
+	response := make([]gin.H, len(sessions))
+	for i, s := range sessions {
+		response[i] = gin.H{
+			"session_id":   s.ID,
+			"user_agent":   s.UserAgent,
+			"ip":           s.IP,
+			"created_at":   s.CreatedAt,
+			"last_used_at": s.LastUsedAt,
+			"current":      s.ID == currentSessionID,
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DELETE /auth/sessions/:session_id - закрывает сессию на одном из устройств
+func DeleteSession(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	if err := util.RevokeSession(uint64(userID), c.Param("session_id")); err != nil {
+		if errors.Is(err, util.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/service"
+)
+
+type StreakController struct {
+	service service.StreakService
+}
+
+func NewStreakController(s service.StreakService) *StreakController {
+	return &StreakController{service: s}
+}
+
+// GET /users/:user_id/streak?days=30
+// Серия и история засчитанных дней; число заморозок видно только владельцу
+func (sc *StreakController) GetStreak(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+	viewerID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(service.DefaultStreakHistoryDays)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+		return
+	}
+
+	info, err := sc.service.Get(c.Request.Context(), userID, days)
+	if err != nil {
+		sc.writeError(c, err)
+		return
+	}
+	if viewerID != userID {
+		info.StreakFreezes = 0
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// POST /users/me/streak/freeze
+// Тратит заморозку на сегодня, если пользователь не успеет опубликовать момент
+func (sc *StreakController) Freeze(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	info, err := sc.service.Freeze(c.Request.Context(), userID)
+	if err != nil {
+		sc.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+func (sc *StreakController) writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNoStreakFreezes),
+		errors.Is(err, service.ErrStreakDayCovered),
+		errors.Is(err, service.ErrStreakNotActive):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("ERROR: Streak request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not process streak"})
+	}
+}
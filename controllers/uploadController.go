@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/service"
+)
+
+// Resumable-загрузка видео по протоколу tus 1.0.0 (core + creation,
+// expiration, termination): https://tus.io/protocols/resumable-upload
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// UploadController содержит зависимость от сервиса загрузок
+type UploadController struct {
+	service service.UploadService
+}
+
+func NewUploadController(s service.UploadService) *UploadController {
+	return &UploadController{service: s}
+}
+
+// --- Options (OPTIONS /uploads) --- возможности сервера
+func (uc *UploadController) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(MaxFileSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// --- Create (POST /uploads) ---
+// Upload-Length - размер файла, Upload-Metadata - "description <b64>"
+func (uc *UploadController) Create(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	authorID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Требуется заголовок Upload-Length"})
+		return
+	}
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат Upload-Metadata"})
+		return
+	}
+	upload, err := uc.service.Create(c.Request.Context(), authorID, length, metadata["description"])
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidUploadLength):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Размер файла превышает лимит"})
+		case errors.Is(err, service.ErrDescriptionTooLong):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrDailyMomentLimit):
+			c.JSON(http.StatusConflict, videoErrorBody(err))
+		case errors.Is(err, service.ErrTooManyUploads):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Слишком много незавершенных загрузок"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать загрузку"})
+		}
+		return
+	}
+
+	setUploadHeaders(c, upload)
+	c.Header("Location", "/uploads/"+upload.UploadID)
+	c.Status(http.StatusCreated)
+}
+
+// --- Head (HEAD /uploads/:upload_id) --- сколько байт уже принято
+func (uc *UploadController) Head(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	authorID, err := currentUserID(c)
+	if err != nil {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	upload, err := uc.service.Get(c.Request.Context(), authorID, c.Param("upload_id"))
+	if err != nil {
+		c.Status(uploadErrorStatus(err))
+		return
+	}
+
+	setUploadHeaders(c, upload)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// --- Patch (PATCH /uploads/:upload_id) --- очередная часть файла
+func (uc *UploadController) Patch(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	authorID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+	if c.ContentType() != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type должен быть " + tusContentType})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Требуется заголовок Upload-Offset"})
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, MaxFileSize)
+	upload, video, err := uc.service.Append(c.Request.Context(), authorID, c.Param("upload_id"), offset, body)
+	if upload != nil {
+		setUploadHeaders(c, upload)
+	}
+	if err != nil {
+		status := uploadErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			log.Printf("ERROR: Upload PATCH failed: %v", err)
+		}
+		c.JSON(status, videoErrorBody(err))
+		return
+	}
+
+	if video != nil {
+		c.Header("Video-Id", strconv.FormatInt(video.VideoID, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// --- Terminate (DELETE /uploads/:upload_id) ---
+func (uc *UploadController) Terminate(c *gin.Context) {
+	if !checkTusResumable(c) {
+		return
+	}
+	authorID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+
+	if err := uc.service.Terminate(c.Request.Context(), authorID, c.Param("upload_id")); err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Tus-Resumable", tusVersion)
+	c.Status(http.StatusNoContent)
+}
+
+func checkTusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func setUploadHeaders(c *gin.Context, upload *models.Upload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if upload.VideoID != nil {
+		c.Header("Video-Id", strconv.FormatInt(*upload.VideoID, 10))
+	} else {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func uploadErrorStatus(err error) int {
+	if status, ok := videoValidationStatus(err); ok {
+		return status
+	}
+	switch {
+	case errors.Is(err, service.ErrUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrUploadExpired):
+		return http.StatusGone
+	case errors.Is(err, service.ErrUploadOffsetMismatch):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrUploadLocked):
+		return http.StatusLocked
+	case errors.Is(err, service.ErrUploadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// parseTusMetadata разбирает Upload-Metadata: пары "ключ base64(значение)" через запятую.
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
@@ -1,21 +1,22 @@
 package controllers
 import (
-	"context"
+	"bytes"
 	"errors"
 	"fmt"
-	"math/rand"
+	"log"
 	"net/http"
-	"os"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/merinovvvv/momentic-backend/initializers"
+	"github.com/merinovvvv/momentic-backend/middleware"
 	"github.com/merinovvvv/momentic-backend/models"
 	"github.com/merinovvvv/momentic-backend/util"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	"github.com/merinovvvv/momentic-backend/repository"
+	"github.com/merinovvvv/momentic-backend/storage"
 )
 
 func SignUp(c *gin.Context) { // POST auth/register
@@ -23,6 +24,7 @@ func SignUp(c *gin.Context) { // POST auth/register
 	var body struct {
 		Email    string `json:"email" binding:"required,email"`
 		Password string `json:"password" binding:"required,min=8"`
+		InviteCode string `json:"invite_code"`
 	}
 	
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -38,14 +40,40 @@ func SignUp(c *gin.Context) { // POST auth/register
 		return
 	}
 
-	user := models.User{Password: string(hash), Email: body.Email}
-	if err := initializers.DB.Create(&user).Error; err != nil {
+	user := models.User{Password: string(hash), Email: body.Email, Role: models.RoleUser}
+	if adminEmail := initializers.BootstrapAdminEmail(); adminEmail != "" && strings.EqualFold(adminEmail, body.Email) {
+		user.Role = models.RoleAdmin
+	}
+	// Пользователь и использование приглашения сохраняются вместе
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if body.InviteCode == "" {
+			return nil
+		}
+		return util.RedeemInvite(tx, body.InviteCode, int64(user.ID))
+	})
+	if err != nil {
+		if errors.Is(err, util.ErrInvalidInvite) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid or expired invite code",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create user",
 		})
+		return
 	}
 
-	verificationCode := fmt.Sprintf("%05d", rand.Intn(99999))
+	verificationCode, err := util.GenerateVerificationCode(util.VerificationCodeLength)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate verification code",
+		})
+		return
+	}
 	if err := util.SendVerificationEmail(user.Email, verificationCode); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to send verification email",
@@ -94,38 +122,15 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	accessClaims := jwt.RegisteredClaims{
-		Subject: fmt.Sprint(user.ID),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
-		IssuedAt: jwt.NewNumericDate(time.Now()),
-	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessString, err := accessToken.SignedString([]byte(os.Getenv("SECRET")))
+	pair, err := util.IssueTokens(user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":"Failed to create access token",
+			"error":"Failed to create tokens",
 		})
 		return
 	}
 
-	refreshClaims := jwt.RegisteredClaims{
-		Subject: fmt.Sprint(user.ID),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 30)),
-		IssuedAt: jwt.NewNumericDate(time.Now()),
-	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshString, err := refreshToken.SignedString([]byte(os.Getenv("REFRESH_SECRET")))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":"Failed to create refresh token",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"access": accessString,
-		"refresh": refreshString,
-	})
+	c.JSON(http.StatusOK, pair)
 }
 
 func Refresh(c *gin.Context) {
@@ -137,87 +142,79 @@ func Refresh(c *gin.Context) {
 		return
 	}
 
-	token, err := jwt.Parse(body.Refresh, func(token *jwt.Token) (any, error) {
-		return []byte(os.Getenv("SECRET")), nil
-	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
-	if err != nil || !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "invalid refresh token",
-		})
-		return
-	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || float64(time.Now().Unix()) > claims["exp"].(float64) {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "invalid refresh token",
-		})
-		return
-	}
-	
-	var user models.User
-	if err := initializers.DB.First(&user, claims["sub"]).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
-		return
-	}
-
-	accessClaims := jwt.RegisteredClaims{
-		Subject: fmt.Sprint(user.ID),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
-		IssuedAt: jwt.NewNumericDate(time.Now()),
-	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessString, err := accessToken.SignedString([]byte(os.Getenv("SECRET")))
+	pair, err := util.RotateRefreshToken(body.Refresh)
 	if err != nil {
+		if errors.Is(err, util.ErrInvalidRefreshToken) || errors.Is(err, util.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid refresh token",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":"Failed to create access token",
+			"error":"Failed to refresh tokens",
 		})
 		return
 	}
 
-	refreshClaims := jwt.RegisteredClaims{
-		Subject: fmt.Sprint(user.ID),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 30)),
-		IssuedAt: jwt.NewNumericDate(time.Now()),
-	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshString, err := refreshToken.SignedString([]byte(os.Getenv("REFRESH_SECRET")))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":"Failed to create refresh token",
-		})
-		return
-	}
-	
-	c.JSON(http.StatusOK, gin.H{
-		"access": accessString,
-		"refresh": refreshString,
-	})
+	c.JSON(http.StatusOK, pair)
 }
 
 func Validate(c *gin.Context) {
-	user, exists := c.Get("user")
+	user, exists := middleware.GetUser(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": "no user in context",
 		})
+		return
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"message": user,
 	})
 }
 
+// PATCH auth/register - заполнение профиля после регистрации.
+// Пароль используется как подтверждение, что профиль меняет владелец аккаунта.
 func ChangeUserInfo(c *gin.Context) {
 	var body struct {
 		Password string `json:"password" binding:"required,min=8"`
 		Name string `json:"name"`
 		Surname string `json:"surname"`
-		
+		Timezone string `json:"timezone"` // IANA, например Europe/Minsk
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
 		return
 	}
-	
+
+	user, exists := middleware.GetUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "no user in context"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid password"})
+		return
+	}
+
+	if body.Name != "" {
+		user.Name = body.Name
+	}
+	if body.Surname != "" {
+		user.Surname = body.Surname
+	}
+	if body.Timezone != "" {
+		if _, err := models.LoadTimezone(body.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.Timezone = body.Timezone
+	}
+	if err := initializers.DB.Model(&user).Select("name", "surname", "timezone").Updates(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user info"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": user})
 }
 
 func VerifyEmail(c *gin.Context) {
@@ -230,39 +227,50 @@ func VerifyEmail(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "details": err.Error()})
 		return
 	}
-	var emailVerification models.EmailVerification
-	err := initializers.DB.First(&emailVerification, "email = ?", body.Email).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	if err := util.CheckVerificationCode(body.Email, body.Code); err != nil {
+		switch {
+		case errors.Is(err, util.ErrVerificationNotFound):
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error":"No such user or email is already verified",
 			})
-		} else {
+		case errors.Is(err, util.ErrVerificationExpired):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":"Verification code expired",
+			})
+		case errors.Is(err, util.ErrTooManyAttempts):
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":"Too many attempts, try again later",
+			})
+		case errors.Is(err, util.ErrInvalidVerificationCode):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":"Invalid verification code",
+			})
+		default:
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":"Error while oppening db",
 			})
 		}
 		return
 	}
-	if emailVerification.ExpiresAt.Before(time.Now()) {
+
+	var user models.User
+	if err := initializers.DB.First(&user, "email = ?", body.Email).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":"Verification code expired",
+			"error":"No such user",
 		})
 		return
 	}
-	
-	//TODO: make it transaction
-	if err := initializers.DB.Delete(&emailVerification).Error; err != nil {
+	if err := initializers.DB.Model(&user).Update("verified", true).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":"Failed to delete verification code",
+			"error":"Failed to verify email",
 		})
 		return
 	}
-	if err := initializers.DB.Model(&models.User{}).Where("email = ?", body.Email).Update("verified", true).Error; err != nil { 
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":"No such user",
-		})
-		return
+
+	// Аккаунт подтвержден - дружба с пригласившим. Ошибка здесь не должна
+	// ломать подтверждение: повторный вызов безопасен.
+	if err := util.BefriendInviter(int64(user.ID)); err != nil {
+		log.Printf("ERROR: Failed to befriend inviter of user %d: %v", user.ID, err)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -292,8 +300,20 @@ func ResendEmailVerification(c *gin.Context) {
 		}
 		return
 	}
-	verificationCode := fmt.Sprintf("%05d", rand.Intn(99999))
+	verificationCode, err := util.GenerateVerificationCode(util.VerificationCodeLength)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to generate verification code",
+		})
+		return
+	}
 	if err := util.SendVerificationEmail(user.Email, verificationCode); err != nil {
+		if errors.Is(err, util.ErrTooManyAttempts) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Too many attempts, try again later",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to send verification email",
 		})
@@ -304,25 +324,21 @@ func ResendEmailVerification(c *gin.Context) {
 }
 
 type UserController struct {
-	Repo repository.UserRepository // Use concrete type from repository package
+	Repo   repository.UserRepository // Use concrete type from repository package
+	Store  storage.BlobStore
+	Signer *storage.URLSigner
 }
 
-func NewUserController(repo repository.UserRepository) *UserController {
-	return &UserController{Repo: repo}
+func NewUserController(repo repository.UserRepository, store storage.BlobStore, signer *storage.URLSigner) *UserController {
+	return &UserController{Repo: repo, Store: store, Signer: signer}
 }
 
 // PATCH /user/avatar/
 func (uc *UserController) UpdateAvatar(c *gin.Context) {
-	var userID uint64 = 1 // fallback for test/dev
-	if v, ok := c.Get("userID"); ok && v != nil {
-		switch id := v.(type) {
-		case uint64:
-			userID = id
-		case int64:
-			userID = uint64(id)
-		case int:
-			userID = uint64(id)
-		}
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
 	}
 
 	imageData, err := c.GetRawData()
@@ -336,22 +352,119 @@ func (uc *UserController) UpdateAvatar(c *gin.Context) {
 		return
 	}
 
-	avatarDir := "uploads/avatars"
-	if err := os.MkdirAll(avatarDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create avatar dir"})
-		return
-	}
-	filePath := fmt.Sprintf("%s/%d.jpg", avatarDir, userID)
-	if err := os.WriteFile(filePath, imageData, 0644); err != nil {
+	avatarKey := fmt.Sprintf("avatars/%d.jpg", userID)
+	if err := uc.Store.Put(c.Request.Context(), avatarKey, bytes.NewReader(imageData), int64(len(imageData)), "image/jpeg"); err != nil {
+		log.Printf("ERROR: Failed to store avatar of user %d: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save avatar"})
 		return
 	}
 
 	// save path to DB
-	if err := uc.Repo.UpdateAvatarPath(context.Background(), userID, filePath); err != nil {
+	if err := uc.Repo.UpdateAvatarPath(c.Request.Context(), uint64(userID), avatarKey); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update avatar path in DB"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"avatar_url": "/static/" + filePath})
+	c.JSON(http.StatusOK, gin.H{"avatar_url": signedOptionalURL(c.Request.Context(), uc.Signer, userID, &avatarKey)})
+}
+
+// GET /users/:user_id/avatar
+func (uc *UserController) GetAvatar(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+
+	avatarKey, err := uc.Repo.GetAvatarPath(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch avatar"})
+		return
+	}
+	if avatarKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Avatar not found"})
+		return
+	}
+
+	info, err := uc.Store.Stat(c.Request.Context(), avatarKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Avatar not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch avatar"})
+		return
+	}
+	content := storage.NewSeeker(c.Request.Context(), uc.Store, avatarKey, info.Size)
+	defer content.Close()
+
+	// Ключ аватара не меняется при обновлении, поэтому клиенты перепроверяют ETag
+	c.Header("Content-Type", "image/jpeg")
+	c.Header("ETag", storage.ETag(avatarKey, info))
+	c.Header("Cache-Control", "private, no-cache")
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime, content)
+}
+
+// PATCH /users/me/timezone
+// Клиент отправляет пояс устройства при его смене, пароль не нужен
+func (uc *UserController) UpdateTimezone(c *gin.Context) {
+	userID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	var body struct {
+		Timezone string `json:"timezone" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "timezone is required"})
+		return
+	}
+	if _, err := models.LoadTimezone(body.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := uc.Repo.UpdateTimezone(c.Request.Context(), uint64(userID), body.Timezone); err != nil {
+		log.Printf("ERROR: Failed to update timezone of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update timezone"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"timezone": body.Timezone})
+}
+
+// PATCH /admin/users/:user_id/role
+func (uc *UserController) UpdateUserRole(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user_id"})
+		return
+	}
+
+	var body struct {
+		Role models.Role `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || !body.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of: user, moderator, admin"})
+		return
+	}
+
+	rowsAffected, err := uc.Repo.UpdateRole(c.Request.Context(), userID, body.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// Роль зашита в access-токены, поэтому старые сессии закрываются
+	if err := util.RevokeAllSessions(userID); err != nil {
+		log.Printf("ERROR: Failed to revoke sessions of user %d after role change: %v", userID, err)
+	}
+
+	log.Printf("INFO: Role of user %d changed to %s", userID, body.Role)
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "role": body.Role})
 }
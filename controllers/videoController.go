@@ -5,13 +5,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/media"
+	"github.com/merinovvvv/momentic-backend/models"
 	"github.com/merinovvvv/momentic-backend/service"
+	"github.com/merinovvvv/momentic-backend/storage"
 )
 
 const MaxFileSize = 200 << 20
@@ -19,10 +19,11 @@ const MaxFileSize = 200 << 20
 // VideoController содержит зависимость от сервиса
 type VideoController struct {
 	service service.VideoService
+	signer  *storage.URLSigner
 }
 
-func NewVideoController(s service.VideoService) *VideoController {
-	return &VideoController{service: s}
+func NewVideoController(s service.VideoService, signer *storage.URLSigner) *VideoController {
+	return &VideoController{service: s, signer: signer}
 }
 
 type UpdateDescriptionRequest struct {
@@ -31,15 +32,13 @@ type UpdateDescriptionRequest struct {
 
 // --- UploadVideo (POST) ---
 func (vc *VideoController) UploadVideo(c *gin.Context) {
-	// 1. HTTP-логика: Извлечение данных и файла
-	authorIDStr := c.PostForm("author_id")
-	description := c.PostForm("description")
-
-	authorID, err := strconv.ParseInt(authorIDStr, 10, 64)
+	// 1. HTTP-логика: автор берется из токена, описание и файл - из формы
+	authorID, err := currentUserID(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат author_id"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
 		return
 	}
+	description := c.PostForm("description")
 
 	file, err := c.FormFile("video_file")
 	if err != nil {
@@ -52,33 +51,41 @@ func (vc *VideoController) UploadVideo(c *gin.Context) {
 		return
 	}
 
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%d_%d%s", authorID, time.Now().UnixNano(), ext)
-	savePath := filepath.Join("uploads", filename)
-
-	if err := c.SaveUploadedFile(file, savePath); err != nil {
-		log.Printf("ERROR: Failed to save file to disk at %s: %v", savePath, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Не удалось сохранить файл на диске: %s", err.Error())})
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Ошибка при чтении файла: %s", err.Error())})
 		return
 	}
+	defer src.Close()
 
-	video, err := vc.service.UploadVideo(c.Request.Context(), savePath, authorID, description)
+	// Сервис проверяет формат по содержимому, кладет файл в хранилище
+	// и удаляет его, если запись в БД не удалась
+	video, err := vc.service.UploadVideo(c.Request.Context(), authorID, description, src, file.Size)
 
 	if err != nil {
-		os.Remove(savePath)
 		if errors.Is(err, service.ErrAuthorIDRequired) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if status, ok := videoValidationStatus(err); ok {
+			c.JSON(status, videoErrorBody(err))
+			return
+		}
 		log.Printf("FATAL: Service failed during UploadVideo: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении данных о видео в БД"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при сохранении видео"})
 		return
 	}
 
+	vc.withMediaURLs(c, authorID, video)
 	c.JSON(http.StatusCreated, gin.H{
-		"message":  "Видео успешно загружено и опубликовано",
-		"video_id": video.VideoID,
-		"filepath": video.Filepath,
+		"message":      "Видео успешно загружено и опубликовано",
+		"video_id":     video.VideoID,
+		"status":       video.Status,
+		"stream_url":   video.StreamURL,
+		"poster_url":   video.PosterURL,
+		"preview_url":  video.PreviewURL,
+		"blurhash":     video.BlurHash,
+		"retake_count": video.RetakeCount,
 	})
 }
 
@@ -91,9 +98,24 @@ func (vc *VideoController) GetTodayFeedByUserID(c *gin.Context) {
 		return
 	}
 
-	videos, err := vc.service.GetTodayFeed(c.Request.Context(), userID)
+	viewerID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+	if viewerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Можно смотреть только свою ленту"})
+		return
+	}
+
+	// ?date=YYYY-MM-DD - прошедший день по часовому поясу пользователя
+	videos, err := vc.service.GetFeed(c.Request.Context(), userID, c.Query("date"))
 
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidFeedDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if errors.Is(err, service.ErrNoFriends) {
 			c.JSON(http.StatusOK, []interface{}{}) // 200 OK с пустым массивом
 			return
@@ -102,9 +124,106 @@ func (vc *VideoController) GetTodayFeedByUserID(c *gin.Context) {
 		return
 	}
 
+	for i := range videos {
+		vc.withMediaURLs(c, viewerID, &videos[i])
+	}
 	c.JSON(http.StatusOK, videos)
 }
 
+// --- GetFeed (GET /feed?cursor=&limit=&date=) ---
+// Лента текущего пользователя страницами; next_cursor передается в cursor
+func (vc *VideoController) GetFeed(c *gin.Context) {
+	viewerID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultFeedPageSize)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	page, err := vc.service.GetFeedPage(c.Request.Context(), viewerID, c.Query("date"), c.Query("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidFeedDate) || errors.Is(err, service.ErrInvalidFeedCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при получении ленты видео"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	for i := range page.Items {
+		item := &page.Items[i]
+		item.Author.AvatarURL = signedOptionalURL(ctx, vc.signer, viewerID, item.Author.AvatarFilepath)
+		if item.Locked {
+			continue
+		}
+		key := item.Filepath
+		if item.PlaylistKey != nil {
+			key = *item.PlaylistKey
+		}
+		item.StreamURL = signedURL(ctx, vc.signer, viewerID, key)
+		item.PosterURL = signedOptionalURL(ctx, vc.signer, viewerID, item.PosterKey)
+		item.PreviewURL = signedOptionalURL(ctx, vc.signer, viewerID, item.PreviewKey)
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// withMediaURLs заменяет ключи в хранилище короткоживущими подписанными ссылками
+func (vc *VideoController) withMediaURLs(c *gin.Context, viewerID int64, video *models.Video) {
+	if video.Locked {
+		return
+	}
+	ctx := c.Request.Context()
+	// HLS-плейлист, если видео уже перекодировано, иначе исходный файл
+	key := video.Filepath
+	if video.PlaylistKey != nil {
+		key = *video.PlaylistKey
+	}
+	video.StreamURL = signedURL(ctx, vc.signer, viewerID, key)
+	video.PosterURL = signedOptionalURL(ctx, vc.signer, viewerID, video.PosterKey)
+	video.PreviewURL = signedOptionalURL(ctx, vc.signer, viewerID, video.PreviewKey)
+}
+
+// --- StreamVideo (GET /videos/:video_id/stream) ---
+// Отдает файл с поддержкой Range, ETag и Last-Modified (нужно AVPlayer)
+func (vc *VideoController) StreamVideo(c *gin.Context) {
+	videoID, err := strconv.ParseInt(c.Param("video_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат ID видео"})
+		return
+	}
+
+	viewerID, err := currentUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+
+	stream, err := vc.service.OpenStream(c.Request.Context(), viewerID, videoID)
+	if err != nil {
+		if errors.Is(err, service.ErrVideoNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Видео не найдено"})
+			return
+		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Видео доступно только автору и его друзьям"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось открыть видео"})
+		return
+	}
+	defer stream.Content.Close()
+
+	c.Header("Content-Type", stream.Video.MimeType)
+	c.Header("ETag", stream.ETag)
+	c.Header("Cache-Control", "private, max-age=3600")
+	http.ServeContent(c.Writer, c.Request, "", stream.ModTime, stream.Content)
+}
+
 // --- DeleteVideo (DELETE) ---
 func (vc *VideoController) DeleteVideo(c *gin.Context) {
 	videoIDStr := c.Param("video_id")
@@ -114,13 +233,23 @@ func (vc *VideoController) DeleteVideo(c *gin.Context) {
 		return
 	}
 
-	err = vc.service.DeleteVideo(c.Request.Context(), videoID)
+	actor, err := currentActor(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+
+	err = vc.service.DeleteVideo(c.Request.Context(), actor, videoID)
 
 	if err != nil {
 		if errors.Is(err, service.ErrVideoNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Видео не найдено"})
 			return
 		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Можно удалять только свои видео"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка при удалении видео"})
 		return
 	}
@@ -143,8 +272,14 @@ func (vc *VideoController) UpdateVideoDescription(c *gin.Context) {
 		return
 	}
 
+	actor, err := currentActor(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+
 	// Вызов сервиса
-	err = vc.service.UpdateDescription(c.Request.Context(), videoID, req.Description)
+	err = vc.service.UpdateDescription(c.Request.Context(), actor, videoID, req.Description)
 
 	// Обработка ошибок
 	if err != nil {
@@ -152,6 +287,10 @@ func (vc *VideoController) UpdateVideoDescription(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Видео не найдено"})
 			return
 		}
+		if errors.Is(err, service.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Можно редактировать только свои видео"})
+			return
+		}
 		if errors.Is(err, service.ErrDescriptionTooLong) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -162,3 +301,36 @@ func (vc *VideoController) UpdateVideoDescription(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Описание успешно обновлено"})
 }
+
+// videoValidationStatus - HTTP-статус для отклоненного содержимого видео
+func videoValidationStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, media.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType, true
+	case errors.Is(err, media.ErrInvalidMedia), errors.Is(err, service.ErrVideoTooLong):
+		return http.StatusUnprocessableEntity, true
+	case errors.Is(err, service.ErrDailyMomentLimit), errors.Is(err, service.ErrMomentConflict):
+		return http.StatusConflict, true
+	default:
+		return 0, false
+	}
+}
+
+// Машиночитаемые коды ошибок публикации: по ним клиент отличает исчерпанный
+// лимит моментов от гонки двух загрузок, статус у обоих 409
+const (
+	ErrCodeDailyMomentLimit = "daily_moment_limit"
+	ErrCodeMomentConflict   = "moment_conflict"
+)
+
+// videoErrorBody - тело ответа для отклоненной загрузки
+func videoErrorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	switch {
+	case errors.Is(err, service.ErrDailyMomentLimit):
+		body["code"] = ErrCodeDailyMomentLimit
+	case errors.Is(err, service.ErrMomentConflict):
+		body["code"] = ErrCodeMomentConflict
+	}
+	return body
+}
@@ -0,0 +1,30 @@
+package initializers
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// BootstrapAdminEmail возвращает email первого администратора из ADMIN_EMAIL.
+func BootstrapAdminEmail() string {
+	return strings.TrimSpace(os.Getenv("ADMIN_EMAIL"))
+}
+
+// BootstrapAdmin выдает роль admin пользователю с email из ADMIN_EMAIL,
+// чтобы в свежем развертывании появился администратор. Если пользователь
+// еще не зарегистрирован, роль будет выдана при регистрации (см. SignUp).
+func BootstrapAdmin() {
+	email := BootstrapAdminEmail()
+	if email == "" {
+		return
+	}
+	result := DB.Exec("UPDATE users SET role = 'admin' WHERE email = ? AND role <> 'admin'", email)
+	if result.Error != nil {
+		log.Printf("ERROR: Failed to bootstrap admin %s: %v", email, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("INFO: User %s promoted to admin from ADMIN_EMAIL", email)
+	}
+}
@@ -1,18 +1,26 @@
 package main
 
 import (
+	"context"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/exec"
 	"fmt"
+	"path/filepath"
+	"strconv"
 	"time"
+	_ "time/tzdata" // в alpine-образе нет базы часовых поясов
 
 	"github.com/gin-gonic/gin"
 	"github.com/merinovvvv/momentic-backend/controllers"
 	"github.com/merinovvvv/momentic-backend/initializers"
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/push"
 	"github.com/merinovvvv/momentic-backend/repository"
 	"github.com/merinovvvv/momentic-backend/service"
+	"github.com/merinovvvv/momentic-backend/storage"
 	"github.com/merinovvvv/momentic-backend/ws"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"github.com/merinovvvv/momentic-backend/middleware"
@@ -21,6 +29,7 @@ import (
 func init() {
 	initializers.LoadEnvVariables()
 	initializers.ConnectToDb()
+	initializers.BootstrapAdmin()
 }
 
 func main() {
@@ -42,51 +51,213 @@ func main() {
 	// 	})
 	// })
 
+	// Все маршруты этой группы требуют заголовок "Authorization: Bearer <access>"
+	authorized := router.Group("/", middleware.RequireAuth)
+
 	// Global chat via WebSocket (for iOS)
-	router.GET("/ws/chat", ws.ServeChatWs)
+	authorized.GET("/ws/chat", ws.ServeChatWs)
 	db := initializers.DB
+	// Хранилище видео и аватаров: локальный диск или S3/MinIO (STORAGE_DRIVER)
+	blobStore, err := storage.NewFromEnv(context.Background())
+	if err != nil {
+		log.Fatalf("FATAL: Failed to init blob storage: %v", err)
+	}
+	// Короткоживущие подписанные ссылки на видео и аватары (presigned для S3)
+	// Без MEDIA_URL_SECRET ключ выводится из SECRET, но не совпадает с ключом JWT
+	mediaSecret := []byte(os.Getenv("MEDIA_URL_SECRET"))
+	if len(mediaSecret) == 0 {
+		mediaSecret = storage.MediaURLKey([]byte(os.Getenv("SECRET")))
+	}
+	mediaURLTTL := 30 * time.Minute
+	if v := os.Getenv("MEDIA_URL_TTL"); v != "" {
+		if mediaURLTTL, err = time.ParseDuration(v); err != nil {
+			log.Fatalf("FATAL: Invalid MEDIA_URL_TTL %q: %v", v, err)
+		}
+	}
+	urlSigner := storage.NewURLSigner(mediaSecret, mediaURLTTL, blobStore)
+	mediaController := controllers.NewMediaController(urlSigner, blobStore)
+	router.GET("/media/*key", mediaController.ServeMedia)
+	router.HEAD("/media/*key", mediaController.ServeMedia)
 	// Avatar upload endpoint for user
 	userRepo := repository.NewUserRepository(db)
-	userController := controllers.NewUserController(userRepo)
-	router.PATCH("/user/avatar/", userController.UpdateAvatar)
-
-	// Serve static for avatars
-	router.Static("/static", "./uploads")
+	userController := controllers.NewUserController(userRepo, blobStore, urlSigner)
+	authorized.PATCH("/user/avatar/", userController.UpdateAvatar)
+	authorized.GET("/users/:user_id/avatar", userController.GetAvatar)
+	authorized.PATCH("/users/me/timezone", userController.UpdateTimezone)
 
 	videoRepo := repository.NewVideoRepository(db)
 	reactionRepo := repository.NewReactionRepository(db)
 
-	videoService := service.NewVideoService(videoRepo)
-	reactionService := service.NewReactionService(reactionRepo)
+	auditRepo := repository.NewAuditRepository(db)
+	friendshipRepo := repository.NewFriendshipRepository(db)
+
+	videoOptions := []service.VideoServiceOption{service.WithAuditRepository(auditRepo), service.WithBlobStore(blobStore)}
+	// Максимальная длительность видео, например VIDEO_MAX_DURATION=90s
+	if v := os.Getenv("VIDEO_MAX_DURATION"); v != "" {
+		maxDuration, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("FATAL: Invalid VIDEO_MAX_DURATION %q: %v", v, err)
+		}
+		videoOptions = append(videoOptions, service.WithMaxDuration(maxDuration))
+	}
+	// Перекодирование в HLS включается, если найден ffmpeg (FFMPEG_PATH);
+	// без него видео публикуются сразу в исходном виде
+	ffmpegPath := os.Getenv("FFMPEG_PATH")
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	var transcodeWorker *service.TranscodeWorker
+	if path, err := exec.LookPath(ffmpegPath); err != nil {
+		log.Printf("WARNING: ffmpeg not found (%v), videos are published without HLS transcoding", err)
+	} else {
+		transcodeRepo := repository.NewTranscodeRepository(db)
+		transcodeWorker, err = service.NewTranscodeWorker(transcodeRepo, videoRepo, blobStore,
+			service.FFmpegTranscoder{Path: path}, filepath.Join(os.TempDir(), "momentic-transcode"))
+		if err != nil {
+			log.Fatalf("FATAL: Failed to init transcode worker: %v", err)
+		}
+		videoOptions = append(videoOptions, service.WithTranscodeQueue(transcodeRepo))
+	}
+	// Сколько раз в день можно переснять момент, например MOMENT_MAX_RETAKES=0
+	if v := os.Getenv("MOMENT_MAX_RETAKES"); v != "" {
+		maxRetakes, err := strconv.Atoi(v)
+		if err != nil || maxRetakes < 0 {
+			log.Fatalf("FATAL: Invalid MOMENT_MAX_RETAKES %q", v)
+		}
+		videoOptions = append(videoOptions, service.WithMaxRetakes(maxRetakes))
+	}
+	// "Опубликуй, чтобы увидеть": FEED_POST_TO_SEE=true закрывает сегодняшнюю
+	// ленту заглушками, пока пользователь не выложил свой момент
+	if v := os.Getenv("FEED_POST_TO_SEE"); v != "" {
+		postToSee, err := strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("FATAL: Invalid FEED_POST_TO_SEE %q: %v", v, err)
+		}
+		videoOptions = append(videoOptions, service.WithPostToSee(postToSee))
+	}
+	// Рейтинг: журнал начислений, затухание пересчитывается раз в час
+	ratingService := service.NewRatingService(repository.NewRatingRepository(db), nil, 0)
+	go ratingService.RunDecay(context.Background(), service.RatingDecayInterval)
+	videoOptions = append(videoOptions, service.WithRatingService(ratingService))
+
+	// Серии: сброс пропущенных дней идет по расписанию, а не только при чтении
+	streakService := service.NewStreakService(repository.NewStreakRepository(db), service.WithStreakRatings(ratingService))
+	go streakService.RunResetJob(context.Background(), 15*time.Minute)
+	videoOptions = append(videoOptions, service.WithStreakService(streakService))
+	streakController := controllers.NewStreakController(streakService)
+	authorized.GET("/users/:user_id/streak", streakController.GetStreak)
+	authorized.POST("/users/me/streak/freeze", streakController.Freeze)
+
+	// Момент дня: окно публикации, например MOMENT_WINDOW=2m
+	momentWindow := service.DefaultMomentWindow
+	if v := os.Getenv("MOMENT_WINDOW"); v != "" {
+		momentWindow, err = time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("FATAL: Invalid MOMENT_WINDOW %q: %v", v, err)
+		}
+	}
+	eventHub := ws.NewEventHub()
+	momentService := service.NewMomentService(repository.NewMomentRepository(db), push.Multi{eventHub, push.LogNotifier{}}, momentWindow)
+	go momentService.RunScheduler(context.Background(), 30*time.Second)
+	videoOptions = append(videoOptions, service.WithMomentService(momentService))
+	momentController := controllers.NewMomentController(momentService)
+	authorized.GET("/ws/events", eventHub.ServeWs)
+	authorized.GET("/moments/today", momentController.GetToday)
+
+	videoService := service.NewVideoService(videoRepo, videoOptions...)
+	if transcodeWorker != nil {
+		go transcodeWorker.Run(context.Background())
+	}
+	reactionService := service.NewReactionService(reactionRepo, friendshipRepo, service.WithReactionRatings(ratingService), service.WithReactionVideos(videoService))
 
 	commentRepo := repository.NewCommentRepository(db)
-	commentController := controllers.NewCommentController(commentRepo)
+	commentController := controllers.NewCommentController(commentRepo, friendshipRepo, videoService, urlSigner)
 
-	videoController := controllers.NewVideoController(videoService)
+	videoController := controllers.NewVideoController(videoService, urlSigner)
 	reactionController := controllers.NewReactionController(reactionService)
-	//curl -X POST http://localhost:8080/videos -F "author_id=2" -F "description=Тестовое видео" -F "video_file=@file_path"
-	router.POST("/videos", videoController.UploadVideo)
 
-	router.POST("/videos/:video_id/comments", commentController.PostComment)
-	router.GET("/videos/:video_id/comments", commentController.GetCommentsByVideoID)
+	// Таблицы рейтинга, например LEADERBOARD_REFRESH_INTERVAL=1m
+	leaderboardRefresh := service.DefaultLeaderboardRefresh
+	if v := os.Getenv("LEADERBOARD_REFRESH_INTERVAL"); v != "" {
+		leaderboardRefresh, err = time.ParseDuration(v)
+		if err != nil || leaderboardRefresh <= 0 {
+			log.Fatalf("FATAL: Invalid LEADERBOARD_REFRESH_INTERVAL %q", v)
+		}
+	}
+	leaderboardService := service.NewLeaderboardService(repository.NewLeaderboardRepository(db))
+	go leaderboardService.RunRefresher(context.Background(), leaderboardRefresh)
+	leaderboardController := controllers.NewLeaderboardController(leaderboardService, urlSigner)
+	authorized.GET("/leaderboards/:scope", leaderboardController.GetLeaderboard)
+	//curl -X POST http://localhost:8080/videos -H "Authorization: Bearer $ACCESS" -F "description=Тестовое видео" -F "video_file=@file_path"
+	authorized.POST("/videos", videoController.UploadVideo)
+
+	// Resumable-загрузка (tus): POST создает загрузку, PATCH дописывает части
+	uploadDir := os.Getenv("UPLOAD_TMP_DIR")
+	if uploadDir == "" {
+		uploadDir = filepath.Join(os.TempDir(), "momentic-uploads")
+	}
+	uploadService, err := service.NewUploadService(repository.NewUploadRepository(db), videoService, uploadDir, service.DefaultUploadTTL, controllers.MaxFileSize)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to init uploads: %v", err)
+	}
+	go uploadService.RunExpiryCleanup(context.Background(), 10*time.Minute)
+	uploadController := controllers.NewUploadController(uploadService)
+	router.OPTIONS("/uploads", uploadController.Options)
+	authorized.POST("/uploads", uploadController.Create)
+	authorized.HEAD("/uploads/:upload_id", uploadController.Head)
+	authorized.PATCH("/uploads/:upload_id", uploadController.Patch)
+	authorized.DELETE("/uploads/:upload_id", uploadController.Terminate)
+
+	authorized.POST("/videos/:video_id/comments", commentController.PostComment)
+	authorized.GET("/videos/:video_id/comments", commentController.GetCommentsByVideoID)
+
+	authorized.GET("/users/:user_id/friends/videos", videoController.GetTodayFeedByUserID)
+	authorized.GET("/feed", videoController.GetFeed)
+
+	authorized.PATCH("/videos/:video_id", videoController.UpdateVideoDescription)
+
+	authorized.DELETE("/videos/:video_id", videoController.DeleteVideo)
+	// Файлы видео отдаются только автору и друзьям, с поддержкой Range
+	authorized.GET("/videos/:video_id/stream", videoController.StreamVideo)
+	authorized.HEAD("/videos/:video_id/stream", videoController.StreamVideo)
+
+	authorized.POST("/videos/:video_id/reactions", reactionController.HandleReaction)
+	authorized.DELETE("/videos/:video_id/reactions", reactionController.RemoveReaction)
+	authorized.GET("/videos/:video_id/reactions", reactionController.GetVideoReactions)
+
+	friendshipService := service.NewFriendshipService(friendshipRepo)
+	friendshipController := controllers.NewFriendshipController(friendshipService, urlSigner)
 
-	router.GET("/users/:user_id/friends/videos", videoController.GetTodayFeedByUserID)
+	authorized.GET("/friends", friendshipController.ListFriends)
+	authorized.DELETE("/friends/:user_id", friendshipController.Unfriend)
+	authorized.GET("/friends/requests/incoming", friendshipController.ListIncoming)
+	authorized.GET("/friends/requests/outgoing", friendshipController.ListOutgoing)
+	authorized.POST("/friends/requests/:user_id", friendshipController.SendRequest)
+	authorized.DELETE("/friends/requests/:user_id", friendshipController.CancelRequest)
+	authorized.POST("/friends/requests/:user_id/accept", friendshipController.AcceptRequest)
+	authorized.POST("/friends/requests/:user_id/decline", friendshipController.DeclineRequest)
 
-	router.PATCH("/videos/:video_id", videoController.UpdateVideoDescription)
+	ws.GetChatHub().SetBlockLookup(friendshipRepo.GetBlockRelatedIDs)
 
-	router.DELETE("/videos/:video_id", videoController.DeleteVideo)
+	authorized.POST("/invites", controllers.CreateInvite)
+	authorized.GET("/invites", controllers.ListInvites)
+	authorized.DELETE("/invites/:invite_id", controllers.RevokeInvite)
 
-	router.POST("/videos/:video_id/reactions", reactionController.HandleReaction)
-	router.DELETE("/videos/:video_id/reactions", reactionController.RemoveReaction)
-	router.GET("/videos/:video_id/reactions", reactionController.GetVideoReactions)
+	authorized.GET("/users/me/blocked", friendshipController.ListBlocked)
+	authorized.GET("/users/me/friend-suggestions", friendshipController.ListSuggestions)
+	authorized.POST("/users/me/friend-suggestions/:user_id/dismiss", friendshipController.DismissSuggestion)
+	authorized.POST("/users/:user_id/block", friendshipController.Block)
+	authorized.DELETE("/users/:user_id/block", friendshipController.Unblock)
 
 	hub := ws.NewHub()
 	go hub.Run() // запускаем hub в горутине
 
 	websocketController := controllers.NewWebSocketController(hub)
-	router.GET("/ws/broadcast", websocketController.ServeWs)
-	//curl -X POST http://localhost:8080/admin/broadcast -H "Content-Type: application/json" -d "{\"content\": \"Это сообщение для рассылки!\"}"
-	router.POST("/admin/broadcast", func(c *gin.Context) {
+	authorized.GET("/ws/broadcast", websocketController.ServeWs)
+	// Администрирование: только для роли admin
+	admin := authorized.Group("/admin", middleware.RequireRole(models.RoleAdmin))
+	//curl -X POST http://localhost:8080/admin/broadcast -H "Authorization: Bearer $ACCESS" -H "Content-Type: application/json" -d "{\"content\": \"Это сообщение для рассылки!\"}"
+	admin.POST("/broadcast", func(c *gin.Context) {
 		var msg struct {
 			Content string `json:"content" binding:"required"`
 		}
@@ -102,17 +273,27 @@ func main() {
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "Broadcast sent"})
 	})
+	admin.PATCH("/users/:user_id/role", userController.UpdateUserRole)
+	admin.GET("/invites/stats", controllers.GetInviteStats)
+	ratingController := controllers.NewRatingController(ratingService)
+	admin.POST("/ratings/recompute", ratingController.Recompute)
 
 	videoHub := ws.NewVideoHub()
 	go videoHub.Run()
 
 	log.Println("INFO: Server started.")
 	router.POST("/auth/register", controllers.SignUp)
-	router.PATCH("/auth/register", middleware.RequireAuth)
+	router.PATCH("/auth/register", middleware.RequireAuth, controllers.ChangeUserInfo)
 	router.POST("/auth/login", controllers.Login)
 	router.POST("/auth/verify-code", controllers.VerifyEmail)
 	router.POST("/auth/refresh", controllers.Refresh)
+	router.POST("/auth/logout", controllers.Logout)
+	authorized.POST("/auth/logout-all", controllers.LogoutAll)
+	authorized.GET("/auth/sessions", controllers.GetSessions)
+	authorized.DELETE("/auth/sessions/:session_id", controllers.DeleteSession)
 	router.POST("/auth/resend-verify-code", controllers.ResendEmailVerification)
+	router.POST("/auth/password/forgot", controllers.ForgotPassword)
+	router.POST("/auth/password/reset", controllers.ResetPassword)
 	router.GET("/validate", middleware.RequireAuth, controllers.Validate)
 	fmt.Println(router.Routes())
 	router.Run() // listens on 0.0.0.0:8080 by default
@@ -0,0 +1,51 @@
+package media
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestBlurHash_SolidColor(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for i := range img.Pix {
+		if i%4 == 3 {
+			img.Pix[i] = 0xff
+		}
+	}
+
+	hash, err := BlurHash(img, 4, 3)
+	if err != nil {
+		t.Fatalf("BlurHash: %v", err)
+	}
+	// У однотонной картинки все AC-компоненты нулевые
+	if want := "L00000" + strings.Repeat("fQ", 11); hash != want {
+		t.Errorf("BlurHash(black) = %q, want %q", hash, want)
+	}
+}
+
+func TestBlurHash_Gradient(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 6), G: uint8(y * 8), B: 128, A: 0xff})
+		}
+	}
+
+	hash, err := BlurHash(img, 3, 4)
+	if err != nil {
+		t.Fatalf("BlurHash: %v", err)
+	}
+	if len(hash) != 4+2*12 || hash[0] != base83Chars[2+3*9] {
+		t.Errorf("BlurHash(gradient) = %q: unexpected size header or length", hash)
+	}
+	if strings.HasSuffix(hash, strings.Repeat("fQ", 11)) {
+		t.Errorf("BlurHash(gradient) = %q: AC components must not be zero", hash)
+	}
+
+	if _, err := BlurHash(img, 0, 10); !errors.Is(err, ErrInvalidComponents) {
+		t.Errorf("BlurHash(0, 10) error = %v, want ErrInvalidComponents", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Package media проверяет загружаемые видео: определяет контейнер по
+// содержимому (а не по расширению) и читает метаданные MP4/QuickTime.
+package media
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// SniffLen - сколько первых байт файла нужно для определения типа
+const SniffLen = 3072
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported video format: only MP4 and QuickTime are accepted")
+	ErrInvalidMedia      = errors.New("invalid or corrupted video file")
+)
+
+// Format - допустимый контейнер
+type Format struct {
+	MimeType  string
+	Extension string
+}
+
+var allowedFormats = map[string]Format{
+	"video/mp4":       {MimeType: "video/mp4", Extension: ".mp4"},
+	"video/quicktime": {MimeType: "video/quicktime", Extension: ".mov"},
+}
+
+// Info - метаданные видеодорожки
+type Info struct {
+	Duration time.Duration
+	Width    int
+	Height   int
+	Rotation int    // 0, 90, 180 или 270 градусов по часовой стрелке
+	Codec    string // fourcc первой записи stsd: avc1, hvc1, ...
+	HasAudio bool   // есть ли в файле звуковая дорожка
+}
+
+// Sniff определяет контейнер по первым байтам файла.
+func Sniff(r io.ReaderAt, size int64) (Format, error) {
+	n := int64(SniffLen)
+	if size < n {
+		n = size
+	}
+	header := make([]byte, n)
+	if _, err := r.ReadAt(header, 0); err != nil && err != io.EOF {
+		return Format{}, err
+	}
+
+	for mt := mimetype.Detect(header); mt != nil; mt = mt.Parent() {
+		if format, ok := allowedFormats[mt.String()]; ok {
+			return format, nil
+		}
+	}
+	return Format{}, ErrUnsupportedFormat
+}
+
+// Probe проверяет формат и читает метаданные файла.
+func Probe(r io.ReaderAt, size int64) (Format, Info, error) {
+	format, err := Sniff(r, size)
+	if err != nil {
+		return Format{}, Info{}, err
+	}
+	info, err := ParseMP4(r, size)
+	if err != nil {
+		return Format{}, Info{}, err
+	}
+	return format, info, nil
+}
@@ -0,0 +1,260 @@
+package media
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Разбор структуры боксов ISO BMFF (MP4) и QuickTime. Читаются только
+// нужные листовые боксы, поэтому moov в конце файла не требует загрузки
+// всего файла в память.
+
+const maxLeafBoxSize = 1 << 20
+
+type box struct {
+	typ   string
+	start int64 // начало содержимого (после заголовка)
+	end   int64
+}
+
+type trackInfo struct {
+	handler   string
+	timescale uint32
+	duration  uint64
+	width     int
+	height    int
+	rotation  int
+	codec     string
+}
+
+// ParseMP4 читает длительность, размеры, поворот и кодек видеодорожки.
+func ParseMP4(r io.ReaderAt, size int64) (Info, error) {
+	var (
+		movieTimescale uint32
+		movieDuration  uint64
+		video          *trackInfo
+		foundMoov      bool
+		hasAudio       bool
+	)
+
+	err := walkBoxes(r, 0, size, func(b box) error {
+		if b.typ != "moov" {
+			return nil
+		}
+		foundMoov = true
+		return walkBoxes(r, b.start, b.end, func(b box) error {
+			switch b.typ {
+			case "mvhd":
+				data, err := readBox(r, b)
+				if err != nil {
+					return err
+				}
+				movieTimescale, movieDuration, err = parseMvhd(data)
+				return err
+			case "trak":
+				track, err := parseTrak(r, b)
+				if err != nil {
+					return err
+				}
+				if track.handler == "vide" && video == nil {
+					video = track
+				}
+				if track.handler == "soun" {
+					hasAudio = true
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return Info{}, err
+	}
+	if !foundMoov {
+		return Info{}, fmt.Errorf("%w: no moov box", ErrInvalidMedia)
+	}
+	if video == nil {
+		return Info{}, fmt.Errorf("%w: no video track", ErrInvalidMedia)
+	}
+
+	info := Info{
+		Width:    video.width,
+		Height:   video.height,
+		Rotation: video.rotation,
+		Codec:    video.codec,
+		HasAudio: hasAudio,
+	}
+	switch {
+	case movieTimescale > 0 && movieDuration > 0:
+		info.Duration = scaleDuration(movieDuration, movieTimescale)
+	case video.timescale > 0:
+		info.Duration = scaleDuration(video.duration, video.timescale)
+	}
+	if info.Duration <= 0 {
+		return Info{}, fmt.Errorf("%w: unknown duration", ErrInvalidMedia)
+	}
+	return info, nil
+}
+
+func parseTrak(r io.ReaderAt, trak box) (*trackInfo, error) {
+	track := &trackInfo{}
+	var sampleWidth, sampleHeight int
+
+	var visit func(b box) error
+	visit = func(b box) error {
+		switch b.typ {
+		case "mdia", "minf", "stbl":
+			return walkBoxes(r, b.start, b.end, visit)
+		case "tkhd", "mdhd", "hdlr", "stsd":
+			data, err := readBox(r, b)
+			if err != nil {
+				return err
+			}
+			switch b.typ {
+			case "tkhd":
+				return parseTkhd(data, track)
+			case "mdhd":
+				return parseMdhd(data, track)
+			case "hdlr":
+				if len(data) < 12 {
+					return fmt.Errorf("%w: short hdlr", ErrInvalidMedia)
+				}
+				track.handler = string(data[8:12])
+			case "stsd":
+				// version/flags(4), entry_count(4), затем первая запись: size(4) type(4) ...
+				if len(data) < 16 {
+					return fmt.Errorf("%w: short stsd", ErrInvalidMedia)
+				}
+				track.codec = string(data[12:16])
+				// VisualSampleEntry: width/height через 24 байта после заголовка записи
+				if len(data) >= 16+28 {
+					sampleWidth = int(binary.BigEndian.Uint16(data[16+24:]))
+					sampleHeight = int(binary.BigEndian.Uint16(data[16+26:]))
+				}
+			}
+		}
+		return nil
+	}
+	if err := walkBoxes(r, trak.start, trak.end, visit); err != nil {
+		return nil, err
+	}
+
+	if track.width == 0 || track.height == 0 {
+		track.width, track.height = sampleWidth, sampleHeight
+	}
+	return track, nil
+}
+
+func parseMvhd(data []byte) (timescale uint32, duration uint64, err error) {
+	if len(data) < 4 {
+		return 0, 0, fmt.Errorf("%w: short mvhd", ErrInvalidMedia)
+	}
+	if data[0] == 1 {
+		if len(data) < 32 {
+			return 0, 0, fmt.Errorf("%w: short mvhd", ErrInvalidMedia)
+		}
+		return binary.BigEndian.Uint32(data[20:]), binary.BigEndian.Uint64(data[24:]), nil
+	}
+	if len(data) < 20 {
+		return 0, 0, fmt.Errorf("%w: short mvhd", ErrInvalidMedia)
+	}
+	return binary.BigEndian.Uint32(data[12:]), uint64(binary.BigEndian.Uint32(data[16:])), nil
+}
+
+func parseMdhd(data []byte, track *trackInfo) error {
+	// Раскладка совпадает с mvhd
+	timescale, duration, err := parseMvhd(data)
+	if err != nil {
+		return fmt.Errorf("%w: short mdhd", ErrInvalidMedia)
+	}
+	track.timescale, track.duration = timescale, duration
+	return nil
+}
+
+func parseTkhd(data []byte, track *trackInfo) error {
+	matrixAt := 40
+	if len(data) > 0 && data[0] == 1 {
+		matrixAt = 52
+	}
+	if len(data) < matrixAt+44 {
+		return fmt.Errorf("%w: short tkhd", ErrInvalidMedia)
+	}
+	matrix := data[matrixAt:]
+	a := int32(binary.BigEndian.Uint32(matrix[0:]))
+	b := int32(binary.BigEndian.Uint32(matrix[4:]))
+	c := int32(binary.BigEndian.Uint32(matrix[12:]))
+	d := int32(binary.BigEndian.Uint32(matrix[16:]))
+	track.rotation = rotationFromMatrix(a, b, c, d)
+
+	// Ширина и высота - числа 16.16 с фиксированной точкой
+	track.width = int(binary.BigEndian.Uint32(data[matrixAt+36:]) >> 16)
+	track.height = int(binary.BigEndian.Uint32(data[matrixAt+40:]) >> 16)
+	return nil
+}
+
+// rotationFromMatrix распознает матрицы поворота, которые пишут камеры телефонов.
+func rotationFromMatrix(a, b, c, d int32) int {
+	const one = 1 << 16
+	switch {
+	case a == 0 && b == one && c == -one && d == 0:
+		return 90
+	case a == -one && b == 0 && c == 0 && d == -one:
+		return 180
+	case a == 0 && b == -one && c == one && d == 0:
+		return 270
+	default:
+		return 0
+	}
+}
+
+// walkBoxes вызывает fn для каждого бокса в диапазоне [start, end).
+func walkBoxes(r io.ReaderAt, start, end int64, fn func(box) error) error {
+	var header [16]byte
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return fmt.Errorf("%w: read box header: %v", ErrInvalidMedia, err)
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		typ := string(header[4:8])
+		headerLen := int64(8)
+
+		switch size {
+		case 0: // бокс до конца файла
+			size = end - offset
+		case 1: // 64-битный размер
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return fmt.Errorf("%w: read box size: %v", ErrInvalidMedia, err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerLen = 16
+		}
+		if size < headerLen || offset+size > end {
+			return fmt.Errorf("%w: bad size of %q box", ErrInvalidMedia, typ)
+		}
+
+		if err := fn(box{typ: typ, start: offset + headerLen, end: offset + size}); err != nil {
+			return err
+		}
+		offset += size
+	}
+	return nil
+}
+
+func readBox(r io.ReaderAt, b box) ([]byte, error) {
+	size := b.end - b.start
+	if size > maxLeafBoxSize {
+		return nil, fmt.Errorf("%w: %q box is too large", ErrInvalidMedia, b.typ)
+	}
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, b.start); err != nil {
+		return nil, fmt.Errorf("%w: read %q box: %v", ErrInvalidMedia, b.typ, err)
+	}
+	return data, nil
+}
+
+func scaleDuration(value uint64, timescale uint32) time.Duration {
+	seconds := value / uint64(timescale)
+	remainder := value % uint64(timescale)
+	return time.Duration(seconds)*time.Second + time.Duration(remainder)*time.Second/time.Duration(timescale)
+}
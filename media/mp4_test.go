@@ -0,0 +1,114 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func mp4Box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(out, uint32(8+len(body)))
+	copy(out[4:], typ)
+	return append(out, body...)
+}
+
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+// testMP4 собирает минимальный файл: ftyp, moov с одной дорожкой и mdat.
+func testMP4(brand, handler string, matrix [4]int32, timescale, duration uint32) []byte {
+	const one = 1 << 16
+
+	mvhd := bytes.Join([][]byte{u32(0), u32(0), u32(0), u32(timescale), u32(duration), make([]byte, 80)}, nil)
+
+	tkhd := bytes.Join([][]byte{
+		u32(0), u32(0), u32(0), u32(1), u32(0), u32(duration), // version/flags ... duration
+		make([]byte, 16), // reserved, layer, alternate_group, volume, reserved
+		u32(uint32(matrix[0])), u32(uint32(matrix[1])), u32(0),
+		u32(uint32(matrix[2])), u32(uint32(matrix[3])), u32(0),
+		u32(0), u32(0), u32(1 << 30),
+		u32(1920 * one), u32(1080 * one),
+	}, nil)
+
+	mdhd := bytes.Join([][]byte{u32(0), u32(0), u32(0), u32(timescale), u32(duration), u32(0)}, nil)
+	hdlr := bytes.Join([][]byte{u32(0), u32(0), []byte(handler), make([]byte, 13)}, nil)
+	avc1 := mp4Box("avc1", make([]byte, 24), []byte{0x07, 0x80, 0x04, 0x38}, make([]byte, 50))
+	stsd := mp4Box("stsd", u32(0), u32(1), avc1)
+
+	trak := mp4Box("trak",
+		mp4Box("tkhd", tkhd),
+		mp4Box("mdia",
+			mp4Box("mdhd", mdhd),
+			mp4Box("hdlr", hdlr),
+			mp4Box("minf", mp4Box("stbl", stsd)),
+		),
+	)
+
+	return bytes.Join([][]byte{
+		mp4Box("ftyp", []byte(brand), u32(0), []byte(brand)),
+		mp4Box("mdat", make([]byte, 64)),
+		mp4Box("moov", mp4Box("mvhd", mvhd), trak),
+	}, nil)
+}
+
+func TestProbe_MP4(t *testing.T) {
+	const one = 1 << 16
+	data := testMP4("isom", "vide", [4]int32{0, one, -one, 0}, 600, 9000)
+
+	format, info, err := Probe(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if format.MimeType != "video/mp4" || format.Extension != ".mp4" {
+		t.Errorf("format = %+v, want video/mp4", format)
+	}
+	want := Info{Duration: 15 * time.Second, Width: 1920, Height: 1080, Rotation: 90, Codec: "avc1"}
+	if info != want {
+		t.Errorf("info = %+v, want %+v", info, want)
+	}
+}
+
+func TestProbe_QuickTime(t *testing.T) {
+	const one = 1 << 16
+	data := testMP4("qt  ", "vide", [4]int32{-one, 0, 0, -one}, 1000, 2500)
+
+	format, info, err := Probe(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if format.MimeType != "video/quicktime" || format.Extension != ".mov" {
+		t.Errorf("format = %+v, want video/quicktime", format)
+	}
+	if info.Rotation != 180 || info.Duration != 2500*time.Millisecond {
+		t.Errorf("info = %+v, want rotation 180 and 2.5s", info)
+	}
+}
+
+func TestProbe_Rejects(t *testing.T) {
+	const one = 1 << 16
+	audioOnly := testMP4("isom", "soun", [4]int32{one, 0, 0, one}, 1000, 1000)
+	truncated := testMP4("isom", "vide", [4]int32{one, 0, 0, one}, 1000, 1000)
+	truncated = truncated[:len(truncated)-20]
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"NotAVideo", png, ErrUnsupportedFormat},
+		{"NoVideoTrack", audioOnly, ErrInvalidMedia},
+		{"Truncated", truncated, ErrInvalidMedia},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Probe() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,44 +1,103 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
-	"os"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/merinovvvv/momentic-backend/models"
 	"github.com/merinovvvv/momentic-backend/initializers"
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/util"
+)
+
+// Ключи, под которыми RequireAuth кладет данные пользователя в gin.Context
+const (
+	ContextUserKey      = "user"
+	ContextUserIDKey    = "userID"
+	ContextSessionIDKey = "sessionID"
+	ContextRoleKey      = "role"
 )
 
+var errNoBearerToken = errors.New("missing bearer token")
+
+// RequireAuth проверяет access-токен из заголовка "Authorization: Bearer <token>"
+// и кладет в контекст пользователя (models.User) и его ID (int64).
 func RequireAuth(c *gin.Context) {
-	var tokenString string
-	if c.Bind(&tokenString) != nil {
-		c.AbortWithStatus(http.StatusUnauthorized)
+	tokenString, err := bearerToken(c.GetHeader("Authorization"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+		return
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		return []byte(os.Getenv("SECRET")), nil
-	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	claims, err := util.ParseAccessToken(tokenString)
+	if err != nil || claims.SessionID == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid access token"})
+		return
+	}
+	// Access-токен отозванной сессии (logout, reuse refresh-токена) больше не действует
+	if active, err := util.IsSessionActive(claims.SessionID); err != nil || !active {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session revoked"})
+		return
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 64)
 	if err != nil {
-		c.AbortWithStatus(http.StatusUnauthorized)
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if float64(time.Now().Unix()) > claims["exp"].(float64) {
-			c.AbortWithStatus(http.StatusUnauthorized)
-		}
-		var user models.User
-		err := initializers.DB.First(&user, claims["sub"]).Error
-		if err != nil {
-			c.AbortWithStatus(http.StatusUnauthorized)
-		}
-        if user.Verified == false {
-            c.AbortWithStatus(http.StatusForbidden)
-        }
-		c.Set("user", user)
-		c.Next()
-	} else {
-		c.AbortWithStatus(http.StatusUnauthorized)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid access token"})
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, userID).Error; err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid access token"})
+		return
+	}
+	if !user.Verified {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Email not verified"})
+		return
+	}
+
+	c.Set(ContextUserKey, user)
+	c.Set(ContextUserIDKey, int64(user.ID))
+	c.Set(ContextSessionIDKey, claims.SessionID)
+	c.Set(ContextRoleKey, claims.Role)
+	c.Next()
+}
+
+// GetUserID возвращает ID пользователя, выставленный RequireAuth.
+func GetUserID(c *gin.Context) (int64, bool) {
+	v, ok := c.Get(ContextUserIDKey)
+	if !ok {
+		return 0, false
+	}
+	id, ok := v.(int64)
+	return id, ok && id != 0
+}
+
+// GetSessionID возвращает ID сессии (family refresh-токенов) текущего access-токена.
+func GetSessionID(c *gin.Context) string {
+	return c.GetString(ContextSessionIDKey)
+}
+
+// GetUser возвращает пользователя, выставленного RequireAuth.
+func GetUser(c *gin.Context) (models.User, bool) {
+	v, ok := c.Get(ContextUserKey)
+	if !ok {
+		return models.User{}, false
+	}
+	user, ok := v.(models.User)
+	return user, ok
+}
+
+func bearerToken(header string) (string, error) {
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", errNoBearerToken
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errNoBearerToken
 	}
+	return token, nil
 }
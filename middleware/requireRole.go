@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/merinovvvv/momentic-backend/models"
+)
+
+// RequireRole пропускает запрос, только если роль из access-токена входит в roles.
+// Должен стоять после RequireAuth.
+func RequireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := GetRole(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+			return
+		}
+		if !slices.Contains(roles, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetRole возвращает роль пользователя из access-токена.
+func GetRole(c *gin.Context) (models.Role, bool) {
+	v, ok := c.Get(ContextRoleKey)
+	if !ok {
+		return "", false
+	}
+	role, ok := v.(models.Role)
+	return role, ok && role.IsValid()
+}
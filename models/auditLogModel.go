@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AuditLog - запись о действии модератора/администратора над чужими данными
+type AuditLog struct {
+	AuditID    int64     `gorm:"primaryKey;column:audit_id;autoIncrement"`
+	ActorID    int64     `gorm:"column:actor_id;type:BIGINT;not null;index"`
+	ActorRole  Role      `gorm:"column:actor_role;type:VARCHAR(16);not null"`
+	Action     string    `gorm:"column:action;type:VARCHAR(64);not null"`
+	TargetType string    `gorm:"column:target_type;type:VARCHAR(32);not null"`
+	TargetID   int64     `gorm:"column:target_id;type:BIGINT;not null"`
+	Details    string    `gorm:"column:details;type:TEXT;not null;default:''"`
+	CreatedAt  time.Time `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
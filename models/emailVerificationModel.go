@@ -5,9 +5,9 @@ import "time"
 type EmailVerification struct {
 	// ID  	   uint64	 `gorm:"primaryKey;column:id"`
 	Email      string    `gorm:"primaryKey;size:255;unique"`
-	Code       string    `gorm:"size:64;not null"` //hashed
+	Code       string    `gorm:"size:64;not null"` // HMAC-SHA256 от кода, hex
 	ExpiresAt time.Time `gorm:"not null;column:expires_at"`
-	// Used bool
+	Attempts   int       `gorm:"not null;default:0;column:attempts"` // неудачные попытки ввода
 }
 
 func (e EmailVerification) TableName() string {
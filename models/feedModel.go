@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// FeedItem - видео в ленте вместе со всем, что нужно ячейке клиента:
+// автор, реакции и число комментариев, без дополнительных запросов
+type FeedItem struct {
+	VideoID     int64     `gorm:"column:video_id" json:"video_id"`
+	Description string    `gorm:"column:description" json:"description"`
+	DurationMs  int64     `gorm:"column:duration_ms" json:"duration_ms"`
+	Width       int       `gorm:"column:width" json:"width"`
+	Height      int       `gorm:"column:height" json:"height"`
+	Rotation    int       `gorm:"column:rotation" json:"rotation"`
+	BlurHash    string    `gorm:"column:blurhash" json:"blurhash"`
+	PostedLate  bool      `gorm:"column:posted_late" json:"posted_late"`
+	RetakeCount int       `gorm:"column:retake_count" json:"retake_count"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+
+	Author FeedAuthor `gorm:"embedded" json:"author"`
+
+	// Ключи в хранилище, наружу отдаются подписанные ссылки
+	Filepath    string  `gorm:"column:filepath" json:"-"`
+	PlaylistKey *string `gorm:"column:playlist_key" json:"-"`
+	PosterKey   *string `gorm:"column:poster_key" json:"-"`
+	PreviewKey  *string `gorm:"column:preview_key" json:"-"`
+
+	StreamURL  string  `gorm:"-" json:"stream_url"`
+	PosterURL  *string `gorm:"-" json:"poster_url"`
+	PreviewURL *string `gorm:"-" json:"preview_url"`
+
+	Reactions    map[ReactionKind]int64 `gorm:"-" json:"reactions"`
+	MyReaction   *ReactionKind          `gorm:"-" json:"my_reaction"`
+	CommentCount int64                  `gorm:"-" json:"comment_count"`
+
+	// Locked - заглушка "опубликуй, чтобы увидеть" (см. Video.Locked)
+	Locked bool `gorm:"-" json:"locked"`
+}
+
+type FeedAuthor struct {
+	UserID         int64   `gorm:"column:author_id" json:"user_id"`
+	Name           string  `gorm:"column:author_name" json:"name"`
+	Surname        string  `gorm:"column:author_surname" json:"surname"`
+	AvatarFilepath *string `gorm:"column:author_avatar_filepath" json:"-"`
+	AvatarURL      *string `gorm:"-" json:"avatar_url"`
+}
+
+// FeedCursor - позиция в ленте: последнее отданное видео
+// (лента отсортирована по created_at DESC, video_id DESC)
+type FeedCursor struct {
+	CreatedAt time.Time
+	VideoID   int64
+}
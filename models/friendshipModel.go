@@ -26,3 +26,63 @@ type Friendship struct {
 	// created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 	CreatedAt time.Time `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()"`
 }
+
+func (Friendship) TableName() string {
+	return "friendships"
+}
+
+// CanonicalPair упорядочивает пару ID так, как требует CHECK (user_id1 < user_id2).
+func CanonicalPair(a, b int64) (userID1, userID2 int64) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
+
+// PendingStatusFrom возвращает статус заявки, отправленной requesterID
+// в паре (userID1, userID2), где userID1 < userID2.
+func PendingStatusFrom(requesterID, userID1 int64) FriendshipStatus {
+	if requesterID == userID1 {
+		return StatusPending1
+	}
+	return StatusPending2
+}
+
+// FriendUser - пользователь в списках друзей и заявок
+type FriendUser struct {
+	UserID         int64     `gorm:"column:user_id" json:"user_id"`
+	Name           string    `gorm:"column:name" json:"name"`
+	Surname        string    `gorm:"column:surname" json:"surname"`
+	AvatarFilepath *string   `gorm:"column:avatar_filepath" json:"-"`
+	AvatarURL      *string   `gorm:"-" json:"avatar_url,omitempty"`
+	Since          time.Time `gorm:"column:created_at" json:"since"`
+}
+
+// SuggestionDismissal - пользователь скрыл кандидата из рекомендаций друзей
+type SuggestionDismissal struct {
+	UserID          int64     `gorm:"column:user_id;primaryKey;autoIncrement:false"`
+	DismissedUserID int64     `gorm:"column:dismissed_user_id;primaryKey;autoIncrement:false"`
+	CreatedAt       time.Time `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()"`
+}
+
+func (SuggestionDismissal) TableName() string {
+	return "friend_suggestion_dismissals"
+}
+
+type MutualFriend struct {
+	UserID  int64  `json:"user_id"`
+	Name    string `json:"name"`
+	Surname string `json:"surname"`
+}
+
+// FriendSuggestion - кандидат в друзья с общими друзьями
+type FriendSuggestion struct {
+	UserID         int64          `gorm:"column:user_id" json:"user_id"`
+	Name           string         `gorm:"column:name" json:"name"`
+	Surname        string         `gorm:"column:surname" json:"surname"`
+	AvatarFilepath *string        `gorm:"column:avatar_filepath" json:"-"`
+	AvatarURL      *string        `gorm:"-" json:"avatar_url,omitempty"`
+	MutualCount    int            `gorm:"column:mutual_count" json:"mutual_count"`
+	MutualJSON     []byte         `gorm:"column:mutual_friends" json:"-"`
+	MutualFriends  []MutualFriend `gorm:"-" json:"mutual_friends"`
+}
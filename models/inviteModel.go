@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Invite - приглашение пользователя; сам код не хранится, он подписывается
+// из InviteID и ExpiresAt (см. util.SignInviteCode)
+type Invite struct {
+	InviteID  int64      `gorm:"primaryKey;column:invite_id;autoIncrement" json:"invite_id"`
+	InviterID int64      `gorm:"column:inviter_id;type:BIGINT;not null;index" json:"-"`
+	MaxUses   int        `gorm:"column:max_uses;not null" json:"max_uses"`
+	Uses      int        `gorm:"column:uses;not null;default:0" json:"uses"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;type:TIMESTAMPTZ;not null" json:"expires_at"`
+	RevokedAt *time.Time `gorm:"column:revoked_at;type:TIMESTAMPTZ" json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()" json:"created_at"`
+}
+
+func (Invite) TableName() string {
+	return "invites"
+}
+
+// InviteRedemption - атрибуция регистрации: кто кого пригласил.
+// BefriendedAt выставляется после подтверждения email приглашенным.
+type InviteRedemption struct {
+	InviteeID    int64      `gorm:"primaryKey;column:invitee_id;autoIncrement:false"`
+	InviteID     int64      `gorm:"column:invite_id;type:BIGINT;not null;index"`
+	InviterID    int64      `gorm:"column:inviter_id;type:BIGINT;not null;index"`
+	CreatedAt    time.Time  `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()"`
+	BefriendedAt *time.Time `gorm:"column:befriended_at;type:TIMESTAMPTZ"`
+}
+
+func (InviteRedemption) TableName() string {
+	return "invite_redemptions"
+}
+
+// InviteStat - сколько регистраций привел пользователь
+type InviteStat struct {
+	InviterID       int64  `gorm:"column:inviter_id" json:"inviter_id"`
+	Name            string `gorm:"column:name" json:"name"`
+	Surname         string `gorm:"column:surname" json:"surname"`
+	Signups         int64  `gorm:"column:signups" json:"signups"`
+	VerifiedSignups int64  `gorm:"column:verified_signups" json:"verified_signups"`
+}
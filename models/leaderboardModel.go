@@ -0,0 +1,34 @@
+package models
+
+// LeaderboardScope - вид таблицы рейтинга
+type LeaderboardScope string
+
+const (
+	LeaderboardGlobal  LeaderboardScope = "global"  // рейтинг за все время
+	LeaderboardWeekly  LeaderboardScope = "weekly"  // очки рейтинга за текущую ISO-неделю
+	LeaderboardFriends LeaderboardScope = "friends" // рейтинг за все время среди друзей
+)
+
+// IsValid сообщает, является ли значение одним из известных видов рейтинга.
+func (s LeaderboardScope) IsValid() bool {
+	switch s {
+	case LeaderboardGlobal, LeaderboardWeekly, LeaderboardFriends:
+		return true
+	default:
+		return false
+	}
+}
+
+// LeaderboardEntry - строка таблицы рейтинга. При равных очках Rank общий.
+type LeaderboardEntry struct {
+	Rank  int64      `gorm:"column:rank" json:"rank"`
+	Score int64      `gorm:"column:score" json:"score"`
+	User  FeedAuthor `gorm:"embedded" json:"user"`
+}
+
+// LeaderboardCursor - последняя отданная строка
+// (таблица отсортирована по score DESC, user_id ASC)
+type LeaderboardCursor struct {
+	Score  int64
+	UserID int64
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// DailyMoment - окно "момента" на локальный день часового пояса: в
+// случайное время StartsAt пользователи получают уведомление и должны
+// опубликовать видео до Deadline
+type DailyMoment struct {
+	// timezone VARCHAR(64), local_date DATE - PRIMARY KEY
+	Timezone  string    `gorm:"primaryKey;column:timezone;size:64" json:"timezone"`
+	LocalDate time.Time `gorm:"primaryKey;column:local_date;type:DATE" json:"-"`
+
+	StartsAt time.Time `gorm:"column:starts_at;type:TIMESTAMPTZ;not null" json:"window_start"`
+	Deadline time.Time `gorm:"column:deadline;type:TIMESTAMPTZ;not null" json:"deadline"`
+
+	// notified_at - когда разослано уведомление (не более одного раза)
+	NotifiedAt *time.Time `gorm:"column:notified_at;type:TIMESTAMPTZ" json:"-"`
+	CreatedAt  time.Time  `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()" json:"-"`
+}
+
+func (DailyMoment) TableName() string {
+	return "daily_moments"
+}
+
+// Date - локальная дата момента в формате YYYY-MM-DD
+func (m DailyMoment) Date() string {
+	return m.LocalDate.Format("2006-01-02")
+}
+
+// IsOnTime - попадает ли t в окно момента
+func (m DailyMoment) IsOnTime(t time.Time) bool {
+	return !t.Before(m.StartsAt) && !t.After(m.Deadline)
+}
+
+// MomentUpload - сколько раз автор загружал момент за локальный день,
+// включая удаленные видео
+type MomentUpload struct {
+	AuthorID   int64     `gorm:"primaryKey;column:author_id;autoIncrement:false"`
+	MomentDate time.Time `gorm:"primaryKey;column:moment_date;type:DATE"`
+	Uploads    int       `gorm:"column:uploads;not null"`
+}
+
+func (MomentUpload) TableName() string {
+	return "moment_uploads"
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PasswordReset - одноразовый код сброса пароля; хранится только хеш.
+type PasswordReset struct {
+	Email     string    `gorm:"primaryKey;size:255"`
+	TokenHash string    `gorm:"column:token_hash;size:64;not null"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;default:now()"`
+}
+
+func (PasswordReset) TableName() string {
+	return "password_resets"
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// RatingEventKind - за что начислены очки рейтинга
+type RatingEventKind string
+
+const (
+	RatingPost     RatingEventKind = "post"     // публикация момента
+	RatingStreak   RatingEventKind = "streak"   // рубеж серии
+	RatingReaction RatingEventKind = "reaction" // реакция на видео (или ее снятие, с минусом)
+)
+
+// RatingEvent - запись журнала рейтинга. Журнал только дополняется (UPDATE
+// и DELETE отклоняет триггер trg_rating_events_append_only, кроме каскада
+// при удалении пользователя): users.rating - сумма очков с затуханием, его
+// можно пересчитать из журнала.
+type RatingEvent struct {
+	// event_id BIGSERIAL PRIMARY KEY
+	EventID int64 `gorm:"primaryKey;column:event_id;autoIncrement" json:"event_id"`
+
+	// user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE
+	UserID int64           `gorm:"column:user_id;not null" json:"user_id"`
+	Kind   RatingEventKind `gorm:"column:kind;type:VARCHAR(16);not null" json:"kind"`
+	Points int             `gorm:"column:points;not null" json:"points"`
+
+	// video_id и actor_id без внешних ключей: запись остается после удаления видео
+	VideoID *int64 `gorm:"column:video_id" json:"video_id,omitempty"`
+	ActorID *int64 `gorm:"column:actor_id" json:"actor_id,omitempty"`
+	// moment_date - локальный день публикации; очки за публикацию даются
+	// один раз на (user_id, moment_date)
+	MomentDate *time.Time `gorm:"column:moment_date;type:DATE" json:"-"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()" json:"created_at"`
+}
+
+func (RatingEvent) TableName() string {
+	return "rating_events"
+}
+
+// RatingBucket - сумма очков пользователя за час журнала; из корзин
+// считается рейтинг с затуханием
+type RatingBucket struct {
+	UserID int64     `gorm:"column:user_id"`
+	Hour   time.Time `gorm:"column:hour"`
+	Points int       `gorm:"column:points"`
+}
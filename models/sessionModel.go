@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Session - семейство refresh-токенов одного устройства.
+// ID сессии совпадает с family ID: все токены, полученные ротацией
+// из одного логина, ссылаются на одну сессию.
+type Session struct {
+	ID         string     `gorm:"primaryKey;column:session_id;size:32" json:"session_id"`
+	UserID     uint64     `gorm:"column:user_id;not null;index" json:"-"`
+	UserAgent  string     `gorm:"column:user_agent;size:255;not null;default:''" json:"user_agent"`
+	IP         string     `gorm:"column:ip;size:64;not null;default:''" json:"ip"`
+	CreatedAt  time.Time  `gorm:"column:created_at;not null;default:now()" json:"created_at"`
+	LastUsedAt time.Time  `gorm:"column:last_used_at;not null;default:now()" json:"last_used_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"-"`
+}
+
+func (Session) TableName() string {
+	return "auth_sessions"
+}
+
+// RefreshToken хранит только SHA-256 от выданного токена.
+// UsedAt выставляется при ротации; повторное предъявление
+// использованного токена означает его кражу.
+type RefreshToken struct {
+	ID        uint64     `gorm:"primaryKey;column:refresh_token_id"`
+	SessionID string     `gorm:"column:session_id;size:32;not null;index"`
+	TokenHash string     `gorm:"column:token_hash;size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time  `gorm:"column:created_at;not null;default:now()"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
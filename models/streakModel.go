@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// StreakDayKind - чем засчитан день серии
+type StreakDayKind string
+
+const (
+	StreakPosted StreakDayKind = "posted"
+	StreakFrozen StreakDayKind = "frozen" // потрачена заморозка
+)
+
+// StreakDay - засчитанный локальный день серии пользователя (история серии)
+type StreakDay struct {
+	// user_id BIGINT, local_date DATE - PRIMARY KEY
+	UserID    int64     `gorm:"primaryKey;column:user_id" json:"-"`
+	LocalDate time.Time `gorm:"primaryKey;column:local_date;type:DATE" json:"-"`
+
+	// kind VARCHAR(8) NOT NULL CHECK (kind IN ('posted', 'frozen'))
+	Kind      StreakDayKind `gorm:"column:kind;type:VARCHAR(8);not null" json:"kind"`
+	CreatedAt time.Time     `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()" json:"-"`
+}
+
+func (StreakDay) TableName() string {
+	return "streak_days"
+}
+
+// Date - локальная дата дня в формате YYYY-MM-DD
+func (d StreakDay) Date() string {
+	return d.LocalDate.Format("2006-01-02")
+}
+
+// StreakState - поля серии из users
+type StreakState struct {
+	UserID         int64      `gorm:"column:user_id"`
+	CurrentStreak  int        `gorm:"column:current_streak"`
+	MaxStreak      int        `gorm:"column:max_streak"`
+	StreakFreezes  int        `gorm:"column:streak_freezes"`
+	LastStreakDate *time.Time `gorm:"column:last_streak_date"`
+	Timezone       string     `gorm:"column:timezone"`
+
+	// LastKind - чем засчитан LastStreakDate (из streak_days)
+	LastKind StreakDayKind `gorm:"-"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultTimezone - часовой пояс пользователей, которые его не указали
+const DefaultTimezone = "UTC"
+
+var ErrInvalidTimezone = errors.New("timezone must be an IANA name, e.g. Europe/Minsk")
+
+// LoadTimezone проверяет IANA-имя часового пояса. Пустая строка - UTC;
+// "Local" запрещен, так как зависит от настроек сервера.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		name = DefaultTimezone
+	}
+	if name == "Local" || len(name) > 64 {
+		return nil, ErrInvalidTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+// LocalDay - границы [start, end) календарного дня, в который попадает t,
+// в поясе loc. День может длиться 23 или 25 часов при переводе часов.
+func LocalDay(t time.Time, loc *time.Location) (start, end time.Time) {
+	t = t.In(loc)
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// CalendarDate - дата t в ее поясе как полночь UTC: так хранятся колонки DATE
+// (daily_moments.local_date, videos.moment_date, streak_days.local_date)
+func CalendarDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
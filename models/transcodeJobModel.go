@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// TranscodeJob - задача перекодирования видео в HLS. Очередь живет в
+// Postgres: воркеры забирают задачи через FOR UPDATE SKIP LOCKED.
+type TranscodeJob struct {
+	// job_id BIGSERIAL PRIMARY KEY
+	JobID int64 `gorm:"primaryKey;column:job_id;autoIncrement"`
+
+	// video_id BIGINT UNIQUE REFERENCES videos(video_id) ON DELETE CASCADE
+	VideoID int64 `gorm:"column:video_id;not null;uniqueIndex"`
+
+	Status      TranscodeJobStatus `gorm:"column:status;type:VARCHAR(16);not null;default:'pending'"`
+	Attempts    int                `gorm:"column:attempts;not null;default:0"`
+	MaxAttempts int                `gorm:"column:max_attempts;not null;default:5"`
+
+	// run_at - не раньше какого момента задачу можно взять (backoff после ошибки)
+	RunAt time.Time `gorm:"column:run_at;type:TIMESTAMPTZ;not null;default:now()"`
+
+	// locked_at/locked_by - кто и когда взял задачу; просроченную аренду забирает другой воркер
+	LockedAt *time.Time `gorm:"column:locked_at;type:TIMESTAMPTZ"`
+	LockedBy string     `gorm:"column:locked_by;type:VARCHAR(64);not null;default:''"`
+
+	LastError string `gorm:"column:last_error;type:TEXT;not null;default:''"`
+
+	CreatedAt time.Time `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()"`
+	UpdatedAt time.Time `gorm:"column:updated_at;type:TIMESTAMPTZ;not null;default:now()"`
+}
+
+type TranscodeJobStatus string
+
+const (
+	JobPending TranscodeJobStatus = "pending"
+	JobRunning TranscodeJobStatus = "running"
+	JobDone    TranscodeJobStatus = "done"
+	JobFailed  TranscodeJobStatus = "failed"
+)
+
+func (TranscodeJob) TableName() string {
+	return "transcode_jobs"
+}
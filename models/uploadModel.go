@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Upload - незавершенная resumable-загрузка видео (tus).
+// Принятые байты лежат во временном файле, Offset - сколько из них подтверждено.
+type Upload struct {
+	// upload_id VARCHAR(32) PRIMARY KEY
+	UploadID string `gorm:"primaryKey;column:upload_id;size:32"`
+
+	// author_id BIGINT REFERENCES users(user_id) ON DELETE CASCADE
+	AuthorID int64 `gorm:"column:author_id;type:BIGINT;not null;index"`
+
+	// upload_length BIGINT NOT NULL - полный размер файла (Upload-Length)
+	Length int64 `gorm:"column:upload_length;not null"`
+
+	// upload_offset BIGINT NOT NULL DEFAULT 0 (Upload-Offset)
+	Offset int64 `gorm:"column:upload_offset;not null;default:0"`
+
+	Description string `gorm:"column:description;type:VARCHAR(70);not null;default:''"`
+
+	// video_id выставляется, когда загрузка опубликована как видео
+	VideoID *int64 `gorm:"column:video_id"`
+
+	ExpiresAt time.Time `gorm:"column:expires_at;type:TIMESTAMPTZ;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()"`
+}
+
+func (Upload) TableName() string {
+	return "uploads"
+}
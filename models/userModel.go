@@ -2,19 +2,42 @@ package models
 
 import "time"
 
+// Role определяет права пользователя (см. usecase.drawio: пользователь, модератор, администратор)
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// IsValid сообщает, является ли значение одной из известных ролей.
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleUser, RoleModerator, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
 type User struct {
     ID    	       uint64    `gorm:"primaryKey;column:user_id"`
 	Name           string    `gorm:"size:50;not null;unique;default:''"`
 	Surname        string    `gorm:"size:50;not null;unique;default:''"`
     Email          string    `gorm:"size:255;not null;unique"`
 	Verified   	   bool	     `gorm:"not null;defaul:'false"`
-	Password       string    `gorm:"not null"`
+	Password       string    `gorm:"not null" json:"-"`
+	Role           Role      `gorm:"column:role;type:VARCHAR(16);not null;default:'user'"`
     Rating         int       `gorm:"not null;default:0;check:rating >= 0"`
     MaxStreak      int       `gorm:"not null;default:0;check:max_streak >= 0"`
     CurrentStreak  int       `gorm:"not null;default:0;check:current_streak >= 0"`
+	StreakFreezes  int       `gorm:"column:streak_freezes;not null;default:0;check:streak_freezes >= 0"`
+	LastStreakDate *time.Time `gorm:"column:last_streak_date;type:DATE"` // последний засчитанный день, см. StreakDay
 	MaxReactions   int       `gorm:"not null;default:0;check:max_reactions >= 0;"`
     AvatarFilepath *string   `gorm:"column:avatar_filepath"` // nullable
     Bio            string    `gorm:"size:100;not null;default:''"`
+	Timezone       string    `gorm:"column:timezone;size:64;not null;default:'UTC'"` // IANA, см. LoadTimezone
     CreatedAt      time.Time `gorm:"column:created_at;not null;default:now()"`
 }
 
@@ -9,18 +9,67 @@ type Video struct {
 	VideoID int64 `gorm:"primaryKey;column:video_id;primaryKey;autoIncrement"`
 
 	// filepath TEXT NOT NULL
-	Filepath string `gorm:"column:filepath;type:TEXT;not null"`
+	// ключ в хранилище, наружу не отдается (см. StreamURL)
+	Filepath string `gorm:"column:filepath;type:TEXT;not null" json:"-"`
 
 	// author_id BIGINT REFERENCES users(user_id) ON DELETE CASCADE
-	AuthorID int64 `gorm:"column:author_id;type:BIGINT;not null"`
+	AuthorID int64 `gorm:"column:author_id;type:BIGINT;not null;index:idx_videos_author_created,priority:1;uniqueIndex:idx_videos_author_moment,priority:1"`
 
 	// description VARCHAR(70) NOT NULL DEFAULT ''
 	Description string `gorm:"column:description;type:VARCHAR(70);not null;default:''"`
 
+	// Метаданные, извлеченные из файла при загрузке (см. пакет media)
+	MimeType   string `gorm:"column:mime_type;type:VARCHAR(32);not null;default:'video/mp4'" json:"mime_type"`
+	DurationMs int64  `gorm:"column:duration_ms;not null;default:0" json:"duration_ms"`
+	Width      int    `gorm:"column:width;not null;default:0" json:"width"`
+	Height     int    `gorm:"column:height;not null;default:0" json:"height"`
+	Rotation   int    `gorm:"column:rotation;not null;default:0" json:"rotation"` // градусы по часовой стрелке
+	Codec      string `gorm:"column:codec;type:VARCHAR(8);not null;default:''" json:"codec"`
+
+	// status VARCHAR(16) NOT NULL DEFAULT 'ready'
+	// В ленту попадают только ready, см. TranscodeJob
+	Status VideoStatus `gorm:"column:status;type:VARCHAR(16);not null;default:'ready'" json:"status"`
+
+	// playlist_key TEXT - ключ master-плейлиста HLS, пока нет перекодирования - nil
+	PlaylistKey *string `gorm:"column:playlist_key;type:TEXT" json:"-"`
+
+	// Постер (JPEG) и анимированное превью (GIF) создаются вместе с HLS
+	PosterKey  *string `gorm:"column:poster_key;type:TEXT" json:"-"`
+	PreviewKey *string `gorm:"column:preview_key;type:TEXT" json:"-"`
+	// blurhash VARCHAR(64) NOT NULL DEFAULT '' - заглушка, пока грузится постер
+	BlurHash string `gorm:"column:blurhash;type:VARCHAR(64);not null;default:''" json:"blurhash"`
+
+	// moment_date DATE - локальный день автора, к моменту которого относится видео
+	// UNIQUE (author_id, moment_date): один момент на пользователя в день
+	MomentDate *time.Time `gorm:"column:moment_date;type:DATE;uniqueIndex:idx_videos_author_moment,priority:2" json:"-"`
+	// posted_late - опубликовано вне окна момента (после дедлайна или до уведомления)
+	PostedLate bool `gorm:"column:posted_late;not null;default:false" json:"posted_late"`
+	// retake_count - сколько загрузок момента этого дня было до этого видео
+	// (включая удаленные)
+	RetakeCount int `gorm:"column:retake_count;not null;default:0" json:"retake_count"`
+
 	// created_at TIMESTAMPTZ NOT NULL DEFAULT now()
-	CreatedAt time.Time `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now()"`
+	CreatedAt time.Time `gorm:"column:created_at;type:TIMESTAMPTZ;not null;default:now();index:idx_videos_author_created,priority:2"`
+
+	// StreamURL заполняется контроллером: короткоживущая подписанная ссылка на файл
+	StreamURL  string  `gorm:"-" json:"stream_url"`
+	PosterURL  *string `gorm:"-" json:"poster_url"`
+	PreviewURL *string `gorm:"-" json:"preview_url"`
+
+	// Locked - заглушка "опубликуй, чтобы увидеть": заполнены только
+	// автор, время и BlurHash
+	Locked bool `gorm:"-" json:"locked"`
 }
 
+// VideoStatus - состояние обработки загруженного видео
+type VideoStatus string
+
+const (
+	VideoProcessing VideoStatus = "processing"
+	VideoReady      VideoStatus = "ready"
+	VideoFailed     VideoStatus = "failed"
+)
+
 func (Video) TableName() string {
 	return "videos"
 }
@@ -0,0 +1,44 @@
+// Package push доставляет пользователям уведомления (push, WebSocket).
+// Реальные провайдеры (APNs) подключаются реализацией Notifier.
+package push
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// Notification - событие для клиента; Type определяет экран в приложении
+type Notification struct {
+	Type  string                 `json:"type"`
+	Title string                 `json:"title"`
+	Body  string                 `json:"body"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+}
+
+// Notifier отправляет уведомление пользователям userIDs
+type Notifier interface {
+	Notify(ctx context.Context, userIDs []int64, n Notification) error
+}
+
+// LogNotifier только пишет уведомления в лог - драйвер по умолчанию,
+// пока не настроен провайдер push-уведомлений
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, userIDs []int64, n Notification) error {
+	log.Printf("INFO: Push %q to %d users: %s", n.Type, len(userIDs), n.Title)
+	return nil
+}
+
+// Multi рассылает уведомление через все notifiers; ошибка одного не мешает остальным
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, userIDs []int64, n Notification) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, userIDs, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
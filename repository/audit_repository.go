@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+)
+
+// AuditRepository сохраняет записи аудита модерации (только добавление)
+type AuditRepository interface {
+	Create(ctx context.Context, entry *models.AuditLog) error
+}
+
+type auditRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepositoryImpl{DB: db}
+}
+
+func (r *auditRepositoryImpl) Create(ctx context.Context, entry *models.AuditLog) error {
+	return r.DB.WithContext(ctx).Create(entry).Error
+}
@@ -10,7 +10,8 @@ import (
 
 type CommentRepository interface {
 	Create(ctx context.Context, comment *models.Comment) error
-	GetByVideoID(ctx context.Context, videoID int64) ([]*models.Comment, error)
+	// GetByVideoID возвращает комментарии без авторов, связанных с viewerID блокировкой
+	GetByVideoID(ctx context.Context, videoID int64, viewerID int64) ([]*models.Comment, error)
 }
 
 type commentRepositoryImpl struct {
@@ -36,13 +37,15 @@ func (r *commentRepositoryImpl) Create(ctx context.Context, comment *models.Comm
 	return nil
 }
 
-func (r *commentRepositoryImpl) GetByVideoID(ctx context.Context, videoID int64) ([]*models.Comment, error) {
+func (r *commentRepositoryImpl) GetByVideoID(ctx context.Context, videoID int64, viewerID int64) ([]*models.Comment, error) {
 	var comments []*models.Comment
 
+	notBlocked, args := NotBlockedWith("comments.user_id", viewerID)
 	// Добавляем Preload сюда тоже, чтобы при получении списка были никнеймы
 	err := r.DB.WithContext(ctx).
 		Preload("User").
 		Where("video_id = ?", videoID).
+		Where(notBlocked, args...).
 		Find(&comments).Error
 
 	if err != nil {
@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FriendshipRepository работает с таблицей friendships.
+// Все методы принимают пару ID в любом порядке и сами приводят ее
+// к каноническому виду (user_id1 < user_id2).
+type FriendshipRepository interface {
+	UserExists(ctx context.Context, userID int64) (bool, error)
+	GetFriendship(ctx context.Context, a, b int64) (*models.Friendship, error)
+	// CreateFriendship вставляет строку, если для пары еще нет записи
+	CreateFriendship(ctx context.Context, a, b int64, status models.FriendshipStatus) (created bool, err error)
+	// UpdateStatus меняет статус, только если текущий статус равен from
+	UpdateStatus(ctx context.Context, a, b int64, from, to models.FriendshipStatus) (rowsAffected int64, err error)
+	// DeleteFriendship удаляет строку, только если ее статус равен status
+	DeleteFriendship(ctx context.Context, a, b int64, status models.FriendshipStatus) (rowsAffected int64, err error)
+	GetIncomingRequests(ctx context.Context, userID int64) ([]models.FriendUser, error)
+	GetOutgoingRequests(ctx context.Context, userID int64) ([]models.FriendUser, error)
+	GetFriends(ctx context.Context, userID int64, limit, offset int) ([]models.FriendUser, int64, error)
+
+	// TransitionPair под блокировкой строки передает текущий статус пары (nil - записи нет)
+	// в fn и сохраняет результат: nil удаляет запись
+	TransitionPair(ctx context.Context, a, b int64, fn func(current *models.FriendshipStatus) (*models.FriendshipStatus, error)) error
+	// IsBlocked - есть ли блокировка в паре в любую сторону
+	IsBlocked(ctx context.Context, a, b int64) (bool, error)
+	// HasBlockWithVideoAuthor - есть ли блокировка между userID и автором видео
+	HasBlockWithVideoAuthor(ctx context.Context, userID, videoID int64) (bool, error)
+	// GetBlockRelatedIDs - все, кто заблокировал userID или заблокирован им
+	GetBlockRelatedIDs(ctx context.Context, userID int64) ([]int64, error)
+	// GetBlockedUsers - пользователи, заблокированные userID
+	GetBlockedUsers(ctx context.Context, userID int64) ([]models.FriendUser, error)
+
+	// GetFriendSuggestions - друзья друзей, отсортированные по числу общих друзей
+	GetFriendSuggestions(ctx context.Context, userID int64, limit int) ([]models.FriendSuggestion, error)
+	DismissSuggestion(ctx context.Context, userID, dismissedUserID int64) error
+}
+
+// BlockedStatuses - статусы пары, при которых пользователи не взаимодействуют
+var BlockedStatuses = []models.FriendshipStatus{models.StatusBlocked1, models.StatusBlocked2, models.StatusBlocked12}
+
+// NotBlockedWith возвращает условие "между column и viewerID нет блокировки"
+// для фильтрации списков (реакции, комментарии) с точки зрения viewerID.
+func NotBlockedWith(column string, viewerID int64) (string, []interface{}) {
+	query := "NOT EXISTS (SELECT 1 FROM friendships bf WHERE bf.user_id1 = LEAST(" + column + ", ?) " +
+		"AND bf.user_id2 = GREATEST(" + column + ", ?) AND bf.status IN ?)"
+	return query, []interface{}{viewerID, viewerID, BlockedStatuses}
+}
+
+type friendshipRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewFriendshipRepository(db *gorm.DB) FriendshipRepository {
+	return &friendshipRepositoryImpl{DB: db}
+}
+
+func (r *friendshipRepositoryImpl) UserExists(ctx context.Context, userID int64) (bool, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ?", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *friendshipRepositoryImpl) GetFriendship(ctx context.Context, a, b int64) (*models.Friendship, error) {
+	userID1, userID2 := models.CanonicalPair(a, b)
+	var friendship models.Friendship
+	err := r.DB.WithContext(ctx).
+		Where("user_id1 = ? AND user_id2 = ?", userID1, userID2).
+		First(&friendship).Error
+	if err != nil {
+		return nil, err
+	}
+	return &friendship, nil
+}
+
+func (r *friendshipRepositoryImpl) CreateFriendship(ctx context.Context, a, b int64, status models.FriendshipStatus) (bool, error) {
+	userID1, userID2 := models.CanonicalPair(a, b)
+	friendship := models.Friendship{
+		UserID1: userID1,
+		UserID2: userID2,
+		Status:  status,
+	}
+	result := r.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&friendship)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *friendshipRepositoryImpl) UpdateStatus(ctx context.Context, a, b int64, from, to models.FriendshipStatus) (int64, error) {
+	userID1, userID2 := models.CanonicalPair(a, b)
+	result := r.DB.WithContext(ctx).Model(&models.Friendship{}).
+		Where("user_id1 = ? AND user_id2 = ? AND status = ?", userID1, userID2, from).
+		Updates(map[string]interface{}{
+			"status":     to,
+			"created_at": gorm.Expr("now()"),
+		})
+	return result.RowsAffected, result.Error
+}
+
+func (r *friendshipRepositoryImpl) DeleteFriendship(ctx context.Context, a, b int64, status models.FriendshipStatus) (int64, error) {
+	userID1, userID2 := models.CanonicalPair(a, b)
+	result := r.DB.WithContext(ctx).
+		Where("user_id1 = ? AND user_id2 = ? AND status = ?", userID1, userID2, status).
+		Delete(&models.Friendship{})
+	return result.RowsAffected, result.Error
+}
+
+// Входящие заявки: userID стоит вторым в паре со статусом pending1
+// или первым со статусом pending2
+func (r *friendshipRepositoryImpl) GetIncomingRequests(ctx context.Context, userID int64) ([]models.FriendUser, error) {
+	return r.findCounterparts(ctx, userID,
+		"(f.user_id2 = @user AND f.status = 'pending1') OR (f.user_id1 = @user AND f.status = 'pending2')")
+}
+
+func (r *friendshipRepositoryImpl) GetOutgoingRequests(ctx context.Context, userID int64) ([]models.FriendUser, error) {
+	return r.findCounterparts(ctx, userID,
+		"(f.user_id1 = @user AND f.status = 'pending1') OR (f.user_id2 = @user AND f.status = 'pending2')")
+}
+
+func (r *friendshipRepositoryImpl) GetFriends(ctx context.Context, userID int64, limit, offset int) ([]models.FriendUser, int64, error) {
+	var total int64
+	err := r.DB.WithContext(ctx).Model(&models.Friendship{}).
+		Where("status = ?", models.StatusFriends).
+		Where("user_id1 = ? OR user_id2 = ?", userID, userID).
+		Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var friends []models.FriendUser
+	err = r.counterpartQuery(ctx, userID).
+		Where("f.status = ?", models.StatusFriends).
+		Where("f.user_id1 = @user OR f.user_id2 = @user", map[string]interface{}{"user": userID}).
+		Order("u.name, u.surname, u.user_id").
+		Limit(limit).
+		Offset(offset).
+		Scan(&friends).Error
+	return friends, total, err
+}
+
+func (r *friendshipRepositoryImpl) findCounterparts(ctx context.Context, userID int64, condition string) ([]models.FriendUser, error) {
+	var users []models.FriendUser
+	err := r.counterpartQuery(ctx, userID).
+		Where(condition, map[string]interface{}{"user": userID}).
+		Order("f.created_at DESC").
+		Scan(&users).Error
+	return users, err
+}
+
+// counterpartQuery выбирает "второго" пользователя пары вместе с датой записи
+func (r *friendshipRepositoryImpl) counterpartQuery(ctx context.Context, userID int64) *gorm.DB {
+	return r.DB.WithContext(ctx).
+		Table("friendships AS f").
+		Select("u.user_id, u.name, u.surname, u.avatar_filepath, f.created_at").
+		Joins("JOIN users u ON u.user_id = CASE WHEN f.user_id1 = ? THEN f.user_id2 ELSE f.user_id1 END", userID)
+}
+
+func (r *friendshipRepositoryImpl) TransitionPair(ctx context.Context, a, b int64, fn func(current *models.FriendshipStatus) (*models.FriendshipStatus, error)) error {
+	userID1, userID2 := models.CanonicalPair(a, b)
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var friendship models.Friendship
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id1 = ? AND user_id2 = ?", userID1, userID2).
+			First(&friendship).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var current *models.FriendshipStatus
+		if err == nil {
+			current = &friendship.Status
+		}
+		next, err := fn(current)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case current == nil && next == nil:
+			return nil
+		case current == nil:
+			return tx.Create(&models.Friendship{UserID1: userID1, UserID2: userID2, Status: *next}).Error
+		case next == nil:
+			return tx.Where("user_id1 = ? AND user_id2 = ?", userID1, userID2).
+				Delete(&models.Friendship{}).Error
+		case *next == *current:
+			return nil
+		default:
+			return tx.Model(&models.Friendship{}).
+				Where("user_id1 = ? AND user_id2 = ?", userID1, userID2).
+				Updates(map[string]interface{}{"status": *next, "created_at": gorm.Expr("now()")}).Error
+		}
+	})
+}
+
+func (r *friendshipRepositoryImpl) IsBlocked(ctx context.Context, a, b int64) (bool, error) {
+	userID1, userID2 := models.CanonicalPair(a, b)
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&models.Friendship{}).
+		Where("user_id1 = ? AND user_id2 = ? AND status IN ?", userID1, userID2, BlockedStatuses).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *friendshipRepositoryImpl) HasBlockWithVideoAuthor(ctx context.Context, userID, videoID int64) (bool, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).
+		Table("videos AS v").
+		Joins("JOIN friendships f ON f.user_id1 = LEAST(v.author_id, ?) AND f.user_id2 = GREATEST(v.author_id, ?)", userID, userID).
+		Where("v.video_id = ? AND f.status IN ?", videoID, BlockedStatuses).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *friendshipRepositoryImpl) GetBlockRelatedIDs(ctx context.Context, userID int64) ([]int64, error) {
+	var ids []int64
+	err := r.DB.WithContext(ctx).Model(&models.Friendship{}).
+		Select("CASE WHEN user_id1 = ? THEN user_id2 ELSE user_id1 END", userID).
+		Where("(user_id1 = ? OR user_id2 = ?) AND status IN ?", userID, userID, BlockedStatuses).
+		Scan(&ids).Error
+	return ids, err
+}
+
+// Заблокированные пользователем: он первый в паре и blocked1/blocked12,
+// либо второй и blocked2/blocked12
+func (r *friendshipRepositoryImpl) GetBlockedUsers(ctx context.Context, userID int64) ([]models.FriendUser, error) {
+	return r.findCounterparts(ctx, userID,
+		"(f.user_id1 = @user AND f.status IN ('blocked1', 'blocked12')) OR (f.user_id2 = @user AND f.status IN ('blocked2', 'blocked12'))")
+}
+
+// Кандидаты - друзья друзей, у которых с userID нет никакой записи в friendships
+// (ни дружбы, ни заявки, ни блокировки) и которых userID не скрывал.
+// Считается одним запросом по таблице friendships.
+const friendSuggestionsQuery = `
+WITH my_friends AS (
+    SELECT CASE WHEN user_id1 = @user THEN user_id2 ELSE user_id1 END AS friend_id
+    FROM friendships
+    WHERE status = 'friends' AND (user_id1 = @user OR user_id2 = @user)
+),
+candidates AS (
+    SELECT CASE WHEN f.user_id1 = mf.friend_id THEN f.user_id2 ELSE f.user_id1 END AS candidate_id,
+           mf.friend_id AS mutual_id
+    FROM my_friends mf
+    JOIN friendships f ON f.status = 'friends'
+        AND (f.user_id1 = mf.friend_id OR f.user_id2 = mf.friend_id)
+)
+SELECT u.user_id, u.name, u.surname, u.avatar_filepath,
+       COUNT(*) AS mutual_count,
+       json_agg(json_build_object('user_id', m.user_id, 'name', m.name, 'surname', m.surname)
+                ORDER BY m.name, m.surname, m.user_id) AS mutual_friends
+FROM candidates c
+JOIN users u ON u.user_id = c.candidate_id
+JOIN users m ON m.user_id = c.mutual_id
+WHERE c.candidate_id <> @user
+  AND NOT EXISTS (
+      SELECT 1 FROM friendships x
+      WHERE x.user_id1 = LEAST(c.candidate_id, @user) AND x.user_id2 = GREATEST(c.candidate_id, @user)
+  )
+  AND NOT EXISTS (
+      SELECT 1 FROM friend_suggestion_dismissals d
+      WHERE d.user_id = @user AND d.dismissed_user_id = c.candidate_id
+  )
+GROUP BY u.user_id, u.name, u.surname, u.avatar_filepath
+ORDER BY mutual_count DESC, u.user_id
+LIMIT @limit`
+
+func (r *friendshipRepositoryImpl) GetFriendSuggestions(ctx context.Context, userID int64, limit int) ([]models.FriendSuggestion, error) {
+	var suggestions []models.FriendSuggestion
+	err := r.DB.WithContext(ctx).
+		Raw(friendSuggestionsQuery, map[string]interface{}{"user": userID, "limit": limit}).
+		Scan(&suggestions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range suggestions {
+		if err := json.Unmarshal(suggestions[i].MutualJSON, &suggestions[i].MutualFriends); err != nil {
+			return nil, err
+		}
+	}
+	return suggestions, nil
+}
+
+func (r *friendshipRepositoryImpl) DismissSuggestion(ctx context.Context, userID, dismissedUserID int64) error {
+	dismissal := models.SuggestionDismissal{UserID: userID, DismissedUserID: dismissedUserID}
+	return r.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&dismissal).Error
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+)
+
+// LeaderboardRepository читает материализованные рейтинги
+// (leaderboard_all_time, leaderboard_weekly)
+type LeaderboardRepository interface {
+	// List - страница рейтинга после after вместе с пользователями
+	List(ctx context.Context, scope models.LeaderboardScope, viewerID int64, after *models.LeaderboardCursor, limit int) ([]models.LeaderboardEntry, error)
+	// Rank - строка viewerID в рейтинге или ErrRecordNotFound, если его там нет
+	Rank(ctx context.Context, scope models.LeaderboardScope, viewerID int64) (*models.LeaderboardEntry, error)
+	// Refresh пересчитывает рейтинги, не блокируя чтение
+	Refresh(ctx context.Context) error
+}
+
+type leaderboardRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewLeaderboardRepository(db *gorm.DB) LeaderboardRepository {
+	return &leaderboardRepositoryImpl{DB: db}
+}
+
+const leaderboardColumns = `l.rank, l.score,
+	l.user_id AS author_id, u.name AS author_name, u.surname AS author_surname, u.avatar_filepath AS author_avatar_filepath`
+
+// ranked - строки рейтинга scope (user_id, score, rank). Среди друзей места
+// пересчитываются на лету: друзей мало, а общий rank для них не подходит.
+func (r *leaderboardRepositoryImpl) ranked(db *gorm.DB, scope models.LeaderboardScope, viewerID int64) (*gorm.DB, error) {
+	switch scope {
+	case models.LeaderboardGlobal:
+		return db.Table("leaderboard_all_time AS l"), nil
+	case models.LeaderboardWeekly:
+		return db.Table("leaderboard_weekly AS l"), nil
+	case models.LeaderboardFriends:
+		friends := db.Table("leaderboard_all_time").
+			Select("user_id, score, RANK() OVER (ORDER BY score DESC) AS rank").
+			Where(`user_id = ? OR user_id IN (
+				SELECT CASE WHEN f.user_id1 = ? THEN f.user_id2 ELSE f.user_id1 END
+				FROM friendships f
+				WHERE f.status = ? AND (f.user_id1 = ? OR f.user_id2 = ?))`,
+				viewerID, viewerID, models.StatusFriends, viewerID, viewerID)
+		return db.Table("(?) AS l", friends), nil
+	default:
+		return nil, fmt.Errorf("unknown leaderboard scope %q", scope)
+	}
+}
+
+func (r *leaderboardRepositoryImpl) List(ctx context.Context, scope models.LeaderboardScope, viewerID int64, after *models.LeaderboardCursor, limit int) ([]models.LeaderboardEntry, error) {
+	db := r.DB.WithContext(ctx)
+	query, err := r.ranked(db, scope, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	query = query.Select(leaderboardColumns).Joins("JOIN users u ON u.user_id = l.user_id")
+	if after != nil {
+		// keyset по (score DESC, user_id ASC), индекс idx_leaderboard_*_keyset
+		query = query.Where("l.score < ? OR (l.score = ? AND l.user_id > ?)", after.Score, after.Score, after.UserID)
+	}
+	var entries []models.LeaderboardEntry
+	err = query.Order("l.score DESC, l.user_id ASC").Limit(limit).Scan(&entries).Error
+	return entries, err
+}
+
+func (r *leaderboardRepositoryImpl) Rank(ctx context.Context, scope models.LeaderboardScope, viewerID int64) (*models.LeaderboardEntry, error) {
+	db := r.DB.WithContext(ctx)
+	query, err := r.ranked(db, scope, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	var entries []models.LeaderboardEntry
+	err = query.Select(leaderboardColumns).
+		Joins("JOIN users u ON u.user_id = l.user_id").
+		Where("l.user_id = ?", viewerID).
+		Limit(1).
+		Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return &entries[0], nil
+}
+
+func (r *leaderboardRepositoryImpl) Refresh(ctx context.Context) error {
+	db := r.DB.WithContext(ctx)
+	for _, view := range []string{"leaderboard_all_time", "leaderboard_weekly"} {
+		if err := db.Exec("REFRESH MATERIALIZED VIEW CONCURRENTLY " + view).Error; err != nil {
+			return fmt.Errorf("refresh %s: %w", view, err)
+		}
+	}
+	return nil
+}
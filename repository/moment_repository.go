@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MomentRepository хранит окна "момента дня" по часовым поясам
+type MomentRepository interface {
+	// Get - момент пояса на локальную дату или ErrRecordNotFound
+	Get(ctx context.Context, timezone string, localDate time.Time) (*models.DailyMoment, error)
+	// CreateIfMissing сохраняет момент, если для этого дня его еще нет, и
+	// возвращает сохраненный: при гонке нескольких экземпляров побеждает первый
+	CreateIfMissing(ctx context.Context, moment *models.DailyMoment) (*models.DailyMoment, error)
+	// ClaimDue помечает разосланными моменты, окно которых открыто в now, и
+	// возвращает их. Каждый момент возвращается ровно один раз.
+	ClaimDue(ctx context.Context, now time.Time) ([]models.DailyMoment, error)
+	// ListTimezones - часовые пояса, в которых есть пользователи
+	ListTimezones(ctx context.Context) ([]string, error)
+	ListUserIDsByTimezone(ctx context.Context, timezone string) ([]int64, error)
+	GetUserTimezone(ctx context.Context, userID int64) (string, error)
+}
+
+type momentRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewMomentRepository(db *gorm.DB) MomentRepository {
+	return &momentRepositoryImpl{DB: db}
+}
+
+func (r *momentRepositoryImpl) Get(ctx context.Context, timezone string, localDate time.Time) (*models.DailyMoment, error) {
+	var moment models.DailyMoment
+	err := r.DB.WithContext(ctx).
+		Where("timezone = ? AND local_date = ?", timezone, localDate.Format("2006-01-02")).
+		First(&moment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &moment, nil
+}
+
+func (r *momentRepositoryImpl) CreateIfMissing(ctx context.Context, moment *models.DailyMoment) (*models.DailyMoment, error) {
+	err := r.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(moment).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, moment.Timezone, moment.LocalDate)
+}
+
+func (r *momentRepositoryImpl) ClaimDue(ctx context.Context, now time.Time) ([]models.DailyMoment, error) {
+	var moments []models.DailyMoment
+	err := r.DB.WithContext(ctx).Raw(`
+		UPDATE daily_moments SET notified_at = now()
+		WHERE notified_at IS NULL AND starts_at <= ? AND deadline > ?
+		RETURNING *`, now, now).
+		Scan(&moments).Error
+	return moments, err
+}
+
+func (r *momentRepositoryImpl) ListTimezones(ctx context.Context) ([]string, error) {
+	return userTimezones(ctx, r.DB)
+}
+
+// userTimezones - часовые пояса, в которых есть пользователи
+func userTimezones(ctx context.Context, db *gorm.DB) ([]string, error) {
+	var timezones []string
+	err := db.WithContext(ctx).Model(&models.User{}).
+		Distinct("timezone").
+		Pluck("timezone", &timezones).Error
+	return timezones, err
+}
+
+func (r *momentRepositoryImpl) ListUserIDsByTimezone(ctx context.Context, timezone string) ([]int64, error) {
+	var ids []int64
+	err := r.DB.WithContext(ctx).Model(&models.User{}).
+		Where("timezone = ?", timezone).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+func (r *momentRepositoryImpl) GetUserTimezone(ctx context.Context, userID int64) (string, error) {
+	return userTimezone(ctx, r.DB, userID)
+}
+
+// userTimezone - IANA-пояс пользователя ("" для несуществующего)
+func userTimezone(ctx context.Context, db *gorm.DB, userID int64) (string, error) {
+	var timezones []string
+	err := db.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ?", userID).
+		Limit(1).
+		Pluck("timezone", &timezones).Error
+	if err != nil || len(timezones) == 0 {
+		return "", err
+	}
+	return timezones[0], nil
+}
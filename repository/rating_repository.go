@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RatingRepository ведет журнал рейтинга (rating_events) и users.rating
+type RatingRepository interface {
+	// Append сохраняет событие и сразу прибавляет его очки к users.rating.
+	// Повтор уникального события (публикация за уже оплаченный день)
+	// игнорируется: appended = false.
+	Append(ctx context.Context, event *models.RatingEvent) (appended bool, err error)
+	// SettleReaction приводит очки автора videoID за реакцию reactorID к
+	// weigh(автор, текущая реакция или nil) и записывает разницу в журнал.
+	// Строка автора блокируется, поэтому параллельные смены реакции не
+	// начисляют очки дважды. nil - начислять нечего; ErrRecordNotFound -
+	// видео уже удалено.
+	SettleReaction(ctx context.Context, reactorID, videoID int64, weigh func(authorID int64, kind *models.ReactionKind) int) (*models.RatingEvent, error)
+	// UpdateMaxReactions поднимает max_reactions автора до числа чужих реакций
+	// на videoID; true - установлен новый рекорд
+	UpdateMaxReactions(ctx context.Context, authorID, videoID int64) (bool, error)
+	// LedgerBuckets суммирует журнал по пользователю и часу, с которого очки
+	// затухают. Для реакций это час первого начисления за текущую реакцию
+	// пары (video_id, actor_id): поправки при смене или снятии реакции
+	// затухают вместе с исходным начислением и гасят его целиком. Новый
+	// отсчет начинается, когда сумма по паре вернулась к нулю.
+	// userID 0 - всех пользователей.
+	LedgerBuckets(ctx context.Context, userID int64) ([]models.RatingBucket, error)
+	// SetRatings записывает ratings в users.rating; пользователи из области
+	// (userID 0 - все), которых нет в ratings, получают 0. Возвращает число
+	// пользователей, у которых рейтинг изменился.
+	SetRatings(ctx context.Context, userID int64, ratings map[int64]int) (int64, error)
+}
+
+type ratingRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewRatingRepository(db *gorm.DB) RatingRepository {
+	return &ratingRepositoryImpl{DB: db}
+}
+
+func (r *ratingRepositoryImpl) Append(ctx context.Context, event *models.RatingEvent) (bool, error) {
+	appended := false
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		appended, err = appendRatingEvent(tx, event)
+		return err
+	})
+	return appended, err
+}
+
+func appendRatingEvent(tx *gorm.DB, event *models.RatingEvent) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	err := tx.Model(&models.User{}).
+		Where("user_id = ?", event.UserID).
+		Update("rating", gorm.Expr("GREATEST(0, rating + ?)", event.Points)).Error
+	return err == nil, err
+}
+
+func (r *ratingRepositoryImpl) SettleReaction(ctx context.Context, reactorID, videoID int64, weigh func(authorID int64, kind *models.ReactionKind) int) (*models.RatingEvent, error) {
+	var event *models.RatingEvent
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var video models.Video
+		if err := tx.Select("video_id, author_id").First(&video, videoID).Error; err != nil {
+			return err
+		}
+		var locked []int64
+		err := tx.Model(&models.User{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", video.AuthorID).
+			Pluck("user_id", &locked).Error
+		if err != nil {
+			return err
+		}
+		if len(locked) == 0 {
+			return ErrRecordNotFound
+		}
+
+		var kinds []models.ReactionKind
+		err = tx.Model(&models.Reaction{}).
+			Where("user_id = ? AND video_id = ?", reactorID, videoID).
+			Pluck("reaction", &kinds).Error
+		if err != nil {
+			return err
+		}
+		var kind *models.ReactionKind
+		if len(kinds) > 0 {
+			kind = &kinds[0]
+		}
+
+		var credited int
+		err = tx.Model(&models.RatingEvent{}).
+			Select("COALESCE(SUM(points), 0)").
+			Where("kind = ? AND video_id = ? AND actor_id = ?", models.RatingReaction, videoID, reactorID).
+			Scan(&credited).Error
+		if err != nil {
+			return err
+		}
+
+		delta := weigh(video.AuthorID, kind) - credited
+		if delta == 0 {
+			return nil
+		}
+		event = &models.RatingEvent{
+			UserID:  video.AuthorID,
+			Kind:    models.RatingReaction,
+			Points:  delta,
+			VideoID: &videoID,
+			ActorID: &reactorID,
+		}
+		_, err = appendRatingEvent(tx, event)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (r *ratingRepositoryImpl) UpdateMaxReactions(ctx context.Context, authorID, videoID int64) (bool, error) {
+	result := r.DB.WithContext(ctx).Exec(`
+		UPDATE users SET max_reactions = c.total
+		FROM (SELECT COUNT(*) AS total FROM reactions WHERE video_id = ? AND user_id <> ?) c
+		WHERE users.user_id = ? AND users.max_reactions < c.total`,
+		videoID, authorID, authorID)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *ratingRepositoryImpl) LedgerBuckets(ctx context.Context, userID int64) ([]models.RatingBucket, error) {
+	var buckets []models.RatingBucket
+	// credited_before - сумма по паре до события; 0 - начало новой реакции
+	err := r.DB.WithContext(ctx).Raw(`
+		WITH reactions AS (
+			SELECT event_id, user_id, video_id, actor_id, points, created_at,
+				SUM(points) OVER (PARTITION BY video_id, actor_id ORDER BY event_id) - points AS credited_before
+			FROM rating_events
+			WHERE kind = 'reaction' AND (@user = 0 OR user_id = @user)
+		), accrued AS (
+			SELECT user_id, points, created_at AS accrued_at
+			FROM rating_events
+			WHERE kind <> 'reaction' AND (@user = 0 OR user_id = @user)
+			UNION ALL
+			SELECT user_id, points,
+				MAX(CASE WHEN credited_before = 0 THEN created_at END)
+					OVER (PARTITION BY video_id, actor_id ORDER BY event_id)
+			FROM reactions
+		)
+		SELECT user_id, date_trunc('hour', accrued_at) AS hour, SUM(points) AS points
+		FROM accrued
+		GROUP BY user_id, hour`,
+		map[string]interface{}{"user": userID}).Scan(&buckets).Error
+	return buckets, err
+}
+
+func (r *ratingRepositoryImpl) SetRatings(ctx context.Context, userID int64, ratings map[int64]int) (int64, error) {
+	ids := make([]string, 0, len(ratings))
+	scores := make([]string, 0, len(ratings))
+	for id, score := range ratings {
+		ids = append(ids, strconv.FormatInt(id, 10))
+		scores = append(scores, strconv.Itoa(score))
+	}
+	// Массивы передаются литералами '{1,2}': драйвер не привязывает срезы
+	result := r.DB.WithContext(ctx).Exec(`
+		UPDATE users u SET rating = COALESCE(s.score, 0)
+		FROM users x
+		LEFT JOIN unnest(?::bigint[], ?::int[]) AS s(user_id, score) ON s.user_id = x.user_id
+		WHERE u.user_id = x.user_id AND (? = 0 OR x.user_id = ?)
+			AND u.rating <> COALESCE(s.score, 0)`,
+		"{"+strings.Join(ids, ",")+"}", "{"+strings.Join(scores, ",")+"}", userID, userID)
+	return result.RowsAffected, result.Error
+}
@@ -12,7 +12,7 @@ import (
 type ReactionRepository interface {
 	SetReaction(ctx context.Context, userID int64, videoID int64, kind models.ReactionKind) error
 	DeleteReaction(ctx context.Context, userID int64, videoID int64) (int64, error)
-	GetReactingUsers(ctx context.Context, videoID int64) ([]models.ReactingUserResponse, error)
+	GetReactingUsers(ctx context.Context, videoID int64, viewerID int64) ([]models.ReactingUserResponse, error)
 }
 
 // reactionRepositoryImpl - реализация ReactionRepository
@@ -62,13 +62,15 @@ func (r *reactionRepositoryImpl) DeleteReaction(ctx context.Context, userID int6
 	return result.RowsAffected, nil
 }
 
-// Получить список пользователей, поставивших реакцию
-func (r *reactionRepositoryImpl) GetReactingUsers(ctx context.Context, videoID int64) ([]models.ReactingUserResponse, error) {
+// Получить список пользователей, поставивших реакцию (без связанных с viewerID блокировкой)
+func (r *reactionRepositoryImpl) GetReactingUsers(ctx context.Context, videoID int64, viewerID int64) ([]models.ReactingUserResponse, error) {
 	var reactions []models.Reaction
 
+	notBlocked, args := NotBlockedWith("reactions.user_id", viewerID)
 	err := r.DB.WithContext(ctx).
 		Preload("User").
 		Where("video_id = ?", videoID).
+		Where(notBlocked, args...).
 		Find(&reactions).Error
 
 	if err != nil {
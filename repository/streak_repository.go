@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StreakRepository хранит серии пользователей (поля users) и их историю (streak_days)
+type StreakRepository interface {
+	// GetState - серия пользователя или ErrRecordNotFound
+	GetState(ctx context.Context, userID int64) (*models.StreakState, error)
+	// Update блокирует строку пользователя и передает серию в apply. День,
+	// который вернул apply, сохраняется вместе с измененной серией; nil -
+	// ничего не сохранять. Ошибка apply откатывает транзакцию.
+	Update(ctx context.Context, userID int64, apply func(state *models.StreakState) (*models.StreakDay, error)) (*models.StreakState, error)
+	// ListDays - история серии за [from, to], от новых дней к старым
+	ListDays(ctx context.Context, userID int64, from, to time.Time) ([]models.StreakDay, error)
+	// ResetMissed обнуляет серии пользователей пояса, последний засчитанный
+	// день которых раньше before, и возвращает их число
+	ResetMissed(ctx context.Context, timezone string, before time.Time) (int64, error)
+	ListTimezones(ctx context.Context) ([]string, error)
+}
+
+type streakRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewStreakRepository(db *gorm.DB) StreakRepository {
+	return &streakRepositoryImpl{DB: db}
+}
+
+const streakColumns = "user_id, current_streak, max_streak, streak_freezes, last_streak_date, timezone"
+
+func (r *streakRepositoryImpl) GetState(ctx context.Context, userID int64) (*models.StreakState, error) {
+	return loadStreakState(r.DB.WithContext(ctx), userID, false)
+}
+
+// loadStreakState читает серию; forUpdate блокирует строку пользователя до конца транзакции
+func loadStreakState(db *gorm.DB, userID int64, forUpdate bool) (*models.StreakState, error) {
+	var state models.StreakState
+	query := db.Table("users").Select(streakColumns).Where("user_id = ?", userID)
+	if forUpdate {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	err := query.Take(&state).Error
+	if err != nil {
+		return nil, err
+	}
+	if state.LastStreakDate != nil {
+		var kinds []models.StreakDayKind
+		err := db.Model(&models.StreakDay{}).
+			Where("user_id = ? AND local_date = ?", userID, state.LastStreakDate.Format("2006-01-02")).
+			Pluck("kind", &kinds).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(kinds) > 0 {
+			state.LastKind = kinds[0]
+		}
+	}
+	return &state, nil
+}
+
+func (r *streakRepositoryImpl) Update(ctx context.Context, userID int64, apply func(state *models.StreakState) (*models.StreakDay, error)) (*models.StreakState, error) {
+	var state *models.StreakState
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		state, err = loadStreakState(tx, userID, true)
+		if err != nil {
+			return err
+		}
+		day, err := apply(state)
+		if err != nil || day == nil {
+			return err
+		}
+
+		// Замороженный заранее день может стать опубликованным
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "local_date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"kind"}),
+		}).Create(day).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+			"current_streak":   state.CurrentStreak,
+			"max_streak":       state.MaxStreak,
+			"streak_freezes":   state.StreakFreezes,
+			"last_streak_date": state.LastStreakDate,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (r *streakRepositoryImpl) ListDays(ctx context.Context, userID int64, from, to time.Time) ([]models.StreakDay, error) {
+	var days []models.StreakDay
+	err := r.DB.WithContext(ctx).
+		Where("user_id = ? AND local_date BETWEEN ? AND ?", userID, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Order("local_date DESC").
+		Find(&days).Error
+	return days, err
+}
+
+func (r *streakRepositoryImpl) ResetMissed(ctx context.Context, timezone string, before time.Time) (int64, error) {
+	result := r.DB.WithContext(ctx).Model(&models.User{}).
+		Where("timezone = ? AND current_streak > 0", timezone).
+		Where("last_streak_date IS NULL OR last_streak_date < ?", before.Format("2006-01-02")).
+		Update("current_streak", 0)
+	return result.RowsAffected, result.Error
+}
+
+func (r *streakRepositoryImpl) ListTimezones(ctx context.Context) ([]string, error) {
+	return userTimezones(ctx, r.DB)
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TranscodeRepository - очередь задач перекодирования в Postgres
+type TranscodeRepository interface {
+	// Enqueue ставит видео в очередь; повторный вызов для того же видео ничего не делает
+	Enqueue(ctx context.Context, videoID int64) error
+	// EnqueueMissing ставит в очередь видео в статусе processing без задачи
+	// (например, если Enqueue после загрузки не удался) и возвращает их число
+	EnqueueMissing(ctx context.Context) (int64, error)
+	// Claim забирает одну готовую к запуску задачу или задачу с истекшей
+	// арендой (staleBefore). Возвращает nil, если задач нет.
+	Claim(ctx context.Context, workerID string, staleBefore time.Time) (*models.TranscodeJob, error)
+	// Complete закрывает задачу и публикует видео с плейлистом и превью.
+	// ErrRecordNotFound - видео уже удалено.
+	Complete(ctx context.Context, job *models.TranscodeJob, output TranscodeOutput) error
+	// Retry возвращает задачу в очередь не раньше runAt
+	Retry(ctx context.Context, job *models.TranscodeJob, runAt time.Time, lastError string) error
+	// Fail окончательно помечает задачу и видео как failed
+	Fail(ctx context.Context, job *models.TranscodeJob, lastError string) error
+}
+
+// TranscodeOutput - ключи файлов, созданных воркером для видео
+type TranscodeOutput struct {
+	PlaylistKey string
+	PosterKey   string
+	PreviewKey  string
+	BlurHash    string
+}
+
+type transcodeRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewTranscodeRepository(db *gorm.DB) TranscodeRepository {
+	return &transcodeRepositoryImpl{DB: db}
+}
+
+func (r *transcodeRepositoryImpl) Enqueue(ctx context.Context, videoID int64) error {
+	job := models.TranscodeJob{VideoID: videoID, Status: models.JobPending}
+	return r.DB.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "video_id"}}, DoNothing: true}).
+		Create(&job).Error
+}
+
+func (r *transcodeRepositoryImpl) EnqueueMissing(ctx context.Context) (int64, error) {
+	result := r.DB.WithContext(ctx).Exec(`
+		INSERT INTO transcode_jobs (video_id)
+		SELECT v.video_id FROM videos v
+		WHERE v.status = ?
+		  AND NOT EXISTS (SELECT 1 FROM transcode_jobs j WHERE j.video_id = v.video_id)
+		ON CONFLICT (video_id) DO NOTHING`, models.VideoProcessing)
+	return result.RowsAffected, result.Error
+}
+
+func (r *transcodeRepositoryImpl) Claim(ctx context.Context, workerID string, staleBefore time.Time) (*models.TranscodeJob, error) {
+	var job models.TranscodeJob
+	// SKIP LOCKED: параллельные воркеры не ждут друг друга и не берут одну задачу дважды
+	result := r.DB.WithContext(ctx).Raw(`
+		UPDATE transcode_jobs
+		SET status = ?, attempts = attempts + 1, locked_at = now(), locked_by = ?, updated_at = now()
+		WHERE job_id = (
+			SELECT job_id FROM transcode_jobs
+			WHERE (status = ? AND run_at <= now())
+			   OR (status = ? AND locked_at < ?)
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		models.JobRunning, workerID, models.JobPending, models.JobRunning, staleBefore).
+		Scan(&job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+func (r *transcodeRepositoryImpl) Complete(ctx context.Context, job *models.TranscodeJob, output TranscodeOutput) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Video{}).
+			Where("video_id = ?", job.VideoID).
+			Updates(map[string]interface{}{
+				"status":       models.VideoReady,
+				"playlist_key": output.PlaylistKey,
+				"poster_key":   output.PosterKey,
+				"preview_key":  output.PreviewKey,
+				"blurhash":     output.BlurHash,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		return tx.Model(&models.TranscodeJob{}).
+			Where("job_id = ?", job.JobID).
+			Updates(map[string]interface{}{
+				"status": models.JobDone, "locked_at": nil, "last_error": "", "updated_at": time.Now(),
+			}).Error
+	})
+}
+
+func (r *transcodeRepositoryImpl) Retry(ctx context.Context, job *models.TranscodeJob, runAt time.Time, lastError string) error {
+	return r.DB.WithContext(ctx).Model(&models.TranscodeJob{}).
+		Where("job_id = ?", job.JobID).
+		Updates(map[string]interface{}{
+			"status": models.JobPending, "run_at": runAt, "locked_at": nil, "last_error": lastError, "updated_at": time.Now(),
+		}).Error
+}
+
+func (r *transcodeRepositoryImpl) Fail(ctx context.Context, job *models.TranscodeJob, lastError string) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.TranscodeJob{}).
+			Where("job_id = ?", job.JobID).
+			Updates(map[string]interface{}{
+				"status": models.JobFailed, "locked_at": nil, "last_error": lastError, "updated_at": time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Video{}).
+			Where("video_id = ?", job.VideoID).
+			Update("status", models.VideoFailed).Error
+	})
+}
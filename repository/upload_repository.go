@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+)
+
+// UploadRepository хранит состояние resumable-загрузок
+type UploadRepository interface {
+	Create(ctx context.Context, upload *models.Upload) error
+	GetByID(ctx context.Context, uploadID string) (*models.Upload, error)
+	// AdvanceOffset сдвигает offset, только если он все еще равен from
+	AdvanceOffset(ctx context.Context, uploadID string, from, to int64) (rowsAffected int64, err error)
+	MarkCompleted(ctx context.Context, uploadID string, videoID int64) error
+	Delete(ctx context.Context, uploadID string) error
+	// CountOpen - число незавершенных загрузок автора, срок которых не истек к now
+	CountOpen(ctx context.Context, authorID int64, now time.Time) (int64, error)
+	// ListExpired - незавершенные загрузки, срок которых истек
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]models.Upload, error)
+}
+
+type uploadRepositoryImpl struct {
+	DB *gorm.DB
+}
+
+func NewUploadRepository(db *gorm.DB) UploadRepository {
+	return &uploadRepositoryImpl{DB: db}
+}
+
+func (r *uploadRepositoryImpl) Create(ctx context.Context, upload *models.Upload) error {
+	return r.DB.WithContext(ctx).Create(upload).Error
+}
+
+func (r *uploadRepositoryImpl) GetByID(ctx context.Context, uploadID string) (*models.Upload, error) {
+	var upload models.Upload
+	if err := r.DB.WithContext(ctx).First(&upload, "upload_id = ?", uploadID).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+func (r *uploadRepositoryImpl) AdvanceOffset(ctx context.Context, uploadID string, from, to int64) (int64, error) {
+	result := r.DB.WithContext(ctx).Model(&models.Upload{}).
+		Where("upload_id = ? AND upload_offset = ? AND video_id IS NULL", uploadID, from).
+		Update("upload_offset", to)
+	return result.RowsAffected, result.Error
+}
+
+func (r *uploadRepositoryImpl) MarkCompleted(ctx context.Context, uploadID string, videoID int64) error {
+	return r.DB.WithContext(ctx).Model(&models.Upload{}).
+		Where("upload_id = ?", uploadID).
+		Update("video_id", videoID).Error
+}
+
+func (r *uploadRepositoryImpl) Delete(ctx context.Context, uploadID string) error {
+	return r.DB.WithContext(ctx).Delete(&models.Upload{}, "upload_id = ?", uploadID).Error
+}
+
+func (r *uploadRepositoryImpl) CountOpen(ctx context.Context, authorID int64, now time.Time) (int64, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&models.Upload{}).
+		Where("author_id = ? AND video_id IS NULL AND expires_at > ?", authorID, now).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *uploadRepositoryImpl) ListExpired(ctx context.Context, before time.Time, limit int) ([]models.Upload, error) {
+	var uploads []models.Upload
+	err := r.DB.WithContext(ctx).
+		Where("expires_at < ?", before).
+		Order("expires_at").
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, err
+}
@@ -9,6 +9,10 @@ import (
 
 type UserRepository interface {
 	UpdateAvatarPath(ctx context.Context, userID uint64, avatarPath string) error
+	// GetAvatarPath возвращает ключ аватара в хранилище или "", если аватара нет
+	GetAvatarPath(ctx context.Context, userID uint64) (string, error)
+	UpdateRole(ctx context.Context, userID uint64, role models.Role) (rowsAffected int64, err error)
+	UpdateTimezone(ctx context.Context, userID uint64, timezone string) error
 }
 
 type userRepositoryImpl struct {
@@ -24,3 +28,28 @@ func (r *userRepositoryImpl) UpdateAvatarPath(ctx context.Context, userID uint64
 		Where("user_id = ?", userID).
 		Update("avatar_filepath", avatarPath).Error
 }
+
+func (r *userRepositoryImpl) GetAvatarPath(ctx context.Context, userID uint64) (string, error) {
+	var paths []*string
+	err := r.DB.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ?", userID).
+		Limit(1).
+		Pluck("avatar_filepath", &paths).Error
+	if err != nil || len(paths) == 0 || paths[0] == nil {
+		return "", err
+	}
+	return *paths[0], nil
+}
+
+func (r *userRepositoryImpl) UpdateRole(ctx context.Context, userID uint64, role models.Role) (int64, error) {
+	result := r.DB.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ?", userID).
+		Update("role", role)
+	return result.RowsAffected, result.Error
+}
+
+func (r *userRepositoryImpl) UpdateTimezone(ctx context.Context, userID uint64, timezone string) error {
+	return r.DB.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ?", userID).
+		Update("timezone", timezone).Error
+}
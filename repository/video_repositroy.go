@@ -7,16 +7,41 @@ import (
 
 	"github.com/merinovvvv/momentic-backend/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var ErrRecordNotFound = gorm.ErrRecordNotFound
 
+// ErrMomentTaken - видео за этот день уже создано, заменено или удалено
+// параллельным запросом
+var ErrMomentTaken = errors.New("video for this moment already exists")
+
 // VideoRepository определяет методы для работы с БД
 type VideoRepository interface {
+	// CreateVideo возвращает ErrMomentTaken, если у автора уже есть видео
+	// с тем же MomentDate. Вместе с видео засчитывается загрузка дня: она
+	// должна быть video.RetakeCount-й по счету, иначе тоже ErrMomentTaken.
 	CreateVideo(ctx context.Context, video *models.Video) error
+	// GetVideoByMomentDate - видео автора за локальный день или ErrRecordNotFound
+	GetVideoByMomentDate(ctx context.Context, authorID int64, momentDate time.Time) (*models.Video, error)
+	// CountMomentUploads - сколько раз автор загружал момент за день,
+	// включая удаленные и замененные видео
+	CountMomentUploads(ctx context.Context, authorID int64, momentDate time.Time) (int, error)
+	// ReplaceVideo атомарно удаляет old и создает video (пересъемка момента),
+	// засчитывая загрузку как CreateVideo. Если old уже удалено или
+	// переснято другим запросом - ErrMomentTaken.
+	ReplaceVideo(ctx context.Context, old *models.Video, video *models.Video) error
 	GetVideoByID(ctx context.Context, videoID int64) (*models.Video, error)
 	GetFriendsIDs(ctx context.Context, userID int64) ([]int64, error)
-	GetTodayVideosByAuthors(ctx context.Context, authorIDs []int64) ([]models.Video, error)
+	AreFriends(ctx context.Context, userID, otherID int64) (bool, error)
+	// GetVideosByAuthors - готовые видео авторов, опубликованные в [from, to)
+	GetVideosByAuthors(ctx context.Context, authorIDs []int64, from, to time.Time) ([]models.Video, error)
+	// ListFeed - страница ленты viewerID (готовые видео друзей за [from, to))
+	// после after, с автором, реакциями и числом комментариев. Число
+	// запросов не зависит от размера страницы.
+	ListFeed(ctx context.Context, viewerID int64, from, to time.Time, after *models.FeedCursor, limit int) ([]models.FeedItem, error)
+	// GetUserTimezone - IANA-пояс пользователя ("" для несуществующего)
+	GetUserTimezone(ctx context.Context, userID int64) (string, error)
 	DeleteVideo(ctx context.Context, videoID int64) (*models.Video, error)
 	UpdateDescription(ctx context.Context, videoID int64, description string) (rowsAffected int64, err error)
 }
@@ -32,7 +57,94 @@ func NewVideoRepository(db *gorm.DB) VideoRepository {
 
 // --- Реализации CRUD для Video ---
 func (r *videoRepositoryImpl) CreateVideo(ctx context.Context, video *models.Video) error {
-	return r.db.WithContext(ctx).Create(video).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := countMomentUpload(tx, video); err != nil {
+			return err
+		}
+		return createMomentVideo(tx, video)
+	})
+}
+
+// countMomentUpload засчитывает загрузку дня, только если до нее их было
+// ровно video.RetakeCount; так из параллельных загрузок, в том числе после
+// удаления видео, проходит только одна
+func countMomentUpload(tx *gorm.DB, video *models.Video) error {
+	if video.MomentDate == nil {
+		return nil
+	}
+	var result *gorm.DB
+	if video.RetakeCount == 0 {
+		result = tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.MomentUpload{AuthorID: video.AuthorID, MomentDate: *video.MomentDate, Uploads: 1})
+	} else {
+		result = tx.Model(&models.MomentUpload{}).
+			Where("author_id = ? AND moment_date = ? AND uploads = ?",
+				video.AuthorID, video.MomentDate.Format("2006-01-02"), video.RetakeCount).
+			Update("uploads", gorm.Expr("uploads + 1"))
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMomentTaken
+	}
+	return nil
+}
+
+func (r *videoRepositoryImpl) CountMomentUploads(ctx context.Context, authorID int64, momentDate time.Time) (int, error) {
+	var uploads []int
+	err := r.db.WithContext(ctx).Model(&models.MomentUpload{}).
+		Where("author_id = ? AND moment_date = ?", authorID, momentDate.Format("2006-01-02")).
+		Pluck("uploads", &uploads).Error
+	if err != nil || len(uploads) == 0 {
+		return 0, err
+	}
+	return uploads[0], nil
+}
+
+// createMomentVideo вставляет видео; конфликт по (author_id, moment_date)
+// означает, что день уже занят
+func createMomentVideo(db *gorm.DB, video *models.Video) error {
+	result := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "author_id"}, {Name: "moment_date"}},
+		DoNothing: true,
+	}).Create(video)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMomentTaken
+	}
+	return nil
+}
+
+func (r *videoRepositoryImpl) GetVideoByMomentDate(ctx context.Context, authorID int64, momentDate time.Time) (*models.Video, error) {
+	var video models.Video
+	err := r.db.WithContext(ctx).
+		Where("author_id = ? AND moment_date = ?", authorID, momentDate.Format("2006-01-02")).
+		First(&video).Error
+	if err != nil {
+		return nil, err
+	}
+	return &video, nil
+}
+
+func (r *videoRepositoryImpl) ReplaceVideo(ctx context.Context, old *models.Video, video *models.Video) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// retake_count в условии отсекает параллельную пересъемку того же видео
+		result := tx.Where("video_id = ? AND retake_count = ?", old.VideoID, old.RetakeCount).
+			Delete(&models.Video{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMomentTaken
+		}
+		if err := countMomentUpload(tx, video); err != nil {
+			return err
+		}
+		return createMomentVideo(tx, video)
+	})
 }
 
 func (r *videoRepositoryImpl) GetVideoByID(ctx context.Context, videoID int64) (*models.Video, error) {
@@ -93,16 +205,117 @@ func (r *videoRepositoryImpl) GetFriendsIDs(ctx context.Context, userID int64) (
 	return friendIDs, nil
 }
 
-func (r *videoRepositoryImpl) GetTodayVideosByAuthors(ctx context.Context, authorIDs []int64) ([]models.Video, error) {
-	startOfDay := time.Now().Truncate(24 * time.Hour)
-	endOfToday := startOfDay.Add(24 * time.Hour)
+func (r *videoRepositoryImpl) AreFriends(ctx context.Context, userID, otherID int64) (bool, error) {
+	userID1, userID2 := models.CanonicalPair(userID, otherID)
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Friendship{}).
+		Where("user_id1 = ? AND user_id2 = ? AND status = ?", userID1, userID2, models.StatusFriends).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *videoRepositoryImpl) GetVideosByAuthors(ctx context.Context, authorIDs []int64, from, to time.Time) ([]models.Video, error) {
 	var videos []models.Video
 
 	result := r.db.WithContext(ctx).
 		Where("author_id IN (?)", authorIDs).
-		Where("created_at >= ? AND created_at < ?", startOfDay, endOfToday).
+		Where("status = ?", models.VideoReady).
+		Where("created_at >= ? AND created_at < ?", from, to).
 		Order("created_at DESC").
 		Find(&videos)
 
 	return videos, result.Error
 }
+
+func (r *videoRepositoryImpl) GetUserTimezone(ctx context.Context, userID int64) (string, error) {
+	return userTimezone(ctx, r.db, userID)
+}
+
+func (r *videoRepositoryImpl) ListFeed(ctx context.Context, viewerID int64, from, to time.Time, after *models.FeedCursor, limit int) ([]models.FeedItem, error) {
+	db := r.db.WithContext(ctx)
+
+	// 1. Страница видео друзей вместе с авторами; keyset по (created_at, video_id)
+	//    опирается на индекс idx_videos_author_created
+	query := db.Table("videos AS v").
+		Select(`v.video_id, v.description, v.duration_ms, v.width, v.height, v.rotation, v.blurhash, v.posted_late, v.retake_count, v.created_at,
+			v.filepath, v.playlist_key, v.poster_key, v.preview_key,
+			v.author_id, u.name AS author_name, u.surname AS author_surname, u.avatar_filepath AS author_avatar_filepath`).
+		Joins("JOIN users u ON u.user_id = v.author_id").
+		Where(`v.author_id IN (
+			SELECT CASE WHEN f.user_id1 = ? THEN f.user_id2 ELSE f.user_id1 END
+			FROM friendships f
+			WHERE f.status = ? AND (f.user_id1 = ? OR f.user_id2 = ?))`,
+			viewerID, models.StatusFriends, viewerID, viewerID).
+		Where("v.status = ?", models.VideoReady).
+		Where("v.created_at >= ? AND v.created_at < ?", from, to)
+	if after != nil {
+		query = query.Where("(v.created_at, v.video_id) < (?, ?)", after.CreatedAt, after.VideoID)
+	}
+	var items []models.FeedItem
+	err := query.Order("v.created_at DESC, v.video_id DESC").Limit(limit).Scan(&items).Error
+	if err != nil || len(items) == 0 {
+		return items, err
+	}
+
+	videoIDs := make([]int64, len(items))
+	byID := make(map[int64]*models.FeedItem, len(items))
+	for i := range items {
+		videoIDs[i] = items[i].VideoID
+		items[i].Reactions = map[models.ReactionKind]int64{}
+		byID[items[i].VideoID] = &items[i]
+	}
+
+	// 2. Реакции по видам; как и в списках реакций и комментариев, не
+	//    учитываются пользователи, связанные со зрителем блокировкой
+	var reactionCounts []struct {
+		VideoID  int64
+		Reaction models.ReactionKind
+		Count    int64
+	}
+	reactionsNotBlocked, reactionArgs := NotBlockedWith("reactions.user_id", viewerID)
+	err = db.Model(&models.Reaction{}).
+		Select("video_id, reaction, COUNT(*) AS count").
+		Where("video_id IN ?", videoIDs).
+		Where(reactionsNotBlocked, reactionArgs...).
+		Group("video_id, reaction").
+		Scan(&reactionCounts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, rc := range reactionCounts {
+		byID[rc.VideoID].Reactions[rc.Reaction] = rc.Count
+	}
+
+	// 3. Реакции самого зрителя
+	var own []models.Reaction
+	err = db.Select("video_id, reaction").
+		Where("user_id = ? AND video_id IN ?", viewerID, videoIDs).
+		Find(&own).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, reaction := range own {
+		kind := reaction.Reaction
+		byID[reaction.VideoID].MyReaction = &kind
+	}
+
+	// 4. Число комментариев
+	var commentCounts []struct {
+		VideoID int64
+		Count   int64
+	}
+	commentsNotBlocked, commentArgs := NotBlockedWith("comments.user_id", viewerID)
+	err = db.Model(&models.Comment{}).
+		Select("video_id, COUNT(*) AS count").
+		Where("video_id IN ?", videoIDs).
+		Where(commentsNotBlocked, commentArgs...).
+		Group("video_id").
+		Scan(&commentCounts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, cc := range commentCounts {
+		byID[cc.VideoID].CommentCount = cc.Count
+	}
+	return items, nil
+}
@@ -0,0 +1,14 @@
+package service
+
+import "github.com/merinovvvv/momentic-backend/models"
+
+// Actor - пользователь, от имени которого выполняется действие
+type Actor struct {
+	UserID int64
+	Role   models.Role
+}
+
+// CanModerate сообщает, может ли пользователь менять чужой контент
+func (a Actor) CanModerate() bool {
+	return a.Role == models.RoleModerator || a.Role == models.RoleAdmin
+}
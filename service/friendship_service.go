@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+var (
+	ErrCannotBefriendSelf    = errors.New("cannot send a friend request to yourself")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrAlreadyFriends        = errors.New("users are already friends")
+	ErrFriendRequestExists   = errors.New("friend request already sent")
+	ErrFriendRequestNotFound = errors.New("friend request not found")
+	ErrNotFriends            = errors.New("users are not friends")
+	ErrCannotBlockSelf       = errors.New("cannot block yourself")
+	ErrNotBlocked            = errors.New("user is not blocked")
+	ErrUserBlocked           = errors.New("interaction is not allowed: user is blocked")
+)
+
+const (
+	DefaultFriendsPageSize = 20
+	MaxFriendsPageSize     = 100
+
+	DefaultSuggestionsLimit = 20
+	MaxSuggestionsLimit     = 50
+)
+
+// FriendshipService определяет жизненный цикл заявок в друзья.
+type FriendshipService interface {
+	// SendRequest возвращает итоговый статус пары: заявка или дружба,
+	// если встречная заявка уже существовала
+	SendRequest(ctx context.Context, fromID, toID int64) (models.FriendshipStatus, error)
+	AcceptRequest(ctx context.Context, userID, requesterID int64) error
+	DeclineRequest(ctx context.Context, userID, requesterID int64) error
+	CancelRequest(ctx context.Context, userID, targetID int64) error
+	Unfriend(ctx context.Context, userID, friendID int64) error
+	ListIncoming(ctx context.Context, userID int64) ([]models.FriendUser, error)
+	ListOutgoing(ctx context.Context, userID int64) ([]models.FriendUser, error)
+	// ListFriends - страница друзей; limit и offset в ответе - уже приведенные к допустимым
+	ListFriends(ctx context.Context, userID int64, limit, offset int) (*FriendsPage, error)
+	// Block заменяет дружбу или заявку блокировкой; повторная блокировка ничего не меняет
+	Block(ctx context.Context, blockerID, targetID int64) error
+	Unblock(ctx context.Context, blockerID, targetID int64) error
+	ListBlocked(ctx context.Context, userID int64) ([]models.FriendUser, error)
+	// Suggestions - друзья друзей по убыванию числа общих друзей
+	Suggestions(ctx context.Context, userID int64, limit int) ([]models.FriendSuggestion, error)
+	DismissSuggestion(ctx context.Context, userID, dismissedUserID int64) error
+}
+
+// FriendsPage - страница друзей с фактически использованными limit и offset
+type FriendsPage struct {
+	Items  []models.FriendUser `json:"items"`
+	Total  int64               `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+}
+
+type friendshipServiceImpl struct {
+	Repo repository.FriendshipRepository
+}
+
+func NewFriendshipService(repo repository.FriendshipRepository) FriendshipService {
+	return &friendshipServiceImpl{Repo: repo}
+}
+
+// requestStatus - статус заявки, отправленной fromID пользователю toID
+func requestStatus(fromID, toID int64) models.FriendshipStatus {
+	userID1, _ := models.CanonicalPair(fromID, toID)
+	return models.PendingStatusFrom(fromID, userID1)
+}
+
+func (s *friendshipServiceImpl) SendRequest(ctx context.Context, fromID, toID int64) (models.FriendshipStatus, error) {
+	if fromID == toID {
+		return "", ErrCannotBefriendSelf
+	}
+	exists, err := s.Repo.UserExists(ctx, toID)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", ErrUserNotFound
+	}
+
+	outgoing := requestStatus(fromID, toID)
+	created, err := s.Repo.CreateFriendship(ctx, fromID, toID, outgoing)
+	if err != nil {
+		log.Printf("ERROR: Failed to create friend request %d -> %d: %v", fromID, toID, err)
+		return "", err
+	}
+	if created {
+		log.Printf("INFO: Friend request sent %d -> %d", fromID, toID)
+		return outgoing, nil
+	}
+
+	// Запись для пары уже есть - разбираемся по ее статусу
+	existing, err := s.Repo.GetFriendship(ctx, fromID, toID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		// Запись удалили между вставкой и чтением - просим повторить
+		return "", ErrFriendRequestNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch existing.Status {
+	case models.StatusFriends:
+		return "", ErrAlreadyFriends
+	case models.StatusBlocked1, models.StatusBlocked2, models.StatusBlocked12:
+		// Блокировка в любую сторону запрещает заявки обоим
+		return "", ErrUserBlocked
+	case outgoing:
+		return "", ErrFriendRequestExists
+	case requestStatus(toID, fromID):
+		// Встречная заявка: отправка равносильна принятию
+		if err := s.AcceptRequest(ctx, fromID, toID); err != nil {
+			return "", err
+		}
+		return models.StatusFriends, nil
+	default:
+		return "", ErrFriendRequestExists
+	}
+}
+
+func (s *friendshipServiceImpl) AcceptRequest(ctx context.Context, userID, requesterID int64) error {
+	rows, err := s.Repo.UpdateStatus(ctx, userID, requesterID, requestStatus(requesterID, userID), models.StatusFriends)
+	if err != nil {
+		log.Printf("ERROR: Failed to accept friend request %d -> %d: %v", requesterID, userID, err)
+		return err
+	}
+	if rows == 0 {
+		return ErrFriendRequestNotFound
+	}
+	log.Printf("INFO: Users %d and %d are now friends", requesterID, userID)
+	return nil
+}
+
+func (s *friendshipServiceImpl) DeclineRequest(ctx context.Context, userID, requesterID int64) error {
+	rows, err := s.Repo.DeleteFriendship(ctx, userID, requesterID, requestStatus(requesterID, userID))
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFriendRequestNotFound
+	}
+	log.Printf("INFO: Friend request %d -> %d declined", requesterID, userID)
+	return nil
+}
+
+func (s *friendshipServiceImpl) CancelRequest(ctx context.Context, userID, targetID int64) error {
+	rows, err := s.Repo.DeleteFriendship(ctx, userID, targetID, requestStatus(userID, targetID))
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrFriendRequestNotFound
+	}
+	log.Printf("INFO: Friend request %d -> %d cancelled", userID, targetID)
+	return nil
+}
+
+func (s *friendshipServiceImpl) Unfriend(ctx context.Context, userID, friendID int64) error {
+	rows, err := s.Repo.DeleteFriendship(ctx, userID, friendID, models.StatusFriends)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFriends
+	}
+	log.Printf("INFO: Users %d and %d are no longer friends", userID, friendID)
+	return nil
+}
+
+func (s *friendshipServiceImpl) ListIncoming(ctx context.Context, userID int64) ([]models.FriendUser, error) {
+	return s.Repo.GetIncomingRequests(ctx, userID)
+}
+
+func (s *friendshipServiceImpl) ListOutgoing(ctx context.Context, userID int64) ([]models.FriendUser, error) {
+	return s.Repo.GetOutgoingRequests(ctx, userID)
+}
+
+func (s *friendshipServiceImpl) ListFriends(ctx context.Context, userID int64, limit, offset int) (*FriendsPage, error) {
+	if limit <= 0 {
+		limit = DefaultFriendsPageSize
+	}
+	if limit > MaxFriendsPageSize {
+		limit = MaxFriendsPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	friends, total, err := s.Repo.GetFriends(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &FriendsPage{Items: friends, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+func (s *friendshipServiceImpl) Block(ctx context.Context, blockerID, targetID int64) error {
+	if blockerID == targetID {
+		return ErrCannotBlockSelf
+	}
+	exists, err := s.Repo.UserExists(ctx, targetID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	userID1, _ := models.CanonicalPair(blockerID, targetID)
+	err = s.Repo.TransitionPair(ctx, blockerID, targetID, func(current *models.FriendshipStatus) (*models.FriendshipStatus, error) {
+		next := blockTransition(current, blockerID == userID1)
+		return &next, nil
+	})
+	if err != nil {
+		log.Printf("ERROR: Failed to block user %d by %d: %v", targetID, blockerID, err)
+		return err
+	}
+	log.Printf("INFO: User %d blocked user %d", blockerID, targetID)
+	return nil
+}
+
+func (s *friendshipServiceImpl) Unblock(ctx context.Context, blockerID, targetID int64) error {
+	userID1, _ := models.CanonicalPair(blockerID, targetID)
+	err := s.Repo.TransitionPair(ctx, blockerID, targetID, func(current *models.FriendshipStatus) (*models.FriendshipStatus, error) {
+		return unblockTransition(current, blockerID == userID1)
+	})
+	if err != nil {
+		if !errors.Is(err, ErrNotBlocked) {
+			log.Printf("ERROR: Failed to unblock user %d by %d: %v", targetID, blockerID, err)
+		}
+		return err
+	}
+	log.Printf("INFO: User %d unblocked user %d", blockerID, targetID)
+	return nil
+}
+
+func (s *friendshipServiceImpl) ListBlocked(ctx context.Context, userID int64) ([]models.FriendUser, error) {
+	return s.Repo.GetBlockedUsers(ctx, userID)
+}
+
+// blockTransition: blocked1 - user1 заблокировал user2, blocked2 - наоборот,
+// blocked12 - взаимная блокировка. Дружба и заявки блокировкой замещаются.
+func blockTransition(current *models.FriendshipStatus, blockerIsUser1 bool) models.FriendshipStatus {
+	mine, theirs := models.StatusBlocked2, models.StatusBlocked1
+	if blockerIsUser1 {
+		mine, theirs = models.StatusBlocked1, models.StatusBlocked2
+	}
+	if current == nil {
+		return mine
+	}
+	switch *current {
+	case theirs, models.StatusBlocked12:
+		return models.StatusBlocked12
+	default:
+		return mine
+	}
+}
+
+// unblockTransition снимает блокировку blocker'а; встречная блокировка остается.
+// nil означает, что запись пары удаляется.
+func unblockTransition(current *models.FriendshipStatus, blockerIsUser1 bool) (*models.FriendshipStatus, error) {
+	mine, theirs := models.StatusBlocked2, models.StatusBlocked1
+	if blockerIsUser1 {
+		mine, theirs = models.StatusBlocked1, models.StatusBlocked2
+	}
+	if current == nil {
+		return nil, ErrNotBlocked
+	}
+	switch *current {
+	case mine:
+		return nil, nil
+	case models.StatusBlocked12:
+		return &theirs, nil
+	default:
+		return nil, ErrNotBlocked
+	}
+}
+
+func (s *friendshipServiceImpl) Suggestions(ctx context.Context, userID int64, limit int) ([]models.FriendSuggestion, error) {
+	if limit <= 0 {
+		limit = DefaultSuggestionsLimit
+	}
+	if limit > MaxSuggestionsLimit {
+		limit = MaxSuggestionsLimit
+	}
+	suggestions, err := s.Repo.GetFriendSuggestions(ctx, userID, limit)
+	if err != nil {
+		log.Printf("ERROR: Failed to build friend suggestions for user %d: %v", userID, err)
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+func (s *friendshipServiceImpl) DismissSuggestion(ctx context.Context, userID, dismissedUserID int64) error {
+	if userID == dismissedUserID {
+		return ErrCannotBefriendSelf
+	}
+	exists, err := s.Repo.UserExists(ctx, dismissedUserID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+	return s.Repo.DismissSuggestion(ctx, userID, dismissedUserID)
+}
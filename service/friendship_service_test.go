@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+// MockFriendshipRepository хранит статусы пар в памяти по канонической паре
+type MockFriendshipRepository struct {
+	Users map[int64]bool
+	Pairs map[[2]int64]models.FriendshipStatus
+
+	// GetFriendsFn - если задан, отвечает на GetFriends
+	GetFriendsFn func(userID int64, limit, offset int) ([]models.FriendUser, int64, error)
+}
+
+func newMockFriendshipRepository(users ...int64) *MockFriendshipRepository {
+	m := &MockFriendshipRepository{Users: map[int64]bool{}, Pairs: map[[2]int64]models.FriendshipStatus{}}
+	for _, id := range users {
+		m.Users[id] = true
+	}
+	return m
+}
+
+func pairKey(a, b int64) [2]int64 {
+	userID1, userID2 := models.CanonicalPair(a, b)
+	return [2]int64{userID1, userID2}
+}
+
+// status - статус пары или "" без записи
+func (m *MockFriendshipRepository) status(a, b int64) models.FriendshipStatus {
+	return m.Pairs[pairKey(a, b)]
+}
+
+func (m *MockFriendshipRepository) UserExists(ctx context.Context, userID int64) (bool, error) {
+	return m.Users[userID], nil
+}
+
+func (m *MockFriendshipRepository) GetFriendship(ctx context.Context, a, b int64) (*models.Friendship, error) {
+	status, ok := m.Pairs[pairKey(a, b)]
+	if !ok {
+		return nil, repository.ErrRecordNotFound
+	}
+	key := pairKey(a, b)
+	return &models.Friendship{UserID1: key[0], UserID2: key[1], Status: status}, nil
+}
+
+func (m *MockFriendshipRepository) CreateFriendship(ctx context.Context, a, b int64, status models.FriendshipStatus) (bool, error) {
+	if _, ok := m.Pairs[pairKey(a, b)]; ok {
+		return false, nil
+	}
+	m.Pairs[pairKey(a, b)] = status
+	return true, nil
+}
+
+func (m *MockFriendshipRepository) UpdateStatus(ctx context.Context, a, b int64, from, to models.FriendshipStatus) (int64, error) {
+	if status, ok := m.Pairs[pairKey(a, b)]; !ok || status != from {
+		return 0, nil
+	}
+	m.Pairs[pairKey(a, b)] = to
+	return 1, nil
+}
+
+func (m *MockFriendshipRepository) DeleteFriendship(ctx context.Context, a, b int64, status models.FriendshipStatus) (int64, error) {
+	if current, ok := m.Pairs[pairKey(a, b)]; !ok || current != status {
+		return 0, nil
+	}
+	delete(m.Pairs, pairKey(a, b))
+	return 1, nil
+}
+
+func (m *MockFriendshipRepository) GetIncomingRequests(ctx context.Context, userID int64) ([]models.FriendUser, error) {
+	return nil, nil
+}
+
+func (m *MockFriendshipRepository) GetOutgoingRequests(ctx context.Context, userID int64) ([]models.FriendUser, error) {
+	return nil, nil
+}
+
+func (m *MockFriendshipRepository) GetFriends(ctx context.Context, userID int64, limit, offset int) ([]models.FriendUser, int64, error) {
+	if m.GetFriendsFn != nil {
+		return m.GetFriendsFn(userID, limit, offset)
+	}
+	return nil, 0, nil
+}
+
+func (m *MockFriendshipRepository) TransitionPair(ctx context.Context, a, b int64, fn func(current *models.FriendshipStatus) (*models.FriendshipStatus, error)) error {
+	var current *models.FriendshipStatus
+	if status, ok := m.Pairs[pairKey(a, b)]; ok {
+		current = &status
+	}
+	next, err := fn(current)
+	if err != nil {
+		return err
+	}
+	if next == nil {
+		delete(m.Pairs, pairKey(a, b))
+	} else {
+		m.Pairs[pairKey(a, b)] = *next
+	}
+	return nil
+}
+
+func (m *MockFriendshipRepository) IsBlocked(ctx context.Context, a, b int64) (bool, error) {
+	switch m.status(a, b) {
+	case models.StatusBlocked1, models.StatusBlocked2, models.StatusBlocked12:
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m *MockFriendshipRepository) HasBlockWithVideoAuthor(ctx context.Context, userID, videoID int64) (bool, error) {
+	return false, nil
+}
+
+func (m *MockFriendshipRepository) GetBlockRelatedIDs(ctx context.Context, userID int64) ([]int64, error) {
+	return nil, nil
+}
+
+func (m *MockFriendshipRepository) GetBlockedUsers(ctx context.Context, userID int64) ([]models.FriendUser, error) {
+	return nil, nil
+}
+
+func (m *MockFriendshipRepository) GetFriendSuggestions(ctx context.Context, userID int64, limit int) ([]models.FriendSuggestion, error) {
+	return nil, nil
+}
+
+func (m *MockFriendshipRepository) DismissSuggestion(ctx context.Context, userID, dismissedUserID int64) error {
+	return nil
+}
+
+func TestFriendshipService_RequestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	repo := newMockFriendshipRepository(1, 2, 3)
+	s := NewFriendshipService(repo)
+
+	if _, err := s.SendRequest(ctx, 1, 1); !errors.Is(err, ErrCannotBefriendSelf) {
+		t.Errorf("SendRequest(self) error = %v, want ErrCannotBefriendSelf", err)
+	}
+	if _, err := s.SendRequest(ctx, 1, 99); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("SendRequest(unknown) error = %v, want ErrUserNotFound", err)
+	}
+
+	// 2 > 1, поэтому заявка 2 -> 1 хранится как pending2
+	status, err := s.SendRequest(ctx, 2, 1)
+	if err != nil || status != models.StatusPending2 {
+		t.Fatalf("SendRequest(2, 1) = %q, %v, want pending2", status, err)
+	}
+	if _, err := s.SendRequest(ctx, 2, 1); !errors.Is(err, ErrFriendRequestExists) {
+		t.Errorf("repeated SendRequest error = %v, want ErrFriendRequestExists", err)
+	}
+	// Только получатель может принять заявку
+	if err := s.AcceptRequest(ctx, 2, 1); !errors.Is(err, ErrFriendRequestNotFound) {
+		t.Errorf("AcceptRequest by sender error = %v, want ErrFriendRequestNotFound", err)
+	}
+	// Встречная заявка равносильна принятию
+	status, err = s.SendRequest(ctx, 1, 2)
+	if err != nil || status != models.StatusFriends {
+		t.Fatalf("counter SendRequest = %q, %v, want friends", status, err)
+	}
+	if _, err := s.SendRequest(ctx, 1, 2); !errors.Is(err, ErrAlreadyFriends) {
+		t.Errorf("SendRequest to friend error = %v, want ErrAlreadyFriends", err)
+	}
+	if err := s.Unfriend(ctx, 2, 1); err != nil {
+		t.Fatalf("Unfriend() error = %v", err)
+	}
+	if err := s.Unfriend(ctx, 2, 1); !errors.Is(err, ErrNotFriends) {
+		t.Errorf("repeated Unfriend error = %v, want ErrNotFriends", err)
+	}
+
+	if _, err := s.SendRequest(ctx, 1, 3); err != nil {
+		t.Fatalf("SendRequest(1, 3) error = %v", err)
+	}
+	if err := s.DeclineRequest(ctx, 1, 3); !errors.Is(err, ErrFriendRequestNotFound) {
+		t.Errorf("DeclineRequest by sender error = %v, want ErrFriendRequestNotFound", err)
+	}
+	if err := s.DeclineRequest(ctx, 3, 1); err != nil {
+		t.Fatalf("DeclineRequest() error = %v", err)
+	}
+	if _, err := s.SendRequest(ctx, 3, 1); err != nil {
+		t.Fatalf("SendRequest(3, 1) error = %v", err)
+	}
+	if err := s.CancelRequest(ctx, 3, 1); err != nil {
+		t.Fatalf("CancelRequest() error = %v", err)
+	}
+	if got := repo.status(1, 3); got != "" {
+		t.Errorf("pair status after cancel = %q, want no record", got)
+	}
+}
+
+func TestFriendshipService_ListFriendsClampsPage(t *testing.T) {
+	tests := []struct {
+		name                  string
+		limit, offset         int
+		wantLimit, wantOffset int
+	}{
+		{name: "Defaults", limit: 0, offset: -5, wantLimit: DefaultFriendsPageSize, wantOffset: 0},
+		{name: "TooLarge", limit: 1000, offset: 40, wantLimit: MaxFriendsPageSize, wantOffset: 40},
+		{name: "AsRequested", limit: 10, offset: 10, wantLimit: 10, wantOffset: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockFriendshipRepository()
+			repo.GetFriendsFn = func(userID int64, limit, offset int) ([]models.FriendUser, int64, error) {
+				if limit != tt.wantLimit || offset != tt.wantOffset {
+					t.Errorf("GetFriends(limit=%d, offset=%d), want %d, %d", limit, offset, tt.wantLimit, tt.wantOffset)
+				}
+				return []models.FriendUser{{UserID: 2}}, 41, nil
+			}
+			page, err := NewFriendshipService(repo).ListFriends(context.Background(), 1, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("ListFriends() error = %v", err)
+			}
+			if page.Limit != tt.wantLimit || page.Offset != tt.wantOffset || page.Total != 41 || len(page.Items) != 1 {
+				t.Errorf("ListFriends() = %+v, want limit %d offset %d", page, tt.wantLimit, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestFriendshipService_BlockTransitions(t *testing.T) {
+	ctx := context.Background()
+	repo := newMockFriendshipRepository(1, 2)
+	s := NewFriendshipService(repo)
+
+	steps := []struct {
+		name    string
+		do      func() error
+		wantErr error
+		want    models.FriendshipStatus
+	}{
+		{"Befriend", func() error {
+			if _, err := s.SendRequest(ctx, 1, 2); err != nil {
+				return err
+			}
+			return s.AcceptRequest(ctx, 2, 1)
+		}, nil, models.StatusFriends},
+		{"UnblockNotBlocked", func() error { return s.Unblock(ctx, 1, 2) }, ErrNotBlocked, models.StatusFriends},
+		{"BlockSelf", func() error { return s.Block(ctx, 1, 1) }, ErrCannotBlockSelf, models.StatusFriends},
+		// Блокировка замещает дружбу
+		{"SecondBlocksFirst", func() error { return s.Block(ctx, 2, 1) }, nil, models.StatusBlocked2},
+		{"RepeatBlock", func() error { return s.Block(ctx, 2, 1) }, nil, models.StatusBlocked2},
+		{"BlockedSends", func() error { _, err := s.SendRequest(ctx, 1, 2); return err }, ErrUserBlocked, models.StatusBlocked2},
+		{"BlockerSends", func() error { _, err := s.SendRequest(ctx, 2, 1); return err }, ErrUserBlocked, models.StatusBlocked2},
+		// Заблокированный не может снять чужую блокировку
+		{"BlockedUnblocks", func() error { return s.Unblock(ctx, 1, 2) }, ErrNotBlocked, models.StatusBlocked2},
+		{"MutualBlock", func() error { return s.Block(ctx, 1, 2) }, nil, models.StatusBlocked12},
+		{"MutualSend", func() error { _, err := s.SendRequest(ctx, 1, 2); return err }, ErrUserBlocked, models.StatusBlocked12},
+		// Снятие своей блокировки оставляет встречную
+		{"FirstUnblocks", func() error { return s.Unblock(ctx, 1, 2) }, nil, models.StatusBlocked2},
+		{"SecondUnblocks", func() error { return s.Unblock(ctx, 2, 1) }, nil, ""},
+		// Дружба после разблокировки не возвращается, но заявки снова разрешены
+		{"SendAfterUnblock", func() error { _, err := s.SendRequest(ctx, 2, 1); return err }, nil, models.StatusPending2},
+		{"BlockPending", func() error { return s.Block(ctx, 1, 2) }, nil, models.StatusBlocked1},
+	}
+	for _, step := range steps {
+		if err := step.do(); !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: error = %v, want %v", step.name, err, step.wantErr)
+		}
+		if got := repo.status(1, 2); got != step.want {
+			t.Fatalf("%s: pair status = %q, want %q", step.name, got, step.want)
+		}
+	}
+}
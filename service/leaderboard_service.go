@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+var ErrInvalidLeaderboardScope = errors.New("scope must be global, weekly or friends")
+var ErrInvalidLeaderboardCursor = errors.New("invalid leaderboard cursor")
+
+const (
+	DefaultLeaderboardPageSize = 20
+	MaxLeaderboardPageSize     = 100
+
+	// DefaultLeaderboardRefresh - как часто пересчитываются рейтинги
+	DefaultLeaderboardRefresh = 5 * time.Minute
+)
+
+// LeaderboardService отдает таблицы рейтинга ("просмотр таблицы рейтинга").
+// Данные берутся из материализованных рейтингов и отстают не больше чем
+// на интервал RunRefresher.
+type LeaderboardService interface {
+	GetPage(ctx context.Context, scope models.LeaderboardScope, viewerID int64, cursor string, limit int) (*LeaderboardPage, error)
+	// RunRefresher пересчитывает рейтинги, пока не отменен ctx
+	RunRefresher(ctx context.Context, interval time.Duration)
+}
+
+// LeaderboardPage - страница рейтинга; Me - место зрителя, даже если его
+// нет на странице (nil, если его нет в рейтинге, например без очков за неделю)
+type LeaderboardPage struct {
+	Scope      models.LeaderboardScope   `json:"scope"`
+	WeekStart  string                    `json:"week_start,omitempty"`
+	Items      []models.LeaderboardEntry `json:"items"`
+	NextCursor string                    `json:"next_cursor"`
+	Me         *models.LeaderboardEntry  `json:"me"`
+}
+
+type leaderboardServiceImpl struct {
+	Repo repository.LeaderboardRepository
+
+	now func() time.Time
+}
+
+func NewLeaderboardService(repo repository.LeaderboardRepository) LeaderboardService {
+	return &leaderboardServiceImpl{Repo: repo, now: time.Now}
+}
+
+func (s *leaderboardServiceImpl) GetPage(ctx context.Context, scope models.LeaderboardScope, viewerID int64, cursor string, limit int) (*LeaderboardPage, error) {
+	if !scope.IsValid() {
+		return nil, ErrInvalidLeaderboardScope
+	}
+	if limit <= 0 {
+		limit = DefaultLeaderboardPageSize
+	}
+	if limit > MaxLeaderboardPageSize {
+		limit = MaxLeaderboardPageSize
+	}
+	var after *models.LeaderboardCursor
+	if cursor != "" {
+		decoded, err := decodeLeaderboardCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &decoded
+	}
+
+	// Лишний элемент показывает, есть ли следующая страница
+	entries, err := s.Repo.List(ctx, scope, viewerID, after, limit+1)
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch %s leaderboard for user %d: %v", scope, viewerID, err)
+		return nil, err
+	}
+
+	page := &LeaderboardPage{Scope: scope, Items: entries}
+	if len(entries) > limit {
+		page.Items = entries[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeLeaderboardCursor(models.LeaderboardCursor{Score: last.Score, UserID: last.User.UserID})
+	}
+	if page.Items == nil {
+		page.Items = []models.LeaderboardEntry{}
+	}
+	if scope == models.LeaderboardWeekly {
+		page.WeekStart = isoWeekStart(s.now()).Format("2006-01-02")
+	}
+
+	me, err := s.Repo.Rank(ctx, scope, viewerID)
+	if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+		log.Printf("ERROR: Failed to fetch %s rank of user %d: %v", scope, viewerID, err)
+		return nil, err
+	}
+	page.Me = me
+	return page, nil
+}
+
+func (s *leaderboardServiceImpl) RunRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		started := time.Now()
+		if err := s.Repo.Refresh(ctx); err != nil {
+			log.Printf("ERROR: Failed to refresh leaderboards: %v", err)
+		} else {
+			log.Printf("INFO: Leaderboards refreshed in %s", time.Since(started))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// isoWeekStart - понедельник ISO-недели t в UTC (как date_trunc('week') в leaderboard_weekly)
+func isoWeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// Курсор непрозрачен для клиента: base64 от "<score>.<user_id>"
+func encodeLeaderboardCursor(c models.LeaderboardCursor) string {
+	raw := strconv.FormatInt(c.Score, 10) + "." + strconv.FormatInt(c.UserID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeLeaderboardCursor(cursor string) (models.LeaderboardCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return models.LeaderboardCursor{}, ErrInvalidLeaderboardCursor
+	}
+	score, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return models.LeaderboardCursor{}, ErrInvalidLeaderboardCursor
+	}
+	scoreValue, err := strconv.ParseInt(score, 10, 64)
+	if err != nil || scoreValue < 0 {
+		return models.LeaderboardCursor{}, ErrInvalidLeaderboardCursor
+	}
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || userID <= 0 {
+		return models.LeaderboardCursor{}, ErrInvalidLeaderboardCursor
+	}
+	return models.LeaderboardCursor{Score: scoreValue, UserID: userID}, nil
+}
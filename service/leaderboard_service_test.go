@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+// MockLeaderboardRepository отдает заранее отсортированный рейтинг
+type MockLeaderboardRepository struct {
+	Entries []models.LeaderboardEntry
+}
+
+func (m *MockLeaderboardRepository) List(ctx context.Context, scope models.LeaderboardScope, viewerID int64, after *models.LeaderboardCursor, limit int) ([]models.LeaderboardEntry, error) {
+	var page []models.LeaderboardEntry
+	for _, entry := range m.Entries {
+		if after != nil && (entry.Score > after.Score || entry.Score == after.Score && entry.User.UserID <= after.UserID) {
+			continue
+		}
+		if len(page) < limit {
+			page = append(page, entry)
+		}
+	}
+	return page, nil
+}
+
+func (m *MockLeaderboardRepository) Rank(ctx context.Context, scope models.LeaderboardScope, viewerID int64) (*models.LeaderboardEntry, error) {
+	for _, entry := range m.Entries {
+		if entry.User.UserID == viewerID {
+			return &entry, nil
+		}
+	}
+	return nil, repository.ErrRecordNotFound
+}
+
+func (m *MockLeaderboardRepository) Refresh(ctx context.Context) error {
+	return nil
+}
+
+func TestLeaderboardService_GetPage(t *testing.T) {
+	ctx := context.Background()
+	entry := func(rank, score, userID int64) models.LeaderboardEntry {
+		return models.LeaderboardEntry{Rank: rank, Score: score, User: models.FeedAuthor{UserID: userID}}
+	}
+	// Равные очки делят место, порядок внутри - по user_id
+	repo := &MockLeaderboardRepository{Entries: []models.LeaderboardEntry{
+		entry(1, 50, 4), entry(2, 30, 1), entry(2, 30, 7), entry(4, 10, 2), entry(5, 0, 9),
+	}}
+	s := NewLeaderboardService(repo)
+
+	var got []int64
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("pagination does not terminate, got %v", got)
+		}
+		page, err := s.GetPage(ctx, models.LeaderboardGlobal, 9, cursor, 2)
+		if err != nil {
+			t.Fatalf("GetPage() error = %v", err)
+		}
+		// Место зрителя есть на каждой странице, хотя сам он только на последней
+		if page.Me == nil || page.Me.Rank != 5 {
+			t.Errorf("GetPage() me = %+v, want rank 5", page.Me)
+		}
+		for _, item := range page.Items {
+			got = append(got, item.User.UserID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if !reflect.DeepEqual(got, []int64{4, 1, 7, 2, 9}) {
+		t.Errorf("GetPage() pages = %v, want [4 1 7 2 9]", got)
+	}
+
+	page, err := s.GetPage(ctx, models.LeaderboardWeekly, 100, "", 0)
+	if err != nil {
+		t.Fatalf("GetPage() error = %v", err)
+	}
+	if page.Me != nil || len(page.Items) != 5 || page.WeekStart == "" {
+		t.Errorf("GetPage(weekly) = %+v, want all items, week_start and no rank for unranked viewer", page)
+	}
+
+	if _, err := s.GetPage(ctx, "monthly", 9, "", 10); !errors.Is(err, ErrInvalidLeaderboardScope) {
+		t.Errorf("GetPage(monthly) error = %v, want ErrInvalidLeaderboardScope", err)
+	}
+	for _, bad := range []string{"not base64!", base64URL("10"), base64URL("-1.5"), base64URL("10.0")} {
+		if _, err := s.GetPage(ctx, models.LeaderboardGlobal, 9, bad, 10); !errors.Is(err, ErrInvalidLeaderboardCursor) {
+			t.Errorf("GetPage(cursor=%q) error = %v, want ErrInvalidLeaderboardCursor", bad, err)
+		}
+	}
+}
+
+func TestISOWeekStart(t *testing.T) {
+	tests := []struct {
+		now  time.Time
+		want string
+	}{
+		{time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), "2026-03-09"},    // понедельник
+		{time.Date(2026, 3, 15, 23, 59, 0, 0, time.UTC), "2026-03-09"}, // воскресенье
+		{time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), "2025-12-29"},   // неделя через границу года
+	}
+	for _, tt := range tests {
+		if got := isoWeekStart(tt.now).Format("2006-01-02"); got != tt.want {
+			t.Errorf("isoWeekStart(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
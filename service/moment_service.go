@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/push"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+const (
+	// DefaultMomentWindow - сколько длится окно после уведомления
+	DefaultMomentWindow = 2 * time.Minute
+
+	// Момент выпадает на локальное время с momentEarliest до momentLatest
+	momentEarliest = 9 * time.Hour
+	momentLatest   = 22 * time.Hour
+
+	// NotificationMoment - тип события "пора публиковать"
+	NotificationMoment = "moment"
+)
+
+// MomentService управляет "моментом дня": случайным окном публикации,
+// общим для всех пользователей одного часового пояса
+type MomentService interface {
+	// Today - момент текущего локального дня пользователя
+	Today(ctx context.Context, userID int64) (*models.DailyMoment, error)
+	// RunScheduler создает моменты и рассылает уведомления, пока не отменен ctx
+	RunScheduler(ctx context.Context, interval time.Duration)
+}
+
+type momentServiceImpl struct {
+	Repo     repository.MomentRepository
+	Notifier push.Notifier
+	Window   time.Duration
+
+	now  func() time.Time
+	rand func(n int64) int64
+
+	// ensured - до какой локальной даты моменты пояса уже созданы,
+	// чтобы не ходить в БД каждый тик
+	mu      sync.Mutex
+	ensured map[string]string
+}
+
+func NewMomentService(repo repository.MomentRepository, notifier push.Notifier, window time.Duration) MomentService {
+	if window <= 0 {
+		window = DefaultMomentWindow
+	}
+	return &momentServiceImpl{
+		Repo:     repo,
+		Notifier: notifier,
+		Window:   window,
+		now:      time.Now,
+		rand:     rand.Int63n,
+		ensured:  map[string]string{},
+	}
+}
+
+func (s *momentServiceImpl) Today(ctx context.Context, userID int64) (*models.DailyMoment, error) {
+	timezone, err := s.Repo.GetUserTimezone(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := models.LoadTimezone(timezone); err != nil {
+		log.Printf("WARNING: User %d has invalid timezone %q, using %s", userID, timezone, models.DefaultTimezone)
+		timezone = models.DefaultTimezone
+	}
+	// Момент мог еще не создаться планировщиком (новый пояс) - создаем сразу
+	return s.ensure(ctx, timezone, s.now())
+}
+
+// ensure возвращает момент дня, в который попадает t в поясе timezone,
+// создавая его при необходимости
+func (s *momentServiceImpl) ensure(ctx context.Context, timezone string, t time.Time) (*models.DailyMoment, error) {
+	loc, err := models.LoadTimezone(timezone)
+	if err != nil {
+		return nil, err
+	}
+	dayStart, _ := models.LocalDay(t, loc)
+	localDate := models.CalendarDate(dayStart)
+
+	moment, err := s.Repo.Get(ctx, timezone, localDate)
+	if err == nil {
+		return moment, nil
+	}
+	if !errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	startsAt := pickMomentStart(dayStart, s.Window, s.rand)
+	moment, err = s.Repo.CreateIfMissing(ctx, &models.DailyMoment{
+		Timezone:  timezone,
+		LocalDate: localDate,
+		StartsAt:  startsAt,
+		Deadline:  startsAt.Add(s.Window),
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: Moment for %s on %s is at %s", timezone, moment.Date(), moment.StartsAt.In(loc).Format("15:04"))
+	return moment, nil
+}
+
+// ensureDays создает моменты на сегодня и завтра: завтрашний нужен заранее,
+// чтобы клиенты могли узнать окно сразу после полуночи
+func (s *momentServiceImpl) ensureDays(ctx context.Context, timezone string, now time.Time) error {
+	for _, t := range []time.Time{now, now.Add(24 * time.Hour)} {
+		if _, err := s.ensure(ctx, timezone, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pickMomentStart выбирает случайную минуту между momentEarliest и
+// momentLatest-window локального дня dayStart. Время считается через
+// time.Date, поэтому переход на летнее время не сдвигает окно за пределы дня.
+func pickMomentStart(dayStart time.Time, window time.Duration, randN func(int64) int64) time.Time {
+	minutes := int64((momentLatest - momentEarliest - window) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	offset := time.Duration(randN(minutes))*time.Minute + momentEarliest
+	return time.Date(dayStart.Year(), dayStart.Month(), dayStart.Day(), 0, int(offset/time.Minute), 0, 0, dayStart.Location())
+}
+
+func (s *momentServiceImpl) RunScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.tick(ctx); err != nil {
+			log.Printf("ERROR: Moment scheduler: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick готовит моменты на сегодня и завтра для всех поясов и рассылает
+// уведомления по открывшимся окнам
+func (s *momentServiceImpl) tick(ctx context.Context) error {
+	timezones, err := s.Repo.ListTimezones(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, timezone := range timezones {
+		loc, err := models.LoadTimezone(timezone)
+		if err != nil {
+			continue
+		}
+		tomorrow := now.Add(24 * time.Hour).In(loc).Format("2006-01-02")
+		s.mu.Lock()
+		done := s.ensured[timezone] == tomorrow
+		s.mu.Unlock()
+		if done {
+			continue
+		}
+		if err := s.ensureDays(ctx, timezone, now); err != nil {
+			log.Printf("ERROR: Failed to schedule moment for %s: %v", timezone, err)
+			continue
+		}
+		s.mu.Lock()
+		s.ensured[timezone] = tomorrow
+		s.mu.Unlock()
+	}
+
+	due, err := s.Repo.ClaimDue(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, moment := range due {
+		userIDs, err := s.Repo.ListUserIDsByTimezone(ctx, moment.Timezone)
+		if err != nil {
+			log.Printf("ERROR: Failed to load users of %s for moment: %v", moment.Timezone, err)
+			continue
+		}
+		notification := push.Notification{
+			Type:  NotificationMoment,
+			Title: "Time to post!",
+			Body:  "Share your moment before the window closes",
+			Data: map[string]interface{}{
+				"date":         moment.Date(),
+				"window_start": moment.StartsAt,
+				"deadline":     moment.Deadline,
+			},
+		}
+		if err := s.Notifier.Notify(ctx, userIDs, notification); err != nil {
+			log.Printf("ERROR: Failed to notify %s about moment: %v", moment.Timezone, err)
+		}
+	}
+	return nil
+}
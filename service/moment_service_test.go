@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/push"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+// MockMomentRepository хранит моменты в памяти
+type MockMomentRepository struct {
+	Moments   map[string]*models.DailyMoment
+	Timezones map[int64]string
+	Created   int
+}
+
+func newMockMomentRepository() *MockMomentRepository {
+	return &MockMomentRepository{Moments: map[string]*models.DailyMoment{}, Timezones: map[int64]string{}}
+}
+
+func momentKey(timezone string, localDate time.Time) string {
+	return timezone + "/" + localDate.Format("2006-01-02")
+}
+
+func (m *MockMomentRepository) Get(ctx context.Context, timezone string, localDate time.Time) (*models.DailyMoment, error) {
+	moment, ok := m.Moments[momentKey(timezone, localDate)]
+	if !ok {
+		return nil, repository.ErrRecordNotFound
+	}
+	copied := *moment
+	return &copied, nil
+}
+
+func (m *MockMomentRepository) CreateIfMissing(ctx context.Context, moment *models.DailyMoment) (*models.DailyMoment, error) {
+	key := momentKey(moment.Timezone, moment.LocalDate)
+	if _, ok := m.Moments[key]; !ok {
+		copied := *moment
+		m.Moments[key] = &copied
+		m.Created++
+	}
+	return m.Get(ctx, moment.Timezone, moment.LocalDate)
+}
+
+func (m *MockMomentRepository) ClaimDue(ctx context.Context, now time.Time) ([]models.DailyMoment, error) {
+	var due []models.DailyMoment
+	for _, moment := range m.Moments {
+		if moment.NotifiedAt == nil && !moment.StartsAt.After(now) && moment.Deadline.After(now) {
+			notifiedAt := now
+			moment.NotifiedAt = &notifiedAt
+			due = append(due, *moment)
+		}
+	}
+	return due, nil
+}
+
+func (m *MockMomentRepository) ListTimezones(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	var timezones []string
+	for _, timezone := range m.Timezones {
+		if !seen[timezone] {
+			seen[timezone] = true
+			timezones = append(timezones, timezone)
+		}
+	}
+	return timezones, nil
+}
+
+func (m *MockMomentRepository) ListUserIDsByTimezone(ctx context.Context, timezone string) ([]int64, error) {
+	var ids []int64
+	for id, tz := range m.Timezones {
+		if tz == timezone {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *MockMomentRepository) GetUserTimezone(ctx context.Context, userID int64) (string, error) {
+	return m.Timezones[userID], nil
+}
+
+type recordingNotifier struct {
+	Sent []push.Notification
+	To   [][]int64
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, userIDs []int64, notification push.Notification) error {
+	n.Sent = append(n.Sent, notification)
+	n.To = append(n.To, userIDs)
+	return nil
+}
+
+func newTestMomentService(repo *MockMomentRepository, notifier push.Notifier, now time.Time, offset int64) *momentServiceImpl {
+	s := NewMomentService(repo, notifier, DefaultMomentWindow).(*momentServiceImpl)
+	s.now = func() time.Time { return now }
+	s.rand = func(n int64) int64 { return offset }
+	return s
+}
+
+func TestPickMomentStart(t *testing.T) {
+	minsk, _ := time.LoadLocation("Europe/Minsk")
+	dayStart := time.Date(2026, 3, 10, 0, 0, 0, 0, minsk)
+
+	earliest := pickMomentStart(dayStart, DefaultMomentWindow, func(n int64) int64 { return 0 })
+	if want := time.Date(2026, 3, 10, 9, 0, 0, 0, minsk); !earliest.Equal(want) {
+		t.Errorf("earliest = %v, want %v", earliest, want)
+	}
+	latest := pickMomentStart(dayStart, DefaultMomentWindow, func(n int64) int64 { return n - 1 })
+	if deadline := latest.Add(DefaultMomentWindow); deadline.After(time.Date(2026, 3, 10, 22, 0, 0, 0, minsk)) {
+		t.Errorf("deadline %v is after 22:00", deadline)
+	}
+
+	// В день перехода на летнее время окно остается в пределах 9:00-22:00
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	dstDay := time.Date(2026, 3, 29, 0, 0, 0, 0, berlin)
+	start := pickMomentStart(dstDay, DefaultMomentWindow, func(n int64) int64 { return 0 })
+	if start.Hour() != 9 || start.Minute() != 0 {
+		t.Errorf("DST start = %v, want 09:00 local", start)
+	}
+}
+
+func TestMomentService_Today(t *testing.T) {
+	repo := newMockMomentRepository()
+	repo.Timezones[1] = "Europe/Minsk"
+	repo.Timezones[2] = "Europe/Minsk"
+	repo.Timezones[3] = "Bad/Zone"
+	// 23:30 UTC 9 марта - в Минске уже 10 марта
+	now := time.Date(2026, 3, 9, 23, 30, 0, 0, time.UTC)
+	s := newTestMomentService(repo, &recordingNotifier{}, now, 60)
+
+	first, err := s.Today(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Today() error = %v", err)
+	}
+	if first.Timezone != "Europe/Minsk" || first.Date() != "2026-03-10" {
+		t.Errorf("moment = %s %s, want Europe/Minsk 2026-03-10", first.Timezone, first.Date())
+	}
+	// 9:00 + 60 минут по Минску (UTC+3)
+	if want := time.Date(2026, 3, 10, 7, 0, 0, 0, time.UTC); !first.StartsAt.Equal(want) {
+		t.Errorf("StartsAt = %v, want %v", first.StartsAt, want)
+	}
+	if first.Deadline.Sub(first.StartsAt) != DefaultMomentWindow {
+		t.Errorf("window = %v, want %v", first.Deadline.Sub(first.StartsAt), DefaultMomentWindow)
+	}
+
+	// Пользователи одного пояса получают один и тот же момент
+	s.rand = func(n int64) int64 { return 0 }
+	second, err := s.Today(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("Today() error = %v", err)
+	}
+	if !second.StartsAt.Equal(first.StartsAt) || repo.Created != 1 {
+		t.Errorf("expected shared moment, got %v (created %d)", second.StartsAt, repo.Created)
+	}
+
+	invalid, err := s.Today(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Today() error = %v", err)
+	}
+	if invalid.Timezone != models.DefaultTimezone {
+		t.Errorf("timezone = %q, want fallback %q", invalid.Timezone, models.DefaultTimezone)
+	}
+}
+
+func TestMomentService_TickNotifiesOnce(t *testing.T) {
+	repo := newMockMomentRepository()
+	repo.Timezones[1] = "UTC"
+	repo.Timezones[2] = "UTC"
+	notifier := &recordingNotifier{}
+	now := time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC)
+	s := newTestMomentService(repo, notifier, now, 30)
+
+	// До окна моменты на сегодня и завтра создаются, но уведомлений нет
+	if err := s.tick(context.Background()); err != nil {
+		t.Fatalf("tick() error = %v", err)
+	}
+	if repo.Created != 2 || len(notifier.Sent) != 0 {
+		t.Fatalf("created %d moments, sent %d, want 2 and 0", repo.Created, len(notifier.Sent))
+	}
+
+	s.now = func() time.Time { return time.Date(2026, 3, 10, 9, 31, 0, 0, time.UTC) }
+	for i := 0; i < 2; i++ {
+		if err := s.tick(context.Background()); err != nil {
+			t.Fatalf("tick() error = %v", err)
+		}
+	}
+	if len(notifier.Sent) != 1 {
+		t.Fatalf("sent %d notifications, want 1", len(notifier.Sent))
+	}
+	if notifier.Sent[0].Type != NotificationMoment || len(notifier.To[0]) != 2 {
+		t.Errorf("unexpected notification %+v to %v", notifier.Sent[0], notifier.To[0])
+	}
+	if repo.Created != 2 {
+		t.Errorf("created %d moments, want 2", repo.Created)
+	}
+}
+
+func TestVideoService_UploadVideoMarksLatePosts(t *testing.T) {
+	tests := []struct {
+		name     string
+		now      time.Time
+		wantLate bool
+	}{
+		{"OnTime", time.Date(2026, 3, 10, 10, 1, 0, 0, time.UTC), false},
+		{"Late", time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC), true},
+		{"BeforeWindow", time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moments := newMockMomentRepository()
+			moments.Timezones[1] = "UTC"
+			momentService := newTestMomentService(moments, &recordingNotifier{}, tt.now, 60)
+
+			var created *models.Video
+			repo := &MockVideoRepository{CreateVideoFn: func(ctx context.Context, video *models.Video) error {
+				created = video
+				return nil
+			}}
+			s := NewVideoService(repo, WithBlobStore(&MockBlobStore{}), WithMomentService(momentService)).(*videoServiceImpl)
+			s.now = func() time.Time { return tt.now }
+
+			data := testVideoFile(5)
+			if _, err := s.UploadVideo(context.Background(), 1, "", strings.NewReader(string(data)), int64(len(data))); err != nil {
+				t.Fatalf("UploadVideo() error = %v", err)
+			}
+			if created.MomentDate == nil || created.MomentDate.Format("2006-01-02") != "2026-03-10" {
+				t.Errorf("MomentDate = %v, want 2026-03-10", created.MomentDate)
+			}
+			if created.PostedLate != tt.wantLate {
+				t.Errorf("PostedLate = %v, want %v", created.PostedLate, tt.wantLate)
+			}
+		})
+	}
+}
+
+// failingMomentService - MomentService, у которого не загружается момент дня
+type failingMomentService struct{}
+
+func (failingMomentService) Today(ctx context.Context, userID int64) (*models.DailyMoment, error) {
+	return nil, errTestDB
+}
+
+func (failingMomentService) RunScheduler(ctx context.Context, interval time.Duration) {}
+
+func TestVideoService_UploadVideoWithoutMoment(t *testing.T) {
+	now := time.Date(2026, 3, 10, 23, 30, 0, 0, time.UTC)
+	var created *models.Video
+	repo := &MockVideoRepository{
+		CreateVideoFn: func(ctx context.Context, video *models.Video) error {
+			created = video
+			return nil
+		},
+		GetUserTimezoneFn: func(ctx context.Context, userID int64) (string, error) {
+			return "Europe/Minsk", nil
+		},
+	}
+	s := NewVideoService(repo, WithBlobStore(&MockBlobStore{}), WithMomentService(failingMomentService{})).(*videoServiceImpl)
+	s.now = func() time.Time { return now }
+
+	// Ошибка момента не отменяет публикацию: дата берется из пояса автора
+	data := testVideoFile(5)
+	if _, err := s.UploadVideo(context.Background(), 1, "", strings.NewReader(string(data)), int64(len(data))); err != nil {
+		t.Fatalf("UploadVideo() error = %v", err)
+	}
+	if created.MomentDate == nil || created.MomentDate.Format("2006-01-02") != "2026-03-11" {
+		t.Errorf("MomentDate = %v, want 2026-03-11 in Europe/Minsk", created.MomentDate)
+	}
+	if created.PostedLate {
+		t.Error("PostedLate = true, want false without a moment")
+	}
+}
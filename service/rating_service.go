@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+const (
+	// Очки за публикацию момента: вовремя и с опозданием
+	PostPoints     = 10
+	LatePostPoints = 5
+
+	// DefaultRatingHalfLife - за это время очки события уменьшаются вдвое
+	DefaultRatingHalfLife = 30 * 24 * time.Hour
+	// RatingDecayInterval - как часто рейтинг пересчитывается с затуханием
+	RatingDecayInterval = time.Hour
+)
+
+// DefaultReactionWeights - очки автору за реакцию каждого вида
+var DefaultReactionWeights = map[models.ReactionKind]int{
+	models.ReactionHeart: 3,
+	models.ReactionFlame: 5,
+	models.ReactionFunny: 2,
+	models.ReactionAngry: 1,
+}
+
+// decayedRating - рейтинг каждого пользователя на момент now: очки корзины
+// уменьшаются вдвое за каждый halfLife, сумма округляется и не уходит ниже 0
+func decayedRating(buckets []models.RatingBucket, now time.Time, halfLife time.Duration) map[int64]int {
+	sums := make(map[int64]float64)
+	for _, b := range buckets {
+		age := now.Sub(b.Hour)
+		if age < 0 {
+			age = 0
+		}
+		sums[b.UserID] += float64(b.Points) * math.Pow(0.5, float64(age)/float64(halfLife))
+	}
+	ratings := make(map[int64]int, len(sums))
+	for userID, sum := range sums {
+		ratings[userID] = max(0, int(math.Round(sum)))
+	}
+	return ratings
+}
+
+// streakMilestones - очки за достижение длины серии
+var streakMilestones = map[int]int{
+	3:   10,
+	7:   30,
+	30:  150,
+	100: 500,
+	365: 2000,
+}
+
+// RatingService начисляет рейтинг за публикации, серии и полученные реакции.
+// Каждое начисление пишется в журнал; users.rating - сумма очков журнала с
+// затуханием, которую RunDecay и Recompute пересчитывают из журнала.
+// Между пересчетами рейтинг приближенный: к последней сумме с затуханием
+// новые события прибавляются полным весом, точное значение появится при
+// следующем пересчете (раз в RatingDecayInterval).
+type RatingService interface {
+	// RecordPost начисляет очки за первую публикацию локального дня day;
+	// пересъемки и повторные загрузки после удаления очков не приносят
+	RecordPost(ctx context.Context, userID, videoID int64, day time.Time, late bool) error
+	// RecordStreak начисляет очки, если streak - рубеж серии
+	RecordStreak(ctx context.Context, userID int64, streak int) error
+	// RecordReaction приводит очки автора videoID к текущей реакции reactorID
+	// (после установки, смены или снятия) и обновляет MaxReactions автора
+	RecordReaction(ctx context.Context, reactorID, videoID int64) error
+	// Recompute пересчитывает рейтинг из журнала; userID 0 - всех
+	Recompute(ctx context.Context, userID int64) (int64, error)
+	// RunDecay применяет затухание, пока не отменен ctx
+	RunDecay(ctx context.Context, interval time.Duration)
+}
+
+type ratingServiceImpl struct {
+	Repo     repository.RatingRepository
+	Weights  map[models.ReactionKind]int
+	HalfLife time.Duration
+
+	now func() time.Time
+}
+
+// NewRatingService: weights nil - DefaultReactionWeights, halfLife 0 - DefaultRatingHalfLife
+func NewRatingService(repo repository.RatingRepository, weights map[models.ReactionKind]int, halfLife time.Duration) RatingService {
+	if weights == nil {
+		weights = DefaultReactionWeights
+	}
+	if halfLife <= 0 {
+		halfLife = DefaultRatingHalfLife
+	}
+	return &ratingServiceImpl{Repo: repo, Weights: weights, HalfLife: halfLife, now: time.Now}
+}
+
+func (s *ratingServiceImpl) RecordPost(ctx context.Context, userID, videoID int64, day time.Time, late bool) error {
+	points := PostPoints
+	if late {
+		points = LatePostPoints
+	}
+	event := &models.RatingEvent{UserID: userID, Kind: models.RatingPost, Points: points, VideoID: &videoID, MomentDate: &day}
+	appended, err := s.Repo.Append(ctx, event)
+	if err != nil {
+		return err
+	}
+	if !appended {
+		log.Printf("INFO: Post points of user %d for %s are already credited", userID, day.Format("2006-01-02"))
+	}
+	return nil
+}
+
+func (s *ratingServiceImpl) RecordStreak(ctx context.Context, userID int64, streak int) error {
+	points, ok := streakMilestones[streak]
+	if !ok {
+		return nil
+	}
+	log.Printf("INFO: User %d reached a %d-day streak, +%d rating", userID, streak, points)
+	_, err := s.Repo.Append(ctx, &models.RatingEvent{UserID: userID, Kind: models.RatingStreak, Points: points})
+	return err
+}
+
+func (s *ratingServiceImpl) RecordReaction(ctx context.Context, reactorID, videoID int64) error {
+	event, err := s.Repo.SettleReaction(ctx, reactorID, videoID, s.reactionPoints(reactorID))
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		// Видео удалено вместе с реакциями, начислять нечего
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if event == nil {
+		return nil
+	}
+
+	record, err := s.Repo.UpdateMaxReactions(ctx, event.UserID, videoID)
+	if err != nil {
+		return err
+	}
+	if record {
+		log.Printf("INFO: Video %d set a new reactions record for user %d", videoID, event.UserID)
+	}
+	return nil
+}
+
+// reactionPoints - сколько очков должна приносить реакция reactorID; за свои
+// видео и снятые реакции - 0
+func (s *ratingServiceImpl) reactionPoints(reactorID int64) func(authorID int64, kind *models.ReactionKind) int {
+	return func(authorID int64, kind *models.ReactionKind) int {
+		if kind == nil || authorID == reactorID {
+			return 0
+		}
+		return s.Weights[*kind]
+	}
+}
+
+func (s *ratingServiceImpl) Recompute(ctx context.Context, userID int64) (int64, error) {
+	buckets, err := s.Repo.LedgerBuckets(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	updated, err := s.Repo.SetRatings(ctx, userID, decayedRating(buckets, s.now(), s.HalfLife))
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("INFO: Ratings recomputed from the ledger, %d users changed", updated)
+	return updated, nil
+}
+
+func (s *ratingServiceImpl) RunDecay(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := s.Recompute(ctx, 0); err != nil {
+			log.Printf("ERROR: Rating decay: %v", err)
+		}
+	}
+}
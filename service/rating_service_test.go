@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+// MockRatingRepository ведет журнал в памяти; Reactions - текущие реакции
+// по ключу [reactor, video], Authors - авторы видео
+type MockRatingRepository struct {
+	Events    []models.RatingEvent
+	Reactions map[[2]int64]models.ReactionKind
+	Authors   map[int64]int64
+	// MaxChecked - для каких видео проверялся рекорд реакций
+	MaxChecked []int64
+	// Ratings - users.rating; SetCalls - число вызовов SetRatings
+	Ratings  map[int64]int
+	SetCalls int
+}
+
+// Append повторяет уникальный индекс idx_rating_events_post_day
+func (m *MockRatingRepository) Append(ctx context.Context, event *models.RatingEvent) (bool, error) {
+	if event.Kind == models.RatingPost {
+		for _, e := range m.Events {
+			if e.Kind == models.RatingPost && e.UserID == event.UserID && e.MomentDate.Equal(*event.MomentDate) {
+				return false, nil
+			}
+		}
+	}
+	m.Events = append(m.Events, *event)
+	return true, nil
+}
+
+func (m *MockRatingRepository) SettleReaction(ctx context.Context, reactorID, videoID int64, weigh func(authorID int64, kind *models.ReactionKind) int) (*models.RatingEvent, error) {
+	authorID, ok := m.Authors[videoID]
+	if !ok {
+		return nil, repository.ErrRecordNotFound
+	}
+	var kind *models.ReactionKind
+	if k, ok := m.Reactions[[2]int64{reactorID, videoID}]; ok {
+		kind = &k
+	}
+	credited := 0
+	for _, e := range m.Events {
+		if e.Kind == models.RatingReaction && *e.VideoID == videoID && *e.ActorID == reactorID {
+			credited += e.Points
+		}
+	}
+	delta := weigh(authorID, kind) - credited
+	if delta == 0 {
+		return nil, nil
+	}
+	event := &models.RatingEvent{UserID: authorID, Kind: models.RatingReaction, Points: delta, VideoID: &videoID, ActorID: &reactorID}
+	m.Events = append(m.Events, *event)
+	return event, nil
+}
+
+func (m *MockRatingRepository) UpdateMaxReactions(ctx context.Context, authorID, videoID int64) (bool, error) {
+	m.MaxChecked = append(m.MaxChecked, videoID)
+	return true, nil
+}
+
+// LedgerBuckets складывает журнал по часу, как date_trunc('hour'); поправки
+// реакции датируются первым начислением текущей реакции пары
+func (m *MockRatingRepository) LedgerBuckets(ctx context.Context, userID int64) ([]models.RatingBucket, error) {
+	sums := map[models.RatingBucket]int{}
+	credited := map[[2]int64]int{}
+	accruedAt := map[[2]int64]time.Time{}
+	for _, e := range m.Events {
+		if userID != 0 && e.UserID != userID {
+			continue
+		}
+		at := e.CreatedAt
+		if e.Kind == models.RatingReaction {
+			pair := [2]int64{*e.VideoID, *e.ActorID}
+			if credited[pair] == 0 {
+				accruedAt[pair] = e.CreatedAt
+			}
+			credited[pair] += e.Points
+			at = accruedAt[pair]
+		}
+		sums[models.RatingBucket{UserID: e.UserID, Hour: at.Truncate(time.Hour)}] += e.Points
+	}
+	var buckets []models.RatingBucket
+	for b, points := range sums {
+		b.Points = points
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+func (m *MockRatingRepository) SetRatings(ctx context.Context, userID int64, ratings map[int64]int) (int64, error) {
+	m.SetCalls++
+	var updated int64
+	for id, rating := range m.Ratings {
+		if userID != 0 && id != userID {
+			continue
+		}
+		if ratings[id] != rating {
+			m.Ratings[id] = ratings[id]
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// total - сумма очков пользователя в журнале
+func (m *MockRatingRepository) total(userID int64) int {
+	sum := 0
+	for _, e := range m.Events {
+		if e.UserID == userID {
+			sum += e.Points
+		}
+	}
+	return sum
+}
+
+func TestRatingService_PostsAndStreaks(t *testing.T) {
+	ctx := context.Background()
+	repo := &MockRatingRepository{}
+	s := NewRatingService(repo, nil, 0)
+
+	posts := []struct {
+		videoID int64
+		day     string
+		late    bool
+	}{
+		{10, "2026-03-10", false},
+		// Удаление и повторная загрузка в тот же день очков не приносят
+		{11, "2026-03-10", false},
+		{12, "2026-03-11", true},
+	}
+	for _, p := range posts {
+		if err := s.RecordPost(ctx, 1, p.videoID, date(p.day), p.late); err != nil {
+			t.Fatalf("RecordPost(%d) error = %v", p.videoID, err)
+		}
+	}
+	if got := repo.total(1); got != PostPoints+LatePostPoints {
+		t.Errorf("points for posts = %d, want %d", got, PostPoints+LatePostPoints)
+	}
+
+	// Очки только за рубежи серии
+	for streak := 1; streak <= 8; streak++ {
+		if err := s.RecordStreak(ctx, 2, streak); err != nil {
+			t.Fatalf("RecordStreak() error = %v", err)
+		}
+	}
+	if got, want := repo.total(2), streakMilestones[3]+streakMilestones[7]; got != want {
+		t.Errorf("points for streak = %d, want %d", got, want)
+	}
+}
+
+func TestRatingService_RecordReaction(t *testing.T) {
+	ctx := context.Background()
+	repo := &MockRatingRepository{
+		Reactions: map[[2]int64]models.ReactionKind{},
+		Authors:   map[int64]int64{100: 1},
+	}
+	s := NewRatingService(repo, nil, 0)
+	react := func(kind models.ReactionKind) {
+		t.Helper()
+		if kind == "" {
+			delete(repo.Reactions, [2]int64{2, 100})
+		} else {
+			repo.Reactions[[2]int64{2, 100}] = kind
+		}
+		if err := s.RecordReaction(ctx, 2, 100); err != nil {
+			t.Fatalf("RecordReaction() error = %v", err)
+		}
+	}
+
+	steps := []struct {
+		kind       models.ReactionKind
+		wantTotal  int
+		wantEvents int
+	}{
+		{models.ReactionHeart, DefaultReactionWeights[models.ReactionHeart], 1},
+		{models.ReactionFlame, DefaultReactionWeights[models.ReactionFlame], 2},
+		// Повтор той же реакции не пишет в журнал
+		{models.ReactionFlame, DefaultReactionWeights[models.ReactionFlame], 2},
+		// Снятие реакции возвращает очки
+		{"", 0, 3},
+	}
+	for _, step := range steps {
+		react(step.kind)
+		if got := repo.total(1); got != step.wantTotal || len(repo.Events) != step.wantEvents {
+			t.Errorf("after %q: total %d with %d events, want %d with %d", step.kind, got, len(repo.Events), step.wantTotal, step.wantEvents)
+		}
+	}
+	if len(repo.MaxChecked) != 3 {
+		t.Errorf("max reactions checked %d times, want 3 (once per ledger change)", len(repo.MaxChecked))
+	}
+
+	// Своя реакция и удаленное видео очков не приносят
+	repo.Reactions[[2]int64{1, 100}] = models.ReactionFlame
+	if err := s.RecordReaction(ctx, 1, 100); err != nil {
+		t.Fatalf("RecordReaction(self) error = %v", err)
+	}
+	if err := s.RecordReaction(ctx, 2, 404); err != nil {
+		t.Fatalf("RecordReaction(deleted video) error = %v", err)
+	}
+	if len(repo.Events) != 3 {
+		t.Errorf("events = %d, want 3", len(repo.Events))
+	}
+}
+
+func TestStreakService_RecordPostAwardsMilestones(t *testing.T) {
+	ctx := context.Background()
+	ratings := &MockRatingRepository{}
+	repo := &MockStreakRepository{States: map[int64]*models.StreakState{
+		1: {UserID: 1, Timezone: "UTC", CurrentStreak: 6, MaxStreak: 6, LastStreakDate: datePtr("2026-03-09"), LastKind: models.StreakPosted},
+	}}
+	s := NewStreakService(repo, WithStreakRatings(NewRatingService(ratings, nil, 0)))
+
+	// Пересъемка в тот же день не дает рубеж повторно
+	for i := 0; i < 2; i++ {
+		if err := s.RecordPost(ctx, 1, date("2026-03-10")); err != nil {
+			t.Fatalf("RecordPost() error = %v", err)
+		}
+	}
+	if got := ratings.total(1); got != streakMilestones[7] {
+		t.Errorf("points = %d, want %d for the 7-day milestone", got, streakMilestones[7])
+	}
+}
+
+func TestDecayedRating(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	halfLife := 10 * 24 * time.Hour
+	buckets := []models.RatingBucket{
+		{UserID: 1, Hour: now, Points: 10},
+		{UserID: 1, Hour: now.Add(-halfLife), Points: 100},
+		{UserID: 1, Hour: now.Add(-2 * halfLife), Points: 7},
+		// Снятая реакция не опускает рейтинг ниже нуля
+		{UserID: 2, Hour: now.Add(-halfLife), Points: 8},
+		{UserID: 2, Hour: now, Points: -5},
+		// Часы базы немного впереди - без усиления
+		{UserID: 3, Hour: now.Add(time.Hour), Points: 4},
+	}
+	got := decayedRating(buckets, now, halfLife)
+	// 10 + 50 + 1.75 = 61.75
+	want := map[int64]int{1: 62, 2: 0, 3: 4}
+	if len(got) != len(want) {
+		t.Fatalf("decayedRating() = %v, want %v", got, want)
+	}
+	for id, rating := range want {
+		if got[id] != rating {
+			t.Errorf("rating of user %d = %d, want %d", id, got[id], rating)
+		}
+	}
+}
+
+func TestRatingService_Recompute(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	halfLife := 24 * time.Hour
+	repo := &MockRatingRepository{
+		Events: []models.RatingEvent{
+			{UserID: 1, Points: 40, CreatedAt: now.Add(-2 * halfLife)},
+			{UserID: 1, Points: 10, CreatedAt: now.Add(-30 * time.Minute)},
+			{UserID: 2, Points: 20, CreatedAt: now.Add(-halfLife)},
+		},
+		// У 3 нет событий в журнале: рейтинг сбрасывается в 0
+		Ratings: map[int64]int{1: 50, 2: 20, 3: 7},
+	}
+	s := NewRatingService(repo, nil, halfLife)
+	s.(*ratingServiceImpl).now = func() time.Time { return now }
+
+	updated, err := s.Recompute(ctx, 2)
+	if err != nil {
+		t.Fatalf("Recompute(2) error = %v", err)
+	}
+	if updated != 1 || repo.Ratings[2] != 10 || repo.Ratings[1] != 50 {
+		t.Errorf("Recompute(2) = %d, ratings %v; want only user 2 at 10", updated, repo.Ratings)
+	}
+
+	updated, err = s.Recompute(ctx, 0)
+	if err != nil {
+		t.Fatalf("Recompute(0) error = %v", err)
+	}
+	want := map[int64]int{1: 20, 2: 10, 3: 0}
+	if updated != 2 {
+		t.Errorf("Recompute(0) updated %d users, want 2", updated)
+	}
+	for id, rating := range want {
+		if repo.Ratings[id] != rating {
+			t.Errorf("rating of user %d = %d, want %d", id, repo.Ratings[id], rating)
+		}
+	}
+}
+
+func TestRatingService_RunDecayStopsOnCancel(t *testing.T) {
+	repo := &MockRatingRepository{Ratings: map[int64]int{}}
+	s := NewRatingService(repo, nil, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.RunDecay(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunDecay did not stop after cancel")
+	}
+	if repo.SetCalls == 0 {
+		t.Error("RunDecay never recomputed ratings")
+	}
+}
+
+func TestRatingService_RecomputeAnchorsReactionCorrections(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	halfLife := 30 * 24 * time.Hour
+	videoID, reactorID := int64(100), int64(2)
+	post := models.RatingEvent{UserID: 1, Kind: models.RatingPost, Points: 40, CreatedAt: now.Add(-2 * halfLife)}
+	reaction := func(points int, at time.Time) models.RatingEvent {
+		return models.RatingEvent{UserID: 1, Kind: models.RatingReaction, Points: points, VideoID: &videoID, ActorID: &reactorID, CreatedAt: at}
+	}
+	recompute := func(events ...models.RatingEvent) int {
+		t.Helper()
+		repo := &MockRatingRepository{Events: events, Ratings: map[int64]int{1: 0}}
+		s := NewRatingService(repo, nil, halfLife)
+		s.(*ratingServiceImpl).now = func() time.Time { return now }
+		if _, err := s.Recompute(ctx, 1); err != nil {
+			t.Fatalf("Recompute() error = %v", err)
+		}
+		return repo.Ratings[1]
+	}
+
+	// 40 очков два периода назад - 10
+	withoutReaction := recompute(post)
+	if withoutReaction != 10 {
+		t.Fatalf("rating without reactions = %d, want 10", withoutReaction)
+	}
+	// Реакция 60 дней назад и ее снятие сейчас - как будто реакции не было
+	if got := recompute(post, reaction(5, now.Add(-2*halfLife)), reaction(-5, now)); got != withoutReaction {
+		t.Errorf("rating after un-react = %d, want %d", got, withoutReaction)
+	}
+	// Смена реакции затухает с исходного начисления: 40/4 + 20/4
+	if got := recompute(post, reaction(12, now.Add(-2*halfLife)), reaction(8, now)); got != 15 {
+		t.Errorf("rating after reaction change = %d, want 15", got)
+	}
+	// Реакция, поставленная заново после снятия, затухает с нового начисления
+	events := []models.RatingEvent{post, reaction(5, now.Add(-2*halfLife)), reaction(-5, now.Add(-halfLife)), reaction(5, now)}
+	if got := recompute(events...); got != withoutReaction+5 {
+		t.Errorf("rating after re-react = %d, want %d", got, withoutReaction+5)
+	}
+}
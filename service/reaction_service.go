@@ -18,15 +18,56 @@ var (
 type ReactionService interface {
 	HandleReaction(ctx context.Context, userID int64, videoID int64, kind models.ReactionKind) error
 	RemoveReaction(ctx context.Context, userID int64, videoID int64) error
-	GetVideoReactions(ctx context.Context, videoID int64) ([]models.ReactingUserResponse, error)
+	GetVideoReactions(ctx context.Context, videoID int64, viewerID int64) ([]models.ReactingUserResponse, error)
 }
 
 type reactionServiceImpl struct {
-	Repo repository.ReactionRepository
+	Repo        repository.ReactionRepository
+	Friendships repository.FriendshipRepository
+	Ratings     RatingService
+	Videos      VideoService
 }
 
-func NewReactionService(repo repository.ReactionRepository) ReactionService {
-	return &reactionServiceImpl{Repo: repo}
+type ReactionServiceOption func(*reactionServiceImpl)
+
+// WithReactionRatings начисляет автору видео рейтинг за полученные реакции
+func WithReactionRatings(ratings RatingService) ReactionServiceOption {
+	return func(s *reactionServiceImpl) {
+		s.Ratings = ratings
+	}
+}
+
+// WithReactionVideos закрывает реакции на видео, закрытые правилом post-to-see
+func WithReactionVideos(videos VideoService) ReactionServiceOption {
+	return func(s *reactionServiceImpl) {
+		s.Videos = videos
+	}
+}
+
+func NewReactionService(repo repository.ReactionRepository, friendships repository.FriendshipRepository, opts ...ReactionServiceOption) ReactionService {
+	s := &reactionServiceImpl{Repo: repo, Friendships: friendships}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// recordRating пересчитывает очки автора за реакцию; ошибка не отменяет саму реакцию
+func (s *reactionServiceImpl) recordRating(ctx context.Context, userID int64, videoID int64) {
+	if s.Ratings == nil {
+		return
+	}
+	if err := s.Ratings.RecordReaction(ctx, userID, videoID); err != nil {
+		log.Printf("WARNING: Failed to record rating for reaction of UserID %d on VideoID %d: %v", userID, videoID, err)
+	}
+}
+
+// checkUnlocked - видео не закрыто для userID правилом post-to-see
+func (s *reactionServiceImpl) checkUnlocked(ctx context.Context, userID int64, videoID int64) error {
+	if s.Videos == nil {
+		return nil
+	}
+	return s.Videos.CheckUnlocked(ctx, userID, videoID)
 }
 
 func isValidReactionKind(kind models.ReactionKind) bool {
@@ -45,13 +86,27 @@ func (s *reactionServiceImpl) HandleReaction(ctx context.Context, userID int64,
 		return ErrInvalidReactionKind
 	}
 
-	err := s.Repo.SetReaction(ctx, userID, videoID, kind)
+	blocked, err := s.Friendships.HasBlockWithVideoAuthor(ctx, userID, videoID)
+	if err != nil {
+		log.Printf("ERROR: Failed to check blocks for VideoID %d and UserID %d: %v", videoID, userID, err)
+		return err
+	}
+	if blocked {
+		log.Printf("WARNING: UserID %d tried to react to VideoID %d of a blocking author", userID, videoID)
+		return ErrUserBlocked
+	}
+	if err := s.checkUnlocked(ctx, userID, videoID); err != nil {
+		return err
+	}
+
+	err = s.Repo.SetReaction(ctx, userID, videoID, kind)
 	if err != nil {
 		log.Printf("ERROR: Failed to set reaction %s for VideoID %d by UserID %d: %v", kind, videoID, userID, err)
 		return err
 	}
 
 	log.Printf("INFO: Reaction '%s' set/updated for VideoID %d by UserID %d", kind, videoID, userID)
+	s.recordRating(ctx, userID, videoID)
 	return nil
 }
 
@@ -67,11 +122,15 @@ func (s *reactionServiceImpl) RemoveReaction(ctx context.Context, userID int64,
 	}
 
 	log.Printf("INFO: Reaction removed for VideoID %d by UserID %d", videoID, userID)
+	s.recordRating(ctx, userID, videoID)
 	return nil
 }
 
-func (s *reactionServiceImpl) GetVideoReactions(ctx context.Context, videoID int64) ([]models.ReactingUserResponse, error) {
-	users, err := s.Repo.GetReactingUsers(ctx, videoID)
+func (s *reactionServiceImpl) GetVideoReactions(ctx context.Context, videoID int64, viewerID int64) ([]models.ReactingUserResponse, error) {
+	if err := s.checkUnlocked(ctx, viewerID, videoID); err != nil {
+		return nil, err
+	}
+	users, err := s.Repo.GetReactingUsers(ctx, videoID, viewerID)
 	if err != nil {
 		log.Printf("ERROR: Failed to fetch reactions for VideoID %d: %v", videoID, err)
 		return nil, err
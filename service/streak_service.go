@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+var ErrNoStreakFreezes = errors.New("no streak freezes left")
+var ErrStreakDayCovered = errors.New("today is already counted in the streak")
+var ErrStreakNotActive = errors.New("there is no active streak to freeze")
+
+const (
+	// StreakFreezeEvery - за каждые столько дней серии начисляется заморозка
+	StreakFreezeEvery = 7
+	// MaxStreakFreezes - больше заморозок не копится
+	MaxStreakFreezes = 2
+
+	DefaultStreakHistoryDays = 30
+	MaxStreakHistoryDays     = 365
+)
+
+// StreakService ведет серии публикаций: CurrentStreak растет за каждый
+// локальный день с публикацией и обнуляется после пропущенного дня.
+// Заморозка засчитывает день без публикации, не увеличивая серию.
+type StreakService interface {
+	// RecordPost засчитывает публикацию в локальный день day; повторная
+	// публикация за тот же день серию не меняет
+	RecordPost(ctx context.Context, userID int64, day time.Time) error
+	// Freeze тратит заморозку на сегодняшний день пользователя
+	Freeze(ctx context.Context, userID int64) (*StreakInfo, error)
+	// Get - серия и история за последние days дней
+	Get(ctx context.Context, userID int64, days int) (*StreakInfo, error)
+	// RunResetJob обнуляет пропущенные серии во всех поясах, пока не отменен ctx
+	RunResetJob(ctx context.Context, interval time.Duration)
+}
+
+// StreakInfo - серия пользователя для GET /users/:user_id/streak
+type StreakInfo struct {
+	UserID        int64  `json:"user_id"`
+	CurrentStreak int    `json:"current_streak"`
+	MaxStreak     int    `json:"max_streak"`
+	StreakFreezes int    `json:"streak_freezes"`
+	LastDate      string `json:"last_date,omitempty"`
+	// TodayCounted - сегодняшний день уже засчитан, серия не сгорит этой ночью
+	TodayCounted bool               `json:"today_counted"`
+	History      []StreakHistoryDay `json:"history"`
+}
+
+type StreakHistoryDay struct {
+	Date string               `json:"date"`
+	Kind models.StreakDayKind `json:"kind"`
+}
+
+type streakServiceImpl struct {
+	Repo    repository.StreakRepository
+	Ratings RatingService
+
+	now func() time.Time
+}
+
+type StreakServiceOption func(*streakServiceImpl)
+
+// WithStreakRatings начисляет рейтинг за рубежи серии
+func WithStreakRatings(ratings RatingService) StreakServiceOption {
+	return func(s *streakServiceImpl) {
+		s.Ratings = ratings
+	}
+}
+
+func NewStreakService(repo repository.StreakRepository, opts ...StreakServiceOption) StreakService {
+	s := &streakServiceImpl{Repo: repo, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *streakServiceImpl) RecordPost(ctx context.Context, userID int64, day time.Time) error {
+	counted := false
+	state, err := s.Repo.Update(ctx, userID, func(state *models.StreakState) (*models.StreakDay, error) {
+		streakDay := applyPost(state, day)
+		counted = streakDay != nil
+		return streakDay, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !counted {
+		return nil
+	}
+	log.Printf("INFO: Streak of user %d is %d (max %d)", userID, state.CurrentStreak, state.MaxStreak)
+	if s.Ratings != nil {
+		return s.Ratings.RecordStreak(ctx, userID, state.CurrentStreak)
+	}
+	return nil
+}
+
+func (s *streakServiceImpl) Freeze(ctx context.Context, userID int64) (*StreakInfo, error) {
+	now := s.now()
+	state, err := s.Repo.Update(ctx, userID, func(state *models.StreakState) (*models.StreakDay, error) {
+		return applyFreeze(state, localDate(now, state.Timezone))
+	})
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: User %d froze streak %d, %d freezes left", userID, state.CurrentStreak, state.StreakFreezes)
+	return s.info(ctx, state, now, DefaultStreakHistoryDays)
+}
+
+func (s *streakServiceImpl) Get(ctx context.Context, userID int64, days int) (*StreakInfo, error) {
+	state, err := s.Repo.GetState(ctx, userID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.info(ctx, state, s.now(), days)
+}
+
+func (s *streakServiceImpl) info(ctx context.Context, state *models.StreakState, now time.Time, days int) (*StreakInfo, error) {
+	if days <= 0 {
+		days = DefaultStreakHistoryDays
+	}
+	if days > MaxStreakHistoryDays {
+		days = MaxStreakHistoryDays
+	}
+	today := localDate(now, state.Timezone)
+	history, err := s.Repo.ListDays(ctx, state.UserID, today.AddDate(0, 0, 1-days), today)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &StreakInfo{
+		UserID: state.UserID,
+		// Задача сброса могла еще не дойти до пояса пользователя
+		CurrentStreak: effectiveStreak(state, today),
+		MaxStreak:     state.MaxStreak,
+		StreakFreezes: state.StreakFreezes,
+		TodayCounted:  sameDate(state.LastStreakDate, today),
+		History:       make([]StreakHistoryDay, 0, len(history)),
+	}
+	if state.LastStreakDate != nil {
+		info.LastDate = state.LastStreakDate.Format("2006-01-02")
+	}
+	for _, day := range history {
+		info.History = append(info.History, StreakHistoryDay{Date: day.Date(), Kind: day.Kind})
+	}
+	return info, nil
+}
+
+func (s *streakServiceImpl) RunResetJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.resetMissed(ctx); err != nil {
+			log.Printf("ERROR: Streak reset: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resetMissed обнуляет серии, у которых не засчитан вчерашний день. Полночь
+// в каждом поясе своя, поэтому "вчера" считается отдельно для каждого.
+func (s *streakServiceImpl) resetMissed(ctx context.Context) error {
+	timezones, err := s.Repo.ListTimezones(ctx)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, timezone := range timezones {
+		yesterday := localDate(now, timezone).AddDate(0, 0, -1)
+		reset, err := s.Repo.ResetMissed(ctx, timezone, yesterday)
+		if err != nil {
+			log.Printf("ERROR: Failed to reset streaks in %s: %v", timezone, err)
+			continue
+		}
+		if reset > 0 {
+			log.Printf("INFO: Reset %d streaks in %s", reset, timezone)
+		}
+	}
+	return nil
+}
+
+// applyPost засчитывает публикацию в день day и возвращает день для
+// истории; nil - день уже засчитан публикацией
+func applyPost(state *models.StreakState, day time.Time) *models.StreakDay {
+	switch {
+	case state.LastStreakDate != nil && state.LastStreakDate.After(day):
+		// Пользователь сменил пояс назад, этот день уже позади
+		return nil
+	case sameDate(state.LastStreakDate, day):
+		if state.LastKind == models.StreakPosted {
+			return nil
+		}
+		// День заморожен заранее, но публикация все равно продлевает серию
+		state.CurrentStreak++
+	case sameDate(state.LastStreakDate, day.AddDate(0, 0, -1)):
+		state.CurrentStreak++
+	default:
+		state.CurrentStreak = 1
+	}
+
+	if state.CurrentStreak > state.MaxStreak {
+		state.MaxStreak = state.CurrentStreak
+	}
+	if state.CurrentStreak%StreakFreezeEvery == 0 && state.StreakFreezes < MaxStreakFreezes {
+		state.StreakFreezes++
+	}
+	state.LastStreakDate = &day
+	state.LastKind = models.StreakPosted
+	return &models.StreakDay{UserID: state.UserID, LocalDate: day, Kind: models.StreakPosted}
+}
+
+// applyFreeze тратит заморозку на сегодняшний день today. Заморозить можно
+// только живую серию (вчерашний день засчитан) и только незасчитанный день.
+func applyFreeze(state *models.StreakState, today time.Time) (*models.StreakDay, error) {
+	if sameDate(state.LastStreakDate, today) {
+		return nil, ErrStreakDayCovered
+	}
+	if state.CurrentStreak == 0 || !sameDate(state.LastStreakDate, today.AddDate(0, 0, -1)) {
+		return nil, ErrStreakNotActive
+	}
+	if state.StreakFreezes == 0 {
+		return nil, ErrNoStreakFreezes
+	}
+	state.StreakFreezes--
+	state.LastStreakDate = &today
+	state.LastKind = models.StreakFrozen
+	return &models.StreakDay{UserID: state.UserID, LocalDate: today, Kind: models.StreakFrozen}, nil
+}
+
+// effectiveStreak - серия с учетом пропуска, который еще не обработал RunResetJob
+func effectiveStreak(state *models.StreakState, today time.Time) int {
+	last := state.LastStreakDate
+	if last == nil || last.Before(today.AddDate(0, 0, -1)) {
+		return 0
+	}
+	return state.CurrentStreak
+}
+
+// localDate - сегодняшняя дата в поясе timezone (некорректный - UTC)
+func localDate(now time.Time, timezone string) time.Time {
+	loc, err := models.LoadTimezone(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, _ := models.LocalDay(now, loc)
+	return models.CalendarDate(start)
+}
+
+func sameDate(date *time.Time, day time.Time) bool {
+	return date != nil && date.Format("2006-01-02") == day.Format("2006-01-02")
+}
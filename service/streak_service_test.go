@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func datePtr(s string) *time.Time {
+	t := date(s)
+	return &t
+}
+
+func TestApplyPost(t *testing.T) {
+	tests := []struct {
+		name        string
+		state       models.StreakState
+		day         string
+		wantDay     bool
+		wantCurrent int
+		wantMax     int
+		wantFreezes int
+	}{
+		{name: "FirstPost", day: "2026-03-10", wantDay: true, wantCurrent: 1, wantMax: 1},
+		{name: "NextDay", state: models.StreakState{CurrentStreak: 3, MaxStreak: 5, LastStreakDate: datePtr("2026-03-09"), LastKind: models.StreakPosted},
+			day: "2026-03-10", wantDay: true, wantCurrent: 4, wantMax: 5},
+		{name: "NewMax", state: models.StreakState{CurrentStreak: 5, MaxStreak: 5, LastStreakDate: datePtr("2026-03-09"), LastKind: models.StreakPosted},
+			day: "2026-03-10", wantDay: true, wantCurrent: 6, wantMax: 6},
+		{name: "MissedDay", state: models.StreakState{CurrentStreak: 3, MaxStreak: 5, LastStreakDate: datePtr("2026-03-08"), LastKind: models.StreakPosted},
+			day: "2026-03-10", wantDay: true, wantCurrent: 1, wantMax: 5},
+		{name: "SameDayRetake", state: models.StreakState{CurrentStreak: 3, MaxStreak: 5, LastStreakDate: datePtr("2026-03-10"), LastKind: models.StreakPosted},
+			day: "2026-03-10", wantDay: false, wantCurrent: 3, wantMax: 5},
+		{name: "PostOnFrozenDay", state: models.StreakState{CurrentStreak: 3, MaxStreak: 5, LastStreakDate: datePtr("2026-03-10"), LastKind: models.StreakFrozen},
+			day: "2026-03-10", wantDay: true, wantCurrent: 4, wantMax: 5},
+		{name: "AfterFrozenDay", state: models.StreakState{CurrentStreak: 3, MaxStreak: 5, LastStreakDate: datePtr("2026-03-09"), LastKind: models.StreakFrozen},
+			day: "2026-03-10", wantDay: true, wantCurrent: 4, wantMax: 5},
+		{name: "EarnsFreeze", state: models.StreakState{CurrentStreak: 6, MaxStreak: 6, StreakFreezes: 1, LastStreakDate: datePtr("2026-03-09"), LastKind: models.StreakPosted},
+			day: "2026-03-10", wantDay: true, wantCurrent: 7, wantMax: 7, wantFreezes: 2},
+		{name: "FreezesCapped", state: models.StreakState{CurrentStreak: 13, MaxStreak: 13, StreakFreezes: MaxStreakFreezes, LastStreakDate: datePtr("2026-03-09"), LastKind: models.StreakPosted},
+			day: "2026-03-10", wantDay: true, wantCurrent: 14, wantMax: 14, wantFreezes: MaxStreakFreezes},
+		{name: "TimezoneMovedBack", state: models.StreakState{CurrentStreak: 3, MaxStreak: 5, LastStreakDate: datePtr("2026-03-11"), LastKind: models.StreakPosted},
+			day: "2026-03-10", wantDay: false, wantCurrent: 3, wantMax: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			day := applyPost(&state, date(tt.day))
+			if (day != nil) != tt.wantDay {
+				t.Fatalf("applyPost() day = %+v, wantDay %v", day, tt.wantDay)
+			}
+			if day != nil && (day.Kind != models.StreakPosted || day.Date() != tt.day) {
+				t.Errorf("applyPost() day = %s %s, want posted %s", day.Date(), day.Kind, tt.day)
+			}
+			if state.CurrentStreak != tt.wantCurrent || state.MaxStreak != tt.wantMax || state.StreakFreezes != tt.wantFreezes {
+				t.Errorf("applyPost() streak = %d/%d freezes %d, want %d/%d freezes %d",
+					state.CurrentStreak, state.MaxStreak, state.StreakFreezes, tt.wantCurrent, tt.wantMax, tt.wantFreezes)
+			}
+		})
+	}
+}
+
+func TestApplyFreeze(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   models.StreakState
+		wantErr error
+	}{
+		{name: "Success", state: models.StreakState{CurrentStreak: 4, StreakFreezes: 1, LastStreakDate: datePtr("2026-03-09")}},
+		{name: "NoFreezes", state: models.StreakState{CurrentStreak: 4, LastStreakDate: datePtr("2026-03-09")}, wantErr: ErrNoStreakFreezes},
+		{name: "AlreadyPosted", state: models.StreakState{CurrentStreak: 4, StreakFreezes: 1, LastStreakDate: datePtr("2026-03-10")}, wantErr: ErrStreakDayCovered},
+		{name: "StreakBroken", state: models.StreakState{CurrentStreak: 4, StreakFreezes: 1, LastStreakDate: datePtr("2026-03-08")}, wantErr: ErrStreakNotActive},
+		{name: "NoStreak", state: models.StreakState{StreakFreezes: 1}, wantErr: ErrStreakNotActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			day, err := applyFreeze(&state, date("2026-03-10"))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("applyFreeze() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if day.Kind != models.StreakFrozen || day.Date() != "2026-03-10" {
+				t.Errorf("applyFreeze() day = %s %s, want frozen 2026-03-10", day.Date(), day.Kind)
+			}
+			if state.CurrentStreak != 4 || state.StreakFreezes != 0 || !sameDate(state.LastStreakDate, date("2026-03-10")) {
+				t.Errorf("applyFreeze() state = %+v", state)
+			}
+		})
+	}
+}
+
+// MockStreakRepository хранит серии в памяти
+type MockStreakRepository struct {
+	States map[int64]*models.StreakState
+	Days   []models.StreakDay
+	// Reset - до какой даты обнулялись серии каждого пояса
+	Reset map[string]string
+}
+
+func (m *MockStreakRepository) GetState(ctx context.Context, userID int64) (*models.StreakState, error) {
+	state, ok := m.States[userID]
+	if !ok {
+		return nil, repository.ErrRecordNotFound
+	}
+	copied := *state
+	return &copied, nil
+}
+
+func (m *MockStreakRepository) Update(ctx context.Context, userID int64, apply func(state *models.StreakState) (*models.StreakDay, error)) (*models.StreakState, error) {
+	state, err := m.GetState(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	day, err := apply(state)
+	if err != nil {
+		return nil, err
+	}
+	if day != nil {
+		m.Days = append(m.Days, *day)
+		m.States[userID] = state
+	}
+	return state, nil
+}
+
+func (m *MockStreakRepository) ListDays(ctx context.Context, userID int64, from, to time.Time) ([]models.StreakDay, error) {
+	var days []models.StreakDay
+	for _, day := range m.Days {
+		if day.UserID == userID && !day.LocalDate.Before(from) && !day.LocalDate.After(to) {
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].LocalDate.After(days[j].LocalDate) })
+	return days, nil
+}
+
+func (m *MockStreakRepository) ResetMissed(ctx context.Context, timezone string, before time.Time) (int64, error) {
+	m.Reset[timezone] = before.Format("2006-01-02")
+	return 0, nil
+}
+
+func (m *MockStreakRepository) ListTimezones(ctx context.Context) ([]string, error) {
+	return []string{"Europe/Minsk", "America/Los_Angeles"}, nil
+}
+
+func TestStreakService_ResetUsesLocalYesterday(t *testing.T) {
+	repo := &MockStreakRepository{Reset: map[string]string{}}
+	s := NewStreakService(repo).(*streakServiceImpl)
+	// 01:00 UTC 10 марта: в Минске уже 10-е, в Лос-Анджелесе еще 9-е
+	s.now = func() time.Time { return time.Date(2026, 3, 10, 1, 0, 0, 0, time.UTC) }
+
+	if err := s.resetMissed(context.Background()); err != nil {
+		t.Fatalf("resetMissed() error = %v", err)
+	}
+	want := map[string]string{"Europe/Minsk": "2026-03-09", "America/Los_Angeles": "2026-03-08"}
+	if !reflect.DeepEqual(repo.Reset, want) {
+		t.Errorf("resetMissed() = %v, want %v", repo.Reset, want)
+	}
+}
+
+func TestStreakService_PostFreezeAndGet(t *testing.T) {
+	ctx := context.Background()
+	repo := &MockStreakRepository{States: map[int64]*models.StreakState{
+		1: {UserID: 1, Timezone: "UTC", CurrentStreak: 6, MaxStreak: 6, LastStreakDate: datePtr("2026-03-09"), LastKind: models.StreakPosted},
+	}}
+	s := NewStreakService(repo).(*streakServiceImpl)
+	s.now = func() time.Time { return time.Date(2026, 3, 10, 20, 0, 0, 0, time.UTC) }
+
+	// 7-й день подряд приносит заморозку, ее тратим на 11-е
+	if err := s.RecordPost(ctx, 1, date("2026-03-10")); err != nil {
+		t.Fatalf("RecordPost() error = %v", err)
+	}
+	s.now = func() time.Time { return time.Date(2026, 3, 11, 8, 0, 0, 0, time.UTC) }
+	info, err := s.Freeze(ctx, 1)
+	if err != nil {
+		t.Fatalf("Freeze() error = %v", err)
+	}
+	if info.CurrentStreak != 7 || info.StreakFreezes != 0 || !info.TodayCounted {
+		t.Errorf("Freeze() = %+v", info)
+	}
+
+	// Вечером 12-го серия жива (еще можно успеть), 13-го пропуск 12-го ее обнуляет
+	s.now = func() time.Time { return time.Date(2026, 3, 12, 23, 0, 0, 0, time.UTC) }
+	info, err = s.Get(ctx, 1, 7)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	wantHistory := []StreakHistoryDay{{"2026-03-11", models.StreakFrozen}, {"2026-03-10", models.StreakPosted}}
+	if info.CurrentStreak != 7 || info.MaxStreak != 7 || !reflect.DeepEqual(info.History, wantHistory) {
+		t.Errorf("Get() = %+v", info)
+	}
+	s.now = func() time.Time { return time.Date(2026, 3, 13, 1, 0, 0, 0, time.UTC) }
+	if info, _ = s.Get(ctx, 1, 7); info.CurrentStreak != 0 || info.MaxStreak != 7 {
+		t.Errorf("Get() after missed day = %+v, want streak 0 and max 7", info)
+	}
+
+	if _, err := s.Get(ctx, 2, 7); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Get() unknown user error = %v, want ErrUserNotFound", err)
+	}
+}
@@ -0,0 +1,407 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/media"
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+	"github.com/merinovvvv/momentic-backend/storage"
+)
+
+const (
+	// TranscodeLease - сколько задача может выполняться, прежде чем ее заберет другой воркер
+	TranscodeLease = 30 * time.Minute
+
+	transcodeRetryBase = 30 * time.Second
+	transcodeRetryMax  = time.Hour
+)
+
+// HLSPrefix - каталог в хранилище с HLS-вариантами видео
+func HLSPrefix(videoID int64) string {
+	return fmt.Sprintf("hls/%d", videoID)
+}
+
+// Rendition - одна ступень лестницы качества
+type Rendition struct {
+	Height       int // короткая сторона кадра
+	VideoBitrate string
+	MaxRate      string
+	BufSize      string
+	AudioBitrate string
+}
+
+// HLSLadder - ступени от лучшей к худшей
+var HLSLadder = []Rendition{
+	{Height: 1080, VideoBitrate: "5000k", MaxRate: "5350k", BufSize: "7500k", AudioBitrate: "192k"},
+	{Height: 720, VideoBitrate: "2800k", MaxRate: "2996k", BufSize: "4200k", AudioBitrate: "128k"},
+	{Height: 480, VideoBitrate: "1400k", MaxRate: "1498k", BufSize: "2100k", AudioBitrate: "128k"},
+	{Height: 360, VideoBitrate: "800k", MaxRate: "856k", BufSize: "1200k", AudioBitrate: "96k"},
+}
+
+// ladderFor оставляет ступени не выше исходника (по короткой стороне),
+// чтобы не растягивать картинку. Для совсем маленького видео остается
+// одна нижняя ступень в исходном размере.
+func ladderFor(width, height int) []Rendition {
+	short := width
+	if height < short {
+		short = height
+	}
+	var ladder []Rendition
+	for _, r := range HLSLadder {
+		if r.Height <= short {
+			ladder = append(ladder, r)
+		}
+	}
+	if len(ladder) == 0 {
+		lowest := HLSLadder[len(HLSLadder)-1]
+		lowest.Height = short - short%2 // libx264 требует четные размеры
+		ladder = append(ladder, lowest)
+	}
+	return ladder
+}
+
+// Transcoder превращает исходный файл в HLS (outDir/master.m3u8 и варианты
+// рядом) и извлекает кадры для постера и превью (см. ExtractFrames)
+type Transcoder interface {
+	Transcode(ctx context.Context, input, outDir string, info media.Info) error
+	ExtractFrames(ctx context.Context, input, outDir string, info media.Info) error
+}
+
+// FFmpegTranscoder запускает локальный ffmpeg
+type FFmpegTranscoder struct {
+	Path string
+}
+
+func (t FFmpegTranscoder) Transcode(ctx context.Context, input, outDir string, info media.Info) error {
+	return t.run(ctx, hlsArgs(input, outDir, info))
+}
+
+func (t FFmpegTranscoder) run(ctx context.Context, args []string) error {
+	cmd := exec.CommandContext(ctx, t.Path, args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// последние строки stderr ffmpeg содержат причину
+		msg := stderr.String()
+		if len(msg) > 1000 {
+			msg = msg[len(msg)-1000:]
+		}
+		return fmt.Errorf("ffmpeg: %w: %s", err, strings.TrimSpace(msg))
+	}
+	return nil
+}
+
+// hlsArgs собирает аргументы ffmpeg для всей лестницы за один проход.
+// ffmpeg сам применяет поворот из метаданных, поэтому масштабируется
+// короткая сторона уже повернутого кадра.
+func hlsArgs(input, outDir string, info media.Info) []string {
+	width, height := displaySize(info)
+	ladder := ladderFor(width, height)
+
+	var filter strings.Builder
+	fmt.Fprintf(&filter, "[0:v]split=%d", len(ladder))
+	for i := range ladder {
+		fmt.Fprintf(&filter, "[v%d]", i)
+	}
+	for i, r := range ladder {
+		fmt.Fprintf(&filter, ";[v%d]scale=%s[v%dout]", i, scaleExpr(width, height, r.Height), i)
+	}
+
+	args := []string{"-hide_banner", "-nostdin", "-y", "-i", input, "-filter_complex", filter.String()}
+	streamMap := make([]string, len(ladder))
+	for i, r := range ladder {
+		args = append(args,
+			"-map", fmt.Sprintf("[v%dout]", i),
+			fmt.Sprintf("-c:v:%d", i), "libx264",
+			fmt.Sprintf("-b:v:%d", i), r.VideoBitrate,
+			fmt.Sprintf("-maxrate:v:%d", i), r.MaxRate,
+			fmt.Sprintf("-bufsize:v:%d", i), r.BufSize,
+		)
+		streamMap[i] = fmt.Sprintf("v:%d", i)
+		if info.HasAudio {
+			args = append(args,
+				"-map", "0:a:0",
+				fmt.Sprintf("-c:a:%d", i), "aac",
+				fmt.Sprintf("-b:a:%d", i), r.AudioBitrate,
+			)
+			streamMap[i] += fmt.Sprintf(",a:%d", i)
+		}
+	}
+	if info.HasAudio {
+		args = append(args, "-ac", "2")
+	}
+	// Ключевые кадры каждые 2 секунды - сегменты всех вариантов совпадают
+	args = append(args,
+		"-preset", "veryfast",
+		"-profile:v", "main",
+		"-pix_fmt", "yuv420p",
+		"-force_key_frames", "expr:gte(t,n_forced*2)",
+		"-f", "hls",
+		"-hls_time", "4",
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_filename", filepath.Join(outDir, "v%v", "seg_%03d.ts"),
+		"-master_pl_name", "master.m3u8",
+		"-var_stream_map", strings.Join(streamMap, " "),
+		filepath.Join(outDir, "v%v", "index.m3u8"),
+	)
+	return args
+}
+
+// displaySize - размеры кадра с учетом поворота из метаданных
+func displaySize(info media.Info) (width, height int) {
+	if info.Rotation == 90 || info.Rotation == 270 {
+		return info.Height, info.Width
+	}
+	return info.Width, info.Height
+}
+
+// scaleExpr - аргумент фильтра scale, задающий короткую сторону short
+func scaleExpr(width, height, short int) string {
+	if width < height {
+		return fmt.Sprintf("%d:-2", short)
+	}
+	return fmt.Sprintf("-2:%d", short)
+}
+
+// transcodeBackoff - пауза перед попыткой attempt+1: 30s, 1m, 2m, ... не больше часа
+func transcodeBackoff(attempt int) time.Duration {
+	delay := transcodeRetryBase
+	for i := 1; i < attempt && delay < transcodeRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > transcodeRetryMax {
+		delay = transcodeRetryMax
+	}
+	return delay
+}
+
+// TranscodeWorker забирает задачи из очереди и публикует HLS-варианты видео.
+// Воркеров может быть несколько, в том числе в разных процессах.
+type TranscodeWorker struct {
+	Jobs       repository.TranscodeRepository
+	Videos     repository.VideoRepository
+	Store      storage.BlobStore
+	Transcoder Transcoder
+	WorkDir    string
+	ID         string
+
+	PollInterval time.Duration
+}
+
+func NewTranscodeWorker(jobs repository.TranscodeRepository, videos repository.VideoRepository, store storage.BlobStore, transcoder Transcoder, workDir string) (*TranscodeWorker, error) {
+	if err := os.MkdirAll(workDir, 0o700); err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	return &TranscodeWorker{
+		Jobs:         jobs,
+		Videos:       videos,
+		Store:        store,
+		Transcoder:   transcoder,
+		WorkDir:      workDir,
+		ID:           fmt.Sprintf("%s:%d", host, os.Getpid()),
+		PollInterval: 5 * time.Second,
+	}, nil
+}
+
+// Run обрабатывает очередь, пока не отменен ctx. Раз в минуту ставит в
+// очередь видео, для которых задача не создалась при загрузке.
+func (w *TranscodeWorker) Run(ctx context.Context) {
+	lastReconcile := time.Time{}
+	for {
+		if time.Since(lastReconcile) > time.Minute {
+			lastReconcile = time.Now()
+			if n, err := w.Jobs.EnqueueMissing(ctx); err != nil {
+				log.Printf("ERROR: Failed to enqueue missing transcode jobs: %v", err)
+			} else if n > 0 {
+				log.Printf("INFO: Enqueued %d videos without transcode job", n)
+			}
+		}
+
+		processed, err := w.RunOnce(ctx)
+		if err != nil {
+			log.Printf("ERROR: Transcode worker %s: %v", w.ID, err)
+		}
+		if processed {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.PollInterval):
+		}
+	}
+}
+
+// RunOnce берет одну задачу и выполняет ее. processed = false, если очередь пуста.
+func (w *TranscodeWorker) RunOnce(ctx context.Context) (processed bool, err error) {
+	job, err := w.Jobs.Claim(ctx, w.ID, time.Now().Add(-TranscodeLease))
+	if err != nil || job == nil {
+		return false, err
+	}
+	log.Printf("INFO: Transcoding video %d (job %d, attempt %d/%d)", job.VideoID, job.JobID, job.Attempts, job.MaxAttempts)
+
+	jobCtx, cancel := context.WithTimeout(ctx, TranscodeLease)
+	defer cancel()
+	output, err := w.process(jobCtx, job)
+	if err == nil {
+		err = w.Jobs.Complete(ctx, job, output)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			// видео удалили, пока шло перекодирование
+			log.Printf("INFO: Video %d was deleted during transcoding, dropping renditions", job.VideoID)
+			w.cleanup(ctx, job.VideoID)
+			return true, nil
+		}
+		if err == nil {
+			log.Printf("INFO: Video %d transcoded to %s", job.VideoID, output.PlaylistKey)
+		}
+		return true, err
+	}
+	return true, w.fail(ctx, job, err)
+}
+
+// cleanup удаляет все, что воркер мог успеть выложить для видео
+func (w *TranscodeWorker) cleanup(ctx context.Context, videoID int64) {
+	for _, prefix := range derivedPrefixes(videoID) {
+		if err := w.Store.DeletePrefix(ctx, prefix); err != nil {
+			log.Printf("WARNING: Could not clean up %s of video %d: %v", prefix, videoID, err)
+		}
+	}
+}
+
+func (w *TranscodeWorker) fail(ctx context.Context, job *models.TranscodeJob, cause error) error {
+	w.cleanup(ctx, job.VideoID)
+	// Битый исходник не станет лучше от повторов
+	permanent := errors.Is(cause, media.ErrInvalidMedia) || errors.Is(cause, media.ErrUnsupportedFormat) ||
+		errors.Is(cause, repository.ErrRecordNotFound)
+	if permanent || job.Attempts >= job.MaxAttempts {
+		log.Printf("ERROR: Transcoding of video %d failed permanently after %d attempts: %v", job.VideoID, job.Attempts, cause)
+		return w.Jobs.Fail(ctx, job, cause.Error())
+	}
+	delay := transcodeBackoff(job.Attempts)
+	log.Printf("WARNING: Transcoding of video %d failed (attempt %d/%d), retry in %s: %v", job.VideoID, job.Attempts, job.MaxAttempts, delay, cause)
+	return w.Jobs.Retry(ctx, job, time.Now().Add(delay), cause.Error())
+}
+
+// process скачивает исходник, перекодирует его в HLS под hls/<video_id>/,
+// делает постер, GIF-превью и BlurHash под previews/<video_id>/.
+func (w *TranscodeWorker) process(ctx context.Context, job *models.TranscodeJob) (repository.TranscodeOutput, error) {
+	var output repository.TranscodeOutput
+	video, err := w.Videos.GetVideoByID(ctx, job.VideoID)
+	if err != nil {
+		return output, err
+	}
+
+	dir, err := os.MkdirTemp(w.WorkDir, fmt.Sprintf("video-%d-", job.VideoID))
+	if err != nil {
+		return output, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "source"+filepath.Ext(video.Filepath))
+	size, err := w.download(ctx, video.Filepath, input)
+	if err != nil {
+		return output, err
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return output, err
+	}
+	_, info, err := media.Probe(f, size)
+	f.Close()
+	if err != nil {
+		return output, err
+	}
+
+	hlsDir := filepath.Join(dir, "hls")
+	framesDir := filepath.Join(dir, "frames")
+	for _, d := range []string{hlsDir, framesDir} {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return output, err
+		}
+	}
+	if err := w.Transcoder.Transcode(ctx, input, hlsDir, info); err != nil {
+		return output, err
+	}
+	if _, err := os.Stat(filepath.Join(hlsDir, "master.m3u8")); err != nil {
+		return output, fmt.Errorf("transcoder produced no master playlist: %w", err)
+	}
+	if err := w.Transcoder.ExtractFrames(ctx, input, framesDir, info); err != nil {
+		return output, err
+	}
+	poster, preview, blurHash, err := buildPreviews(framesDir)
+	if err != nil {
+		return output, fmt.Errorf("previews: %w", err)
+	}
+
+	prefix := HLSPrefix(job.VideoID)
+	err = filepath.WalkDir(hlsDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(hlsDir, path)
+		if err != nil {
+			return err
+		}
+		return w.upload(ctx, path, prefix+"/"+filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return output, err
+	}
+
+	output = repository.TranscodeOutput{
+		PlaylistKey: prefix + "/master.m3u8",
+		PosterKey:   PreviewPrefix(job.VideoID) + "/poster.jpg",
+		PreviewKey:  PreviewPrefix(job.VideoID) + "/preview.gif",
+		BlurHash:    blurHash,
+	}
+	if err := w.Store.Put(ctx, output.PosterKey, bytes.NewReader(poster), int64(len(poster)), "image/jpeg"); err != nil {
+		return output, err
+	}
+	if err := w.Store.Put(ctx, output.PreviewKey, bytes.NewReader(preview), int64(len(preview)), "image/gif"); err != nil {
+		return output, err
+	}
+	return output, nil
+}
+
+func (w *TranscodeWorker) download(ctx context.Context, key, path string) (int64, error) {
+	src, err := w.Store.Open(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	dst, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+func (w *TranscodeWorker) upload(ctx context.Context, path, key string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return w.Store.Put(ctx, key, f, info.Size(), storage.ContentTypeByKey(key))
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/media"
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+// MockTranscodeRepository - очередь из одной задачи
+type MockTranscodeRepository struct {
+	Job       *models.TranscodeJob
+	Enqueued  []int64
+	Completed repository.TranscodeOutput
+	RetryAt   time.Time
+	Failed    bool
+
+	CompleteErr error
+}
+
+func (m *MockTranscodeRepository) Enqueue(ctx context.Context, videoID int64) error {
+	m.Enqueued = append(m.Enqueued, videoID)
+	return nil
+}
+func (m *MockTranscodeRepository) EnqueueMissing(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+func (m *MockTranscodeRepository) Claim(ctx context.Context, workerID string, staleBefore time.Time) (*models.TranscodeJob, error) {
+	job := m.Job
+	m.Job = nil
+	if job != nil {
+		job.Attempts++
+	}
+	return job, nil
+}
+func (m *MockTranscodeRepository) Complete(ctx context.Context, job *models.TranscodeJob, output repository.TranscodeOutput) error {
+	if m.CompleteErr != nil {
+		return m.CompleteErr
+	}
+	m.Completed = output
+	return nil
+}
+func (m *MockTranscodeRepository) Retry(ctx context.Context, job *models.TranscodeJob, runAt time.Time, lastError string) error {
+	m.RetryAt = runAt
+	return nil
+}
+func (m *MockTranscodeRepository) Fail(ctx context.Context, job *models.TranscodeJob, lastError string) error {
+	m.Failed = true
+	return nil
+}
+
+// fakeTranscoder пишет минимальный HLS вместо запуска ffmpeg
+type fakeTranscoder struct {
+	err error
+}
+
+func (f fakeTranscoder) Transcode(ctx context.Context, input, outDir string, info media.Info) error {
+	if f.err != nil {
+		return f.err
+	}
+	if err := os.MkdirAll(filepath.Join(outDir, "v0"), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "master.m3u8"), []byte("#EXTM3U\nv0/index.m3u8\n"), 0o600); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outDir, "v0", "seg_000.ts"), []byte("ts"), 0o600)
+}
+
+func (f fakeTranscoder) ExtractFrames(ctx context.Context, input, outDir string, info media.Info) error {
+	names := []string{"poster.png", "preview_001.png", "preview_002.png"}
+	for i, name := range names {
+		img := image.NewRGBA(image.Rect(0, 0, 32, 18))
+		for x := 0; x < 32; x++ {
+			img.Set(x, i, color.RGBA{R: uint8(x * 8), G: 64, B: 200, A: 0xff})
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(outDir, name), buf.Bytes(), 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestLadderFor(t *testing.T) {
+	heights := func(ladder []Rendition) []int {
+		var out []int
+		for _, r := range ladder {
+			out = append(out, r.Height)
+		}
+		return out
+	}
+	tests := []struct {
+		name          string
+		width, height int
+		want          []int
+	}{
+		{"FullHD", 1920, 1080, []int{1080, 720, 480, 360}},
+		{"PortraitHD", 720, 1280, []int{720, 480, 360}},
+		{"SD", 854, 480, []int{480, 360}},
+		{"Tiny", 320, 241, []int{240}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := heights(ladderFor(tt.width, tt.height)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ladderFor(%d, %d) = %v, want %v", tt.width, tt.height, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHLSArgs(t *testing.T) {
+	// 1280x720 с поворотом на 90 - вертикальное видео, масштабируется ширина
+	args := strings.Join(hlsArgs("in.mp4", "out", media.Info{Width: 1280, Height: 720, Rotation: 90, HasAudio: true}), " ")
+	for _, want := range []string{
+		"[0:v]split=3[v0][v1][v2];[v0]scale=720:-2[v0out]",
+		"-var_stream_map v:0,a:0 v:1,a:1 v:2,a:2",
+		"-master_pl_name master.m3u8",
+		filepath.Join("out", "v%v", "index.m3u8"),
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("hlsArgs() = %q, missing %q", args, want)
+		}
+	}
+
+	args = strings.Join(hlsArgs("in.mp4", "out", media.Info{Width: 640, Height: 360}), " ")
+	if strings.Contains(args, "0:a:0") || !strings.Contains(args, "-var_stream_map v:0 ") {
+		t.Errorf("hlsArgs() without audio = %q", args)
+	}
+}
+
+func TestTranscodeBackoff(t *testing.T) {
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	for i, d := range want {
+		if got := transcodeBackoff(i + 1); got != d {
+			t.Errorf("transcodeBackoff(%d) = %s, want %s", i+1, got, d)
+		}
+	}
+	if got := transcodeBackoff(20); got != time.Hour {
+		t.Errorf("transcodeBackoff(20) = %s, want 1h", got)
+	}
+}
+
+func TestTranscodeWorker_RunOnce(t *testing.T) {
+	ctx := context.Background()
+	video := &models.Video{VideoID: 7, AuthorID: 1, Filepath: "videos/1_1.mp4", Status: models.VideoProcessing}
+
+	tests := []struct {
+		name          string
+		attempts      int
+		transcodeErr  error
+		completeErr   error
+		wantPlaylist  string
+		wantRetry     bool
+		wantFailed    bool
+		wantRemaining int // объектов hls/7/ и previews/7/ после выполнения
+	}{
+		{name: "Success", wantPlaylist: "hls/7/master.m3u8", wantRemaining: 4},
+		{name: "RetryWithBackoff", transcodeErr: errors.New("ffmpeg crashed"), wantRetry: true},
+		{name: "FailAfterMaxAttempts", attempts: 4, transcodeErr: errors.New("ffmpeg crashed"), wantFailed: true},
+		{name: "VideoDeletedMeanwhile", completeErr: repository.ErrRecordNotFound, wantRemaining: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &MockBlobStore{Objects: map[string][]byte{video.Filepath: testVideoFile(5)}}
+			jobs := &MockTranscodeRepository{
+				Job:         &models.TranscodeJob{JobID: 1, VideoID: 7, Attempts: tt.attempts, MaxAttempts: 5},
+				CompleteErr: tt.completeErr,
+			}
+			videos := &MockVideoRepository{GetVideoByIDFn: func(ctx context.Context, videoID int64) (*models.Video, error) {
+				return video, nil
+			}}
+			worker, err := NewTranscodeWorker(jobs, videos, store, fakeTranscoder{err: tt.transcodeErr}, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			processed, err := worker.RunOnce(ctx)
+			if !processed || err != nil {
+				t.Fatalf("RunOnce() = %v, %v", processed, err)
+			}
+			if jobs.Completed.PlaylistKey != tt.wantPlaylist {
+				t.Errorf("completed playlist = %q, want %q", jobs.Completed.PlaylistKey, tt.wantPlaylist)
+			}
+			if tt.wantPlaylist != "" {
+				if jobs.Completed.BlurHash == "" || !strings.HasPrefix(string(store.Objects[jobs.Completed.PosterKey]), "\xff\xd8") ||
+					!strings.HasPrefix(string(store.Objects[jobs.Completed.PreviewKey]), "GIF89a") {
+					t.Errorf("poster, preview or blurhash missing: %+v", jobs.Completed)
+				}
+			}
+			if retried := !jobs.RetryAt.IsZero(); retried != tt.wantRetry {
+				t.Errorf("retried = %v, want %v", retried, tt.wantRetry)
+			}
+			if tt.wantRetry && time.Until(jobs.RetryAt) < 20*time.Second {
+				t.Errorf("retry at %s is too early", jobs.RetryAt)
+			}
+			if jobs.Failed != tt.wantFailed {
+				t.Errorf("failed = %v, want %v", jobs.Failed, tt.wantFailed)
+			}
+			remaining := 0
+			for key := range store.Objects {
+				if strings.HasPrefix(key, "hls/7/") || strings.HasPrefix(key, "previews/7/") {
+					remaining++
+				}
+			}
+			if remaining != tt.wantRemaining {
+				t.Errorf("hls objects = %d, want %d", remaining, tt.wantRemaining)
+			}
+		})
+	}
+
+	// пустая очередь
+	worker := &TranscodeWorker{Jobs: &MockTranscodeRepository{}}
+	if processed, err := worker.RunOnce(ctx); processed || err != nil {
+		t.Errorf("RunOnce() on empty queue = %v, %v", processed, err)
+	}
+}
+
+func TestVideoService_UploadVideoEnqueuesTranscoding(t *testing.T) {
+	jobs := &MockTranscodeRepository{}
+	var created *models.Video
+	repo := &MockVideoRepository{CreateVideoFn: func(ctx context.Context, video *models.Video) error {
+		video.VideoID = 42
+		created = video
+		return nil
+	}}
+	s := NewVideoService(repo, WithBlobStore(&MockBlobStore{}), WithTranscodeQueue(jobs))
+
+	data := testVideoFile(5)
+	if _, err := s.UploadVideo(context.Background(), 1, "", strings.NewReader(string(data)), int64(len(data))); err != nil {
+		t.Fatalf("UploadVideo() error = %v", err)
+	}
+	if created.Status != models.VideoProcessing {
+		t.Errorf("status = %q, want processing", created.Status)
+	}
+	if !reflect.DeepEqual(jobs.Enqueued, []int64{42}) {
+		t.Errorf("enqueued = %v, want [42]", jobs.Enqueued)
+	}
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/media"
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+const (
+	DefaultUploadTTL       = time.Hour * 24
+	uploadCleanupBatchSize = 100
+
+	// DefaultMaxOpenUploads - сколько незавершенных загрузок может держать
+	// один автор, чтобы брошенные загрузки не заполняли диск
+	DefaultMaxOpenUploads = 3
+)
+
+var ErrUploadNotFound = errors.New("upload not found")
+var ErrUploadExpired = errors.New("upload expired")
+var ErrUploadOffsetMismatch = errors.New("upload offset does not match")
+var ErrUploadTooLarge = errors.New("upload exceeds declared length")
+var ErrUploadLocked = errors.New("upload is being written by another request")
+var ErrInvalidUploadLength = errors.New("invalid upload length")
+var ErrTooManyUploads = errors.New("too many unfinished uploads")
+
+// UploadService - resumable-загрузки видео по частям (протокол tus).
+// Части дописываются во временный файл, подтвержденный offset хранится в БД.
+// Когда получен последний байт, файл публикуется через VideoService.UploadVideo.
+type UploadService interface {
+	Create(ctx context.Context, authorID int64, length int64, description string) (*models.Upload, error)
+	Get(ctx context.Context, authorID int64, uploadID string) (*models.Upload, error)
+	// Append дописывает часть с offset и возвращает новое состояние загрузки;
+	// если загрузка завершена, возвращается и опубликованное видео.
+	Append(ctx context.Context, authorID int64, uploadID string, offset int64, chunk io.Reader) (*models.Upload, *models.Video, error)
+	Terminate(ctx context.Context, authorID int64, uploadID string) error
+	// RunExpiryCleanup периодически удаляет брошенные загрузки; блокирует до отмены ctx
+	RunExpiryCleanup(ctx context.Context, interval time.Duration)
+}
+
+type uploadServiceImpl struct {
+	Repo    repository.UploadRepository
+	Videos  VideoService
+	Dir     string
+	TTL     time.Duration
+	MaxSize int64
+	MaxOpen int
+
+	locks sync.Map // upload_id -> *sync.Mutex
+}
+
+// NewUploadService хранит принятые части в dir; maxSize - предел Upload-Length
+func NewUploadService(repo repository.UploadRepository, videos VideoService, dir string, ttl time.Duration, maxSize int64) (UploadService, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create upload dir: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = DefaultUploadTTL
+	}
+	return &uploadServiceImpl{Repo: repo, Videos: videos, Dir: dir, TTL: ttl, MaxSize: maxSize, MaxOpen: DefaultMaxOpenUploads}, nil
+}
+
+func (s *uploadServiceImpl) Create(ctx context.Context, authorID int64, length int64, description string) (*models.Upload, error) {
+	if authorID == 0 {
+		return nil, ErrAuthorIDRequired
+	}
+	if length <= 0 || length > s.MaxSize {
+		return nil, ErrInvalidUploadLength
+	}
+	if len(description) > 70 {
+		return nil, ErrDescriptionTooLong
+	}
+	// Лимит моментов проверяется до приема файла, чтобы клиент не передавал
+	// его целиком ради отказа в finalize. Окончательно его проверит UploadVideo.
+	if err := s.Videos.CheckMomentLimit(ctx, authorID); err != nil {
+		return nil, err
+	}
+	pending, err := s.Repo.CountOpen(ctx, authorID, time.Now())
+	if err != nil {
+		log.Printf("ERROR: Failed to count open uploads of author %d: %v", authorID, err)
+		return nil, err
+	}
+	if pending >= int64(s.MaxOpen) {
+		log.Printf("INFO: Author %d already has %d unfinished uploads", authorID, pending)
+		return nil, ErrTooManyUploads
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("generate upload id: %w", err)
+	}
+	upload := models.Upload{
+		UploadID:    hex.EncodeToString(buf),
+		AuthorID:    authorID,
+		Length:      length,
+		Description: description,
+		ExpiresAt:   time.Now().Add(s.TTL),
+	}
+
+	f, err := os.OpenFile(s.path(upload.UploadID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("create upload file: %w", err)
+	}
+	f.Close()
+
+	if err := s.Repo.Create(ctx, &upload); err != nil {
+		os.Remove(s.path(upload.UploadID))
+		log.Printf("ERROR: Failed to create upload for author %d: %v", authorID, err)
+		return nil, err
+	}
+
+	log.Printf("INFO: Upload %s created. AuthorID: %d, Length: %d", upload.UploadID, authorID, length)
+	return &upload, nil
+}
+
+func (s *uploadServiceImpl) Get(ctx context.Context, authorID int64, uploadID string) (*models.Upload, error) {
+	upload, err := s.Repo.GetByID(ctx, uploadID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Чужие загрузки не раскрываются
+	if upload.AuthorID != authorID {
+		return nil, ErrUploadNotFound
+	}
+	if upload.VideoID == nil && time.Now().After(upload.ExpiresAt) {
+		return nil, ErrUploadExpired
+	}
+	return upload, nil
+}
+
+func (s *uploadServiceImpl) Append(ctx context.Context, authorID int64, uploadID string, offset int64, chunk io.Reader) (*models.Upload, *models.Video, error) {
+	mu := s.lock(uploadID)
+	if !mu.TryLock() {
+		return nil, nil, ErrUploadLocked
+	}
+	defer mu.Unlock()
+
+	upload, err := s.Get(ctx, authorID, uploadID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if upload.VideoID != nil || offset != upload.Offset {
+		return upload, nil, ErrUploadOffsetMismatch
+	}
+
+	written, writeErr := s.writeChunk(upload, chunk)
+	if written > 0 {
+		// Подтверждаем все, что успело записаться, даже если соединение
+		// оборвалось: клиент продолжит с нового offset
+		rows, err := s.Repo.AdvanceOffset(ctx, uploadID, upload.Offset, upload.Offset+written)
+		if err != nil {
+			return nil, nil, err
+		}
+		if rows == 0 {
+			return nil, nil, ErrUploadOffsetMismatch
+		}
+		upload.Offset += written
+	}
+	if writeErr != nil {
+		log.Printf("WARNING: Upload %s interrupted at offset %d: %v", uploadID, upload.Offset, writeErr)
+		return upload, nil, writeErr
+	}
+
+	if upload.Offset < upload.Length {
+		return upload, nil, nil
+	}
+
+	video, err := s.finalize(ctx, upload)
+	if isRejectedContent(err) {
+		// Повтор не поможет: файл не видео, слишком длинный или лимит моментов исчерпан
+		if rmErr := s.remove(ctx, uploadID); rmErr != nil {
+			log.Printf("WARNING: Failed to remove rejected upload %s: %v", uploadID, rmErr)
+		}
+		return upload, nil, err
+	}
+	if err != nil {
+		// Файл и запись остаются: повторный PATCH с offset = length повторит публикацию
+		return upload, nil, err
+	}
+	return upload, video, nil
+}
+
+func (s *uploadServiceImpl) Terminate(ctx context.Context, authorID int64, uploadID string) error {
+	mu := s.lock(uploadID)
+	if !mu.TryLock() {
+		return ErrUploadLocked
+	}
+	defer mu.Unlock()
+
+	if _, err := s.Get(ctx, authorID, uploadID); err != nil {
+		return err
+	}
+	return s.remove(ctx, uploadID)
+}
+
+func (s *uploadServiceImpl) RunExpiryCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.cleanupExpired(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *uploadServiceImpl) cleanupExpired(ctx context.Context) {
+	uploads, err := s.Repo.ListExpired(ctx, time.Now(), uploadCleanupBatchSize)
+	if err != nil {
+		log.Printf("ERROR: Failed to list expired uploads: %v", err)
+		return
+	}
+	for _, upload := range uploads {
+		mu := s.lock(upload.UploadID)
+		if !mu.TryLock() {
+			continue
+		}
+		if err := s.remove(ctx, upload.UploadID); err != nil {
+			log.Printf("ERROR: Failed to remove expired upload %s: %v", upload.UploadID, err)
+		}
+		mu.Unlock()
+	}
+	if len(uploads) > 0 {
+		log.Printf("INFO: Removed %d expired uploads", len(uploads))
+	}
+}
+
+// writeChunk дописывает часть в файл загрузки, не выходя за Upload-Length.
+func (s *uploadServiceImpl) writeChunk(upload *models.Upload, chunk io.Reader) (int64, error) {
+	f, err := os.OpenFile(s.path(upload.UploadID), os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("open upload file: %w", err)
+	}
+	defer f.Close()
+
+	// Байты после подтвержденного offset (от оборванного запроса,
+	// не успевшего сохранить offset) отбрасываются
+	if err := f.Truncate(upload.Offset); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(upload.Offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	remaining := upload.Length - upload.Offset
+	written, err := io.Copy(f, io.LimitReader(chunk, remaining))
+	if err != nil {
+		return written, err
+	}
+	if written == remaining {
+		var probe [1]byte
+		if n, _ := chunk.Read(probe[:]); n > 0 {
+			// Часть целиком отклоняется, записанное будет обрезано следующим запросом
+			return 0, ErrUploadTooLarge
+		}
+	}
+	return written, f.Sync()
+}
+
+func (s *uploadServiceImpl) finalize(ctx context.Context, upload *models.Upload) (*models.Video, error) {
+	f, err := os.Open(s.path(upload.UploadID))
+	if err != nil {
+		return nil, fmt.Errorf("open upload file: %w", err)
+	}
+	defer f.Close()
+
+	video, err := s.Videos.UploadVideo(ctx, upload.AuthorID, upload.Description, f, upload.Length)
+	if err != nil {
+		log.Printf("ERROR: Failed to publish upload %s: %v", upload.UploadID, err)
+		return nil, err
+	}
+
+	if err := s.Repo.MarkCompleted(ctx, upload.UploadID, video.VideoID); err != nil {
+		log.Printf("WARNING: Failed to mark upload %s as completed: %v", upload.UploadID, err)
+	}
+	upload.VideoID = &video.VideoID
+	if err := os.Remove(s.path(upload.UploadID)); err != nil {
+		log.Printf("WARNING: Could not delete upload file %s: %v", upload.UploadID, err)
+	}
+
+	log.Printf("INFO: Upload %s published as video %d", upload.UploadID, video.VideoID)
+	return video, nil
+}
+
+func isRejectedContent(err error) bool {
+	return errors.Is(err, media.ErrUnsupportedFormat) || errors.Is(err, media.ErrInvalidMedia) ||
+		errors.Is(err, ErrVideoTooLong) || errors.Is(err, ErrDailyMomentLimit)
+}
+
+func (s *uploadServiceImpl) remove(ctx context.Context, uploadID string) error {
+	if err := s.Repo.Delete(ctx, uploadID); err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(uploadID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("WARNING: Could not delete upload file %s: %v", uploadID, err)
+	}
+	s.locks.Delete(uploadID)
+	return nil
+}
+
+func (s *uploadServiceImpl) lock(uploadID string) *sync.Mutex {
+	mu, _ := s.locks.LoadOrStore(uploadID, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+func (s *uploadServiceImpl) path(uploadID string) string {
+	return filepath.Join(s.Dir, uploadID)
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/media"
+	"github.com/merinovvvv/momentic-backend/models"
+	"github.com/merinovvvv/momentic-backend/repository"
+)
+
+// MockUploadRepository - хранит загрузки в памяти
+type MockUploadRepository struct {
+	Uploads map[string]models.Upload
+}
+
+func (m *MockUploadRepository) Create(ctx context.Context, upload *models.Upload) error {
+	m.Uploads[upload.UploadID] = *upload
+	return nil
+}
+func (m *MockUploadRepository) GetByID(ctx context.Context, uploadID string) (*models.Upload, error) {
+	upload, ok := m.Uploads[uploadID]
+	if !ok {
+		return nil, repository.ErrRecordNotFound
+	}
+	return &upload, nil
+}
+func (m *MockUploadRepository) AdvanceOffset(ctx context.Context, uploadID string, from, to int64) (int64, error) {
+	upload, ok := m.Uploads[uploadID]
+	if !ok || upload.Offset != from || upload.VideoID != nil {
+		return 0, nil
+	}
+	upload.Offset = to
+	m.Uploads[uploadID] = upload
+	return 1, nil
+}
+func (m *MockUploadRepository) MarkCompleted(ctx context.Context, uploadID string, videoID int64) error {
+	upload := m.Uploads[uploadID]
+	upload.VideoID = &videoID
+	m.Uploads[uploadID] = upload
+	return nil
+}
+func (m *MockUploadRepository) Delete(ctx context.Context, uploadID string) error {
+	delete(m.Uploads, uploadID)
+	return nil
+}
+func (m *MockUploadRepository) CountOpen(ctx context.Context, authorID int64, now time.Time) (int64, error) {
+	var count int64
+	for _, upload := range m.Uploads {
+		if upload.AuthorID == authorID && upload.VideoID == nil && upload.ExpiresAt.After(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+func (m *MockUploadRepository) ListExpired(ctx context.Context, before time.Time, limit int) ([]models.Upload, error) {
+	var expired []models.Upload
+	for _, upload := range m.Uploads {
+		if upload.ExpiresAt.Before(before) {
+			expired = append(expired, upload)
+		}
+	}
+	return expired, nil
+}
+
+func newTestUploadService(t *testing.T) (*uploadServiceImpl, *MockUploadRepository, *MockBlobStore) {
+	t.Helper()
+	repo := &MockUploadRepository{Uploads: map[string]models.Upload{}}
+	store := &MockBlobStore{}
+	videos := NewVideoService(&MockVideoRepository{
+		CreateVideoFn: func(ctx context.Context, video *models.Video) error {
+			video.VideoID = 77
+			return nil
+		},
+	}, WithBlobStore(store))
+	s, err := NewUploadService(repo, videos, t.TempDir(), time.Hour, 1<<20)
+	if err != nil {
+		t.Fatalf("NewUploadService: %v", err)
+	}
+	return s.(*uploadServiceImpl), repo, store
+}
+
+func TestUploadService_ResumeAndPublish(t *testing.T) {
+	ctx := context.Background()
+	s, _, store := newTestUploadService(t)
+	content := testVideoFile(10)
+
+	upload, err := s.Create(ctx, 10, int64(len(content)), "Test video")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Соединение оборвалось после 4 байт: они должны быть подтверждены
+	errDropped := errors.New("connection reset")
+	broken := io.MultiReader(bytes.NewReader(content[:4]), &failingReader{err: errDropped})
+	got, video, err := s.Append(ctx, 10, upload.UploadID, 0, broken)
+	if !errors.Is(err, errDropped) || video != nil {
+		t.Fatalf("Append (dropped) = %v, %v; want %v", video, err, errDropped)
+	}
+	if got.Offset != 4 {
+		t.Fatalf("offset after dropped chunk = %d, want 4", got.Offset)
+	}
+
+	// Повтор со старым offset отклоняется
+	if _, _, err := s.Append(ctx, 10, upload.UploadID, 0, bytes.NewReader(content)); !errors.Is(err, ErrUploadOffsetMismatch) {
+		t.Fatalf("Append with stale offset: got %v, want ErrUploadOffsetMismatch", err)
+	}
+
+	got, video, err = s.Append(ctx, 10, upload.UploadID, 4, bytes.NewReader(content[4:]))
+	if err != nil {
+		t.Fatalf("Append (final): %v", err)
+	}
+	if video == nil || video.VideoID != 77 || got.VideoID == nil {
+		t.Fatalf("expected published video 77, got video=%v upload=%+v", video, got)
+	}
+	if stored := store.Objects[video.Filepath]; !bytes.Equal(stored, content) {
+		t.Errorf("published file differs from uploaded content (%d of %d bytes)", len(stored), len(content))
+	}
+}
+
+func TestUploadService_RejectedContentIsRemoved(t *testing.T) {
+	ctx := context.Background()
+	s, repo, store := newTestUploadService(t)
+	content := []byte("not a video at all")
+
+	upload, err := s.Create(ctx, 10, int64(len(content)), "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, _, err := s.Append(ctx, 10, upload.UploadID, 0, bytes.NewReader(content)); !errors.Is(err, media.ErrUnsupportedFormat) {
+		t.Fatalf("Append: got %v, want media.ErrUnsupportedFormat", err)
+	}
+	if _, ok := repo.Uploads[upload.UploadID]; ok || len(store.Objects) > 0 {
+		t.Errorf("rejected upload should be removed and not published")
+	}
+}
+
+func TestUploadService_Errors(t *testing.T) {
+	ctx := context.Background()
+	s, repo, _ := newTestUploadService(t)
+
+	if _, err := s.Create(ctx, 10, 2<<20, ""); !errors.Is(err, ErrInvalidUploadLength) {
+		t.Errorf("Create over MaxSize: got %v, want ErrInvalidUploadLength", err)
+	}
+
+	upload, err := s.Create(ctx, 10, 3, "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := s.Get(ctx, 20, upload.UploadID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Get by another user: got %v, want ErrUploadNotFound", err)
+	}
+	if _, _, err := s.Append(ctx, 10, upload.UploadID, 0, strings.NewReader("toolong")); !errors.Is(err, ErrUploadTooLarge) {
+		t.Errorf("Append past length: got %v, want ErrUploadTooLarge", err)
+	}
+
+	expired := repo.Uploads[upload.UploadID]
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	repo.Uploads[upload.UploadID] = expired
+	if _, err := s.Get(ctx, 10, upload.UploadID); !errors.Is(err, ErrUploadExpired) {
+		t.Errorf("Get expired: got %v, want ErrUploadExpired", err)
+	}
+	s.cleanupExpired(ctx)
+	if _, ok := repo.Uploads[upload.UploadID]; ok {
+		t.Errorf("expired upload was not cleaned up")
+	}
+}
+
+func TestUploadService_CreateLimits(t *testing.T) {
+	ctx := context.Background()
+	s, repo, store := newTestUploadService(t)
+
+	// Незавершенных загрузок не больше MaxOpen; истекшие не считаются
+	for i := 0; i < s.MaxOpen; i++ {
+		if _, err := s.Create(ctx, 10, 3, ""); err != nil {
+			t.Fatalf("Create #%d: %v", i+1, err)
+		}
+	}
+	if _, err := s.Create(ctx, 10, 3, ""); !errors.Is(err, ErrTooManyUploads) {
+		t.Errorf("Create over MaxOpen: got %v, want ErrTooManyUploads", err)
+	}
+	if _, err := s.Create(ctx, 20, 3, ""); err != nil {
+		t.Errorf("Create by another author: %v", err)
+	}
+	for id, upload := range repo.Uploads {
+		if upload.AuthorID == 10 {
+			upload.ExpiresAt = time.Now().Add(-time.Minute)
+			repo.Uploads[id] = upload
+			break
+		}
+	}
+	if _, err := s.Create(ctx, 10, 3, ""); err != nil {
+		t.Errorf("Create after an upload expired: %v", err)
+	}
+
+	// Без пересъемок загрузка отклоняется до приема файла
+	s.Videos = NewVideoService(&MockVideoRepository{
+		CountMomentUploadsFn: func(ctx context.Context, authorID int64, momentDate time.Time) (int, error) {
+			return DefaultMaxRetakes + 1, nil
+		},
+	}, WithBlobStore(store))
+	before := len(repo.Uploads)
+	if _, err := s.Create(ctx, 30, 3, ""); !errors.Is(err, ErrDailyMomentLimit) {
+		t.Errorf("Create without retakes left: got %v, want ErrDailyMomentLimit", err)
+	}
+	if len(repo.Uploads) != before {
+		t.Errorf("rejected Create stored an upload")
+	}
+}
+
+type failingReader struct{ err error }
+
+func (r *failingReader) Read(p []byte) (int, error) { return 0, r.err }
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/media"
+)
+
+// Параметры постера и анимированного превью для ячеек ленты
+const (
+	posterMaxSide   = 720 // короткая сторона постера
+	previewSide     = 180 // короткая сторона GIF
+	previewFPS      = 8
+	previewDuration = 3 * time.Second
+	blurHashSide    = 64 // BlurHash считается по уменьшенному постеру
+)
+
+// PreviewPrefix - каталог в хранилище с постером и превью видео
+func PreviewPrefix(videoID int64) string {
+	return fmt.Sprintf("previews/%d", videoID)
+}
+
+// derivedPrefixes - все каталоги с файлами, созданными из исходника видео
+func derivedPrefixes(videoID int64) []string {
+	return []string{HLSPrefix(videoID), PreviewPrefix(videoID)}
+}
+
+// ExtractFrames сохраняет в outDir кадр для постера (poster.png) и кадры
+// превью (preview_001.png, ...). Кодирование JPEG/GIF делает buildPreviews.
+func (t FFmpegTranscoder) ExtractFrames(ctx context.Context, input, outDir string, info media.Info) error {
+	if err := t.run(ctx, posterArgs(input, outDir, info)); err != nil {
+		return err
+	}
+	return t.run(ctx, previewArgs(input, outDir, info))
+}
+
+// posterArgs берет кадр на первой секунде (или в середине короткого видео)
+func posterArgs(input, outDir string, info media.Info) []string {
+	seek := time.Second
+	if info.Duration < 2*seek {
+		seek = info.Duration / 2
+	}
+	width, height := displaySize(info)
+	short := width
+	if height < short {
+		short = height
+	}
+	if short > posterMaxSide {
+		short = posterMaxSide
+	}
+	return []string{"-hide_banner", "-nostdin", "-y",
+		"-ss", strconv.FormatFloat(seek.Seconds(), 'f', 3, 64),
+		"-i", input,
+		"-frames:v", "1",
+		"-vf", "scale=" + scaleExpr(width, height, short),
+		filepath.Join(outDir, "poster.png"),
+	}
+}
+
+// previewArgs - первые previewDuration секунд с частотой previewFPS
+func previewArgs(input, outDir string, info media.Info) []string {
+	width, height := displaySize(info)
+	return []string{"-hide_banner", "-nostdin", "-y",
+		"-t", strconv.FormatFloat(previewDuration.Seconds(), 'f', 3, 64),
+		"-i", input,
+		"-vf", fmt.Sprintf("fps=%d,scale=%s", previewFPS, scaleExpr(width, height, previewSide)),
+		filepath.Join(outDir, "preview_%03d.png"),
+	}
+}
+
+// buildPreviews кодирует кадры из ExtractFrames: постер в JPEG, превью в
+// GIF, и считает BlurHash постера.
+func buildPreviews(framesDir string) (poster, preview []byte, blurHash string, err error) {
+	posterImg, err := readPNG(filepath.Join(framesDir, "poster.png"))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	var posterBuf bytes.Buffer
+	if err := jpeg.Encode(&posterBuf, posterImg, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, nil, "", err
+	}
+
+	bounds := posterImg.Bounds()
+	xComponents, yComponents := 4, 3
+	if bounds.Dx() < bounds.Dy() {
+		xComponents, yComponents = 3, 4
+	}
+	blurHash, err = media.BlurHash(downscale(posterImg, blurHashSide), xComponents, yComponents)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	frames, err := filepath.Glob(filepath.Join(framesDir, "preview_*.png"))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if len(frames) == 0 {
+		return nil, nil, "", fmt.Errorf("no preview frames in %s", framesDir)
+	}
+	sort.Strings(frames)
+	anim := &gif.GIF{}
+	for _, path := range frames {
+		frame, err := readPNG(path)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		paletted := image.NewPaletted(frame.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, frame.Bounds(), frame, frame.Bounds().Min)
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, 100/previewFPS)
+	}
+	var previewBuf bytes.Buffer
+	if err := gif.EncodeAll(&previewBuf, anim); err != nil {
+		return nil, nil, "", err
+	}
+	return posterBuf.Bytes(), previewBuf.Bytes(), blurHash, nil
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// downscale уменьшает изображение до maxSide по длинной стороне (ближайший
+// сосед): для BlurHash детали не нужны, а считать его по полному кадру долго
+func downscale(img image.Image, maxSide int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSide && height <= maxSide {
+		return img
+	}
+	newWidth, newHeight := maxSide, height*maxSide/width
+	if height > width {
+		newWidth, newHeight = width*maxSide/height, maxSide
+	}
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+	small := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		for x := 0; x < newWidth; x++ {
+			small.Set(x, y, img.At(bounds.Min.X+x*width/newWidth, bounds.Min.Y+y*height/newHeight))
+		}
+	}
+	return small
+}
@@ -2,12 +2,19 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
 	"log"
-	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/merinovvvv/momentic-backend/media"
 	"github.com/merinovvvv/momentic-backend/models"
 	"github.com/merinovvvv/momentic-backend/repository"
+	"github.com/merinovvvv/momentic-backend/storage"
 )
 
 // Ошибки, специфичные для сервисного слоя
@@ -15,47 +22,285 @@ var ErrVideoNotFound = errors.New("video not found")
 var ErrDescriptionTooLong = errors.New("description is too long (max 70 chars)")
 var ErrNoFriends = errors.New("user has no friends")
 var ErrAuthorIDRequired = errors.New("author_id is required")
+var ErrForbidden = errors.New("action is not allowed for this user")
+var ErrStorageNotConfigured = errors.New("blob store is not configured")
+var ErrVideoTooLong = errors.New("video is longer than allowed")
+var ErrInvalidFeedDate = errors.New("date must be YYYY-MM-DD and not in the future")
+var ErrInvalidFeedCursor = errors.New("invalid feed cursor")
+var ErrDailyMomentLimit = errors.New("today's moment is already posted and no retakes are left")
+var ErrMomentConflict = errors.New("today's moment was changed by another upload")
+
+// DefaultMaxVideoDuration - предел длительности, если не задан WithMaxDuration
+const DefaultMaxVideoDuration = 60 * time.Second
+
+// DefaultMaxRetakes - сколько раз можно переснять момент дня, если не задан WithMaxRetakes
+const DefaultMaxRetakes = 2
+
+const (
+	DefaultFeedPageSize = 20
+	MaxFeedPageSize     = 50
+)
 
 // VideoService определяет все методы
 type VideoService interface {
-	UploadVideo(ctx context.Context, filepath string, authorID int64, description string) (*models.Video, error)
-	GetTodayFeed(ctx context.Context, userID int64) ([]models.Video, error)
-	DeleteVideo(ctx context.Context, videoID int64) error
-	UpdateDescription(ctx context.Context, videoID int64, description string) error
+	UploadVideo(ctx context.Context, authorID int64, description string, content io.ReaderAt, size int64) (*models.Video, error)
+	// GetFeed - видео друзей за локальный день пользователя: сегодня, если
+	// date пустая, иначе за date в формате YYYY-MM-DD
+	GetFeed(ctx context.Context, userID int64, date string) ([]models.Video, error)
+	// GetFeedPage - та же лента, но страницами по курсору и с автором,
+	// реакциями и комментариями в каждом элементе
+	GetFeedPage(ctx context.Context, viewerID int64, date, cursor string, limit int) (*FeedPage, error)
+	DeleteVideo(ctx context.Context, actor Actor, videoID int64) error
+	UpdateDescription(ctx context.Context, actor Actor, videoID int64, description string) error
+	// OpenStream открывает файл видео для автора или его друга
+	OpenStream(ctx context.Context, viewerID int64, videoID int64) (*VideoStream, error)
+	// CheckUnlocked возвращает ErrForbidden, если видео закрыто зрителю
+	// правилом post-to-see (как в ленте), и ErrVideoNotFound для
+	// несуществующего видео. Комментарии и реакции проверяют его перед доступом.
+	CheckUnlocked(ctx context.Context, viewerID int64, videoID int64) error
+	// CheckMomentLimit возвращает ErrDailyMomentLimit, если автор уже
+	// исчерпал пересъемки момента сегодняшнего дня. Загрузки проверяют его
+	// до приема файла.
+	CheckMomentLimit(ctx context.Context, authorID int64) error
+}
+
+// VideoStream - файл видео для отдачи с поддержкой Range; Content нужно закрыть
+type VideoStream struct {
+	Video   *models.Video
+	Content io.ReadSeekCloser
+	ModTime time.Time
+	ETag    string
+}
+
+// FeedPage - страница ленты; NextCursor пустой на последней странице.
+// Locked - зритель еще не опубликовал момент, элементы заменены заглушками.
+type FeedPage struct {
+	Items      []models.FeedItem `json:"items"`
+	NextCursor string            `json:"next_cursor"`
+	Locked     bool              `json:"locked"`
 }
 
 type videoServiceImpl struct {
-	Repo repository.VideoRepository
+	Repo  repository.VideoRepository
+	Audit repository.AuditRepository
+	Store storage.BlobStore
+	Jobs  repository.TranscodeRepository
+
+	Moments MomentService
+	Streaks StreakService
+	Ratings RatingService
+
+	MaxDuration time.Duration
+	MaxRetakes  int
+	PostToSee   bool
+
+	now func() time.Time
+}
+
+// VideoServiceOption подключает к сервису необязательные зависимости
+type VideoServiceOption func(*videoServiceImpl)
+
+// WithAuditRepository включает запись действий модераторов в журнал аудита
+func WithAuditRepository(audit repository.AuditRepository) VideoServiceOption {
+	return func(s *videoServiceImpl) {
+		s.Audit = audit
+	}
+}
+
+// WithBlobStore задает хранилище файлов видео
+func WithBlobStore(store storage.BlobStore) VideoServiceOption {
+	return func(s *videoServiceImpl) {
+		s.Store = store
+	}
+}
+
+// WithMaxDuration задает максимальную длительность загружаемого видео
+func WithMaxDuration(d time.Duration) VideoServiceOption {
+	return func(s *videoServiceImpl) {
+		s.MaxDuration = d
+	}
+}
+
+// WithTranscodeQueue включает перекодирование в HLS: новые видео получают
+// статус processing и попадают в ленту после обработки TranscodeWorker
+func WithTranscodeQueue(jobs repository.TranscodeRepository) VideoServiceOption {
+	return func(s *videoServiceImpl) {
+		s.Jobs = jobs
+	}
+}
+
+// WithMaxRetakes задает число пересъемок момента за день; 0 - без пересъемок
+func WithMaxRetakes(n int) VideoServiceOption {
+	return func(s *videoServiceImpl) {
+		if n >= 0 {
+			s.MaxRetakes = n
+		}
+	}
+}
+
+// WithPostToSee включает правило "опубликуй, чтобы увидеть": пока зритель
+// не выложил сегодняшний момент, сегодняшняя лента отдается заглушками
+func WithPostToSee(enabled bool) VideoServiceOption {
+	return func(s *videoServiceImpl) {
+		s.PostToSee = enabled
+	}
+}
+
+// WithStreakService засчитывает публикации в серию автора
+func WithStreakService(streaks StreakService) VideoServiceOption {
+	return func(s *videoServiceImpl) {
+		s.Streaks = streaks
+	}
 }
 
-func NewVideoService(repo repository.VideoRepository) VideoService {
-	return &videoServiceImpl{Repo: repo}
+// WithRatingService начисляет автору рейтинг за публикацию момента
+func WithRatingService(ratings RatingService) VideoServiceOption {
+	return func(s *videoServiceImpl) {
+		s.Ratings = ratings
+	}
+}
+
+// WithMomentService помечает видео как опубликованные вовремя или с
+// опозданием относительно окна момента дня автора
+func WithMomentService(moments MomentService) VideoServiceOption {
+	return func(s *videoServiceImpl) {
+		s.Moments = moments
+	}
+}
+
+func NewVideoService(repo repository.VideoRepository, opts ...VideoServiceOption) VideoService {
+	s := &videoServiceImpl{Repo: repo, MaxDuration: DefaultMaxVideoDuration, MaxRetakes: DefaultMaxRetakes, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // --- UploadVideo (Создание) ---
-func (s *videoServiceImpl) UploadVideo(ctx context.Context, filepath string, authorID int64, description string) (*models.Video, error) {
+// Тип файла определяется по содержимому (только MP4/QuickTime), метаданные
+// читаются из структуры MP4. Файл сохраняется в хранилище под ключом
+// videos/<author>_<nano><ext>, в БД записывается этот ключ.
+// Если запись в БД не удалась, файл удаляется. У автора один момент в
+// локальный день, пересъемка заменяет его не более MaxRetakes раз.
+func (s *videoServiceImpl) UploadVideo(ctx context.Context, authorID int64, description string, content io.ReaderAt, size int64) (*models.Video, error) {
 	if authorID == 0 {
 		return nil, ErrAuthorIDRequired
 	}
+	if s.Store == nil {
+		return nil, ErrStorageNotConfigured
+	}
+
+	// Один момент в локальный день автора; повторная загрузка - пересъемка,
+	// которая заменяет видео дня, пока не кончился лимит
+	day, moment, err := s.momentDay(ctx, authorID)
+	if err != nil {
+		log.Printf("ERROR: Failed to resolve the local day of author %d: %v", authorID, err)
+		return nil, err
+	}
+	uploads, err := s.momentUploads(ctx, authorID, day)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.Repo.GetVideoByMomentDate(ctx, authorID, day)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		previous = nil
+	} else if err != nil {
+		log.Printf("ERROR: Failed to load moment video of author %d: %v", authorID, err)
+		return nil, err
+	}
+
+	format, info, err := media.Probe(content, size)
+	if err != nil {
+		log.Printf("WARNING: Rejected upload of author %d: %v", authorID, err)
+		return nil, err
+	}
+	if s.MaxDuration > 0 && info.Duration > s.MaxDuration {
+		log.Printf("WARNING: Rejected upload of author %d: duration %s exceeds %s", authorID, info.Duration, s.MaxDuration)
+		return nil, ErrVideoTooLong
+	}
+
+	key := fmt.Sprintf("videos/%d_%d%s", authorID, time.Now().UnixNano(), format.Extension)
+	if err := s.Store.Put(ctx, key, io.NewSectionReader(content, 0, size), size, format.MimeType); err != nil {
+		log.Printf("ERROR: Failed to store video file %s for author %d: %v", key, authorID, err)
+		return nil, err
+	}
 
 	newVideo := models.Video{
-		Filepath:    filepath,
+		Filepath:    key,
 		AuthorID:    authorID,
 		Description: description,
+		MimeType:    format.MimeType,
+		DurationMs:  info.Duration.Milliseconds(),
+		Width:       info.Width,
+		Height:      info.Height,
+		Rotation:    info.Rotation,
+		Codec:       info.Codec,
+		Status:      models.VideoReady,
+		MomentDate:  &day,
+		RetakeCount: uploads,
+	}
+	if s.Jobs != nil {
+		newVideo.Status = models.VideoProcessing
+	}
+	if moment != nil {
+		newVideo.PostedLate = !moment.IsOnTime(s.now())
 	}
 
-	err := s.Repo.CreateVideo(ctx, &newVideo)
+	if previous != nil {
+		err = s.Repo.ReplaceVideo(ctx, previous, &newVideo)
+	} else {
+		err = s.Repo.CreateVideo(ctx, &newVideo)
+	}
 	if err != nil {
 		log.Printf("ERROR: Failed to create video in DB for author %d: %v", authorID, err)
+		if delErr := s.Store.Delete(ctx, key); delErr != nil {
+			log.Printf("WARNING: Could not delete orphaned file %s: %v", key, delErr)
+		}
+		if errors.Is(err, repository.ErrMomentTaken) {
+			return nil, ErrMomentConflict
+		}
+		return nil, err
+	}
+	if previous != nil {
+		s.deleteFiles(ctx, previous)
+		log.Printf("INFO: Video %d replaced by retake %d of author %d", previous.VideoID, newVideo.RetakeCount, authorID)
+	}
+	// Ошибки серии и рейтинга не отменяют уже сохраненную публикацию
+	if s.Streaks != nil {
+		if err := s.Streaks.RecordPost(ctx, authorID, day); err != nil {
+			log.Printf("WARNING: Failed to record streak of author %d: %v", authorID, err)
+		}
+	}
+	// Очки за публикацию начисляются один раз за день: журнал рейтинга сам
+	// отбрасывает пересъемки и повторные загрузки после удаления
+	if s.Ratings != nil {
+		if err := s.Ratings.RecordPost(ctx, authorID, newVideo.VideoID, day, newVideo.PostedLate); err != nil {
+			log.Printf("WARNING: Failed to record rating of author %d: %v", authorID, err)
+		}
+	}
+
+	if s.Jobs != nil {
+		// Если задача не создалась, видео подберет TranscodeWorker (EnqueueMissing)
+		if err := s.Jobs.Enqueue(ctx, newVideo.VideoID); err != nil {
+			log.Printf("WARNING: Failed to enqueue transcoding of video %d: %v", newVideo.VideoID, err)
+		}
 	}
 
 	log.Printf("INFO: Video uploaded successfully. ID: %d, AuthorID: %d", newVideo.VideoID, authorID)
 
-	return &newVideo, err
+	return &newVideo, nil
 }
 
-// --- GetTodayFeed (Чтение) ---
-func (s *videoServiceImpl) GetTodayFeed(ctx context.Context, userID int64) ([]models.Video, error) {
+// --- GetFeed (Чтение) ---
+// Границы дня считаются в часовом поясе зрителя, а не в UTC
+func (s *videoServiceImpl) GetFeed(ctx context.Context, userID int64, date string) ([]models.Video, error) {
+	from, to, err := s.feedDay(ctx, userID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	// Блокировка замещает статус friends, поэтому видео заблокировавших
+	// пользователей (и заблокированных) в ленту не попадают
 	friendIDs, err := s.Repo.GetFriendsIDs(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -66,21 +311,221 @@ func (s *videoServiceImpl) GetTodayFeed(ctx context.Context, userID int64) ([]mo
 		return []models.Video{}, ErrNoFriends
 	}
 
-	videos, err := s.Repo.GetTodayVideosByAuthors(ctx, friendIDs)
+	videos, err := s.Repo.GetVideosByAuthors(ctx, friendIDs, from, to)
+
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch videos of %s for user %d: %v", from.Format("2006-01-02"), userID, err)
+		return nil, err
+	}
 
+	locked, err := s.feedLocked(ctx, userID, from)
 	if err != nil {
-		log.Printf("ERROR: Failed to fetch today's videos for user %d: %v", userID, err)
 		return nil, err
 	}
+	if locked {
+		for i := range videos {
+			videos[i] = lockedVideo(videos[i])
+		}
+	}
 
 	log.Printf("INFO: Successfully retrieved %d videos for user %d.", len(videos), userID)
 	return videos, err
 }
 
+// --- GetFeedPage (Чтение) ---
+func (s *videoServiceImpl) GetFeedPage(ctx context.Context, viewerID int64, date, cursor string, limit int) (*FeedPage, error) {
+	if limit <= 0 {
+		limit = DefaultFeedPageSize
+	}
+	if limit > MaxFeedPageSize {
+		limit = MaxFeedPageSize
+	}
+	var after *models.FeedCursor
+	if cursor != "" {
+		decoded, err := decodeFeedCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &decoded
+	}
+
+	from, to, err := s.feedDay(ctx, viewerID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	// Лишний элемент показывает, есть ли следующая страница
+	items, err := s.Repo.ListFeed(ctx, viewerID, from, to, after, limit+1)
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch feed page for user %d: %v", viewerID, err)
+		return nil, err
+	}
+
+	locked, err := s.feedLocked(ctx, viewerID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &FeedPage{Items: items, Locked: locked}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		last := page.Items[limit-1]
+		page.NextCursor = encodeFeedCursor(models.FeedCursor{CreatedAt: last.CreatedAt, VideoID: last.VideoID})
+	}
+	if locked {
+		for i := range page.Items {
+			page.Items[i] = lockedFeedItem(page.Items[i])
+		}
+	}
+	if page.Items == nil {
+		page.Items = []models.FeedItem{}
+	}
+	return page, nil
+}
+
+// feedLocked - закрыта ли лента дня, начинающегося в from. Закрывается только
+// сегодняшняя лента и только пока у зрителя нет своего момента за сегодня;
+// прошедшие дни открыты.
+func (s *videoServiceImpl) feedLocked(ctx context.Context, viewerID int64, from time.Time) (bool, error) {
+	if !s.PostToSee {
+		return false, nil
+	}
+	today, _, err := s.momentDay(ctx, viewerID)
+	if err != nil {
+		return false, err
+	}
+	if from.Format("2006-01-02") != today.Format("2006-01-02") {
+		return false, nil
+	}
+	_, err = s.Repo.GetVideoByMomentDate(ctx, viewerID, today)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return true, nil
+	}
+	return false, err
+}
+
+// lockedVideo оставляет от видео только автора, время и BlurHash
+func lockedVideo(v models.Video) models.Video {
+	return models.Video{AuthorID: v.AuthorID, CreatedAt: v.CreatedAt, BlurHash: v.BlurHash, Locked: true}
+}
+
+func lockedFeedItem(item models.FeedItem) models.FeedItem {
+	return models.FeedItem{Author: item.Author, CreatedAt: item.CreatedAt, BlurHash: item.BlurHash, Locked: true}
+}
+
+// Курсор непрозрачен для клиента: base64 от "<created_at в мкс>.<video_id>".
+// Микросекунды - точность timestamptz в Postgres.
+func encodeFeedCursor(c models.FeedCursor) string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixMicro(), 10) + "." + strconv.FormatInt(c.VideoID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeFeedCursor(cursor string) (models.FeedCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return models.FeedCursor{}, ErrInvalidFeedCursor
+	}
+	micros, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return models.FeedCursor{}, ErrInvalidFeedCursor
+	}
+	createdAt, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return models.FeedCursor{}, ErrInvalidFeedCursor
+	}
+	videoID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || videoID <= 0 {
+		return models.FeedCursor{}, ErrInvalidFeedCursor
+	}
+	return models.FeedCursor{CreatedAt: time.UnixMicro(createdAt), VideoID: videoID}, nil
+}
+
+// feedDay возвращает границы запрошенного локального дня пользователя
+func (s *videoServiceImpl) feedDay(ctx context.Context, userID int64, date string) (time.Time, time.Time, error) {
+	loc, err := s.userLocation(ctx, userID)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	today, tomorrow := models.LocalDay(s.now(), loc)
+	if date == "" {
+		return today, tomorrow, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil || day.After(today) {
+		return time.Time{}, time.Time{}, ErrInvalidFeedDate
+	}
+	from, to := models.LocalDay(day, loc)
+	return from, to, nil
+}
+
+// userLocation - часовой пояс пользователя; некорректный заменяется на UTC
+func (s *videoServiceImpl) userLocation(ctx context.Context, userID int64) (*time.Location, error) {
+	timezone, err := s.Repo.GetUserTimezone(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := models.LoadTimezone(timezone)
+	if err != nil {
+		log.Printf("WARNING: User %d has invalid timezone %q, using %s", userID, timezone, models.DefaultTimezone)
+		loc = time.UTC
+	}
+	return loc, nil
+}
+
+// momentDay - локальная дата автора (полночь UTC, как в DATE) и момент
+// этого дня, если подключен MomentService. Момент нужен только для пометки
+// опоздания, поэтому без него дата считается по поясу пользователя.
+func (s *videoServiceImpl) momentDay(ctx context.Context, authorID int64) (time.Time, *models.DailyMoment, error) {
+	if s.Moments != nil {
+		moment, err := s.Moments.Today(ctx, authorID)
+		if err == nil {
+			return moment.LocalDate, moment, nil
+		}
+		log.Printf("WARNING: Failed to load today's moment for user %d, posts are not marked late: %v", authorID, err)
+	}
+	loc, err := s.userLocation(ctx, authorID)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	start, _ := models.LocalDay(s.now(), loc)
+	return models.CalendarDate(start), nil, nil
+}
+
+func (s *videoServiceImpl) CheckMomentLimit(ctx context.Context, authorID int64) error {
+	day, _, err := s.momentDay(ctx, authorID)
+	if err != nil {
+		log.Printf("ERROR: Failed to resolve the local day of author %d: %v", authorID, err)
+		return err
+	}
+	_, err = s.momentUploads(ctx, authorID, day)
+	return err
+}
+
+// momentUploads - число загрузок момента day или ErrDailyMomentLimit, если
+// пересъемок не осталось. Считаются все загрузки дня, включая удаленные
+// видео: иначе удаление и повторная загрузка обходили бы лимит.
+func (s *videoServiceImpl) momentUploads(ctx context.Context, authorID int64, day time.Time) (int, error) {
+	uploads, err := s.Repo.CountMomentUploads(ctx, authorID, day)
+	if err != nil {
+		log.Printf("ERROR: Failed to count moment uploads of author %d: %v", authorID, err)
+		return 0, err
+	}
+	if uploads > s.MaxRetakes {
+		log.Printf("INFO: Author %d hit the daily moment limit (%d uploads)", authorID, uploads)
+		return 0, ErrDailyMomentLimit
+	}
+	return uploads, nil
+}
+
 // --- DeleteVideo (Удаление) ---
-func (s *videoServiceImpl) DeleteVideo(ctx context.Context, videoID int64) error {
+func (s *videoServiceImpl) DeleteVideo(ctx context.Context, actor Actor, videoID int64) error {
+	video, err := s.authorize(ctx, actor, videoID, "video.delete")
+	if err != nil {
+		return err
+	}
 
-	video, err := s.Repo.DeleteVideo(ctx, videoID)
+	video, err = s.Repo.DeleteVideo(ctx, videoID)
 	if errors.Is(err, repository.ErrRecordNotFound) {
 		log.Printf("ERROR: Video not found. Video id %d:", videoID)
 		return ErrVideoNotFound
@@ -90,36 +535,185 @@ func (s *videoServiceImpl) DeleteVideo(ctx context.Context, videoID int64) error
 		return err
 	}
 
-	if err := os.Remove(video.Filepath); err != nil {
-		log.Printf("WARNING: Could not delete file %s from disk after DB success: %v", video.Filepath, err)
-	}
+	s.deleteFiles(ctx, video)
 
 	log.Printf("INFO: Video deleted successfully. ID: %d", videoID)
 	return nil
 }
 
+// deleteFiles удаляет из хранилища файлы уже удаленного из БД видео;
+// ошибки только логируются
+func (s *videoServiceImpl) deleteFiles(ctx context.Context, video *models.Video) {
+	if s.Store == nil {
+		log.Printf("WARNING: Blob store is not configured, file %s of video %d is left in place", video.Filepath, video.VideoID)
+		return
+	}
+	if err := s.Store.Delete(ctx, video.Filepath); err != nil {
+		log.Printf("WARNING: Could not delete file %s from storage after DB success: %v", video.Filepath, err)
+	}
+	// HLS-варианты, постер и превью
+	for _, prefix := range derivedPrefixes(video.VideoID) {
+		if err := s.Store.DeletePrefix(ctx, prefix); err != nil {
+			log.Printf("WARNING: Could not delete %s of video %d: %v", prefix, video.VideoID, err)
+		}
+	}
+}
+
 // --- UpdateDescription (Обновление) ---
-func (s *videoServiceImpl) UpdateDescription(ctx context.Context, videoID int64, description string) error {
+func (s *videoServiceImpl) UpdateDescription(ctx context.Context, actor Actor, videoID int64, description string) error {
 	if len(description) > 70 {
 		return ErrDescriptionTooLong
 	}
 
+	if _, err := s.authorize(ctx, actor, videoID, "video.update_description"); err != nil {
+		return err
+	}
+
 	rowsAffected, err := s.Repo.UpdateDescription(ctx, videoID, description)
 	if err != nil {
 		return err
 	}
 
+	// Чужое видео отсекает authorize (ErrForbidden); сюда попадает только
+	// видео, удаленное между проверкой и обновлением
 	if rowsAffected == 0 {
-		_, err = s.Repo.GetVideoByID(ctx, videoID)
-		if errors.Is(err, repository.ErrRecordNotFound) {
-			return ErrVideoNotFound
+		log.Printf("INFO: Video %d was deleted before its description was updated", videoID)
+		return ErrVideoNotFound
+	}
+
+	log.Printf("INFO: Video description updated successfully. ID: %d", videoID)
+	return nil
+}
+
+// --- OpenStream (Просмотр) ---
+func (s *videoServiceImpl) OpenStream(ctx context.Context, viewerID int64, videoID int64) (*VideoStream, error) {
+	if s.Store == nil {
+		return nil, ErrStorageNotConfigured
+	}
+
+	video, err := s.Repo.GetVideoByID(ctx, videoID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, ErrVideoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Видео видят только автор и его друзья (блокировка замещает статус friends)
+	if video.AuthorID != viewerID {
+		// до окончания обработки видео есть только у автора, как и в ленте
+		if video.Status != models.VideoReady {
+			return nil, ErrVideoNotFound
 		}
+		friends, err := s.Repo.AreFriends(ctx, viewerID, video.AuthorID)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if !friends {
+			log.Printf("WARNING: User %d is not allowed to watch video %d of author %d", viewerID, videoID, video.AuthorID)
+			return nil, ErrForbidden
+		}
+		locked, err := s.videoLocked(ctx, viewerID, video)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			log.Printf("INFO: Video %d is locked for user %d until they post today's moment", videoID, viewerID)
+			return nil, ErrForbidden
 		}
-		log.Printf("INFO: Video description update skipped. ID: %d", videoID)
 	}
 
-	log.Printf("INFO: Video description updated successfully. ID: %d", videoID)
+	info, err := s.Store.Stat(ctx, video.Filepath)
+	if errors.Is(err, storage.ErrNotFound) {
+		log.Printf("ERROR: File %s of video %d is missing in storage", video.Filepath, videoID)
+		return nil, ErrVideoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &VideoStream{
+		Video:   video,
+		Content: storage.NewSeeker(ctx, s.Store, video.Filepath, info.Size),
+		ModTime: info.ModTime,
+		ETag:    storage.ETag(video.Filepath, info),
+	}, nil
+}
+
+func (s *videoServiceImpl) CheckUnlocked(ctx context.Context, viewerID int64, videoID int64) error {
+	if !s.PostToSee {
+		return nil
+	}
+	video, err := s.Repo.GetVideoByID(ctx, videoID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return ErrVideoNotFound
+	}
+	if err != nil {
+		return err
+	}
+	locked, err := s.videoLocked(ctx, viewerID, video)
+	if err != nil {
+		return err
+	}
+	if locked {
+		log.Printf("INFO: Video %d is locked for user %d until they post today's moment", videoID, viewerID)
+		return ErrForbidden
+	}
 	return nil
 }
+
+// videoLocked - закрыто ли чужое видео зрителю: как и в ленте, закрыты
+// видео сегодняшнего дня зрителя, пока он сам не опубликовал момент.
+// ID видео последовательны, поэтому проверка нужна и вне ленты.
+func (s *videoServiceImpl) videoLocked(ctx context.Context, viewerID int64, video *models.Video) (bool, error) {
+	if !s.PostToSee || video.AuthorID == viewerID {
+		return false, nil
+	}
+	from, to, err := s.feedDay(ctx, viewerID, "")
+	if err != nil {
+		return false, err
+	}
+	if video.CreatedAt.Before(from) || !video.CreatedAt.Before(to) {
+		return false, nil
+	}
+	return s.feedLocked(ctx, viewerID, from)
+}
+
+// authorize проверяет, что actor - автор видео. Модератор может действовать
+// над чужим видео, такое действие записывается в журнал аудита.
+func (s *videoServiceImpl) authorize(ctx context.Context, actor Actor, videoID int64, action string) (*models.Video, error) {
+	video, err := s.Repo.GetVideoByID(ctx, videoID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, ErrVideoNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if video.AuthorID == actor.UserID {
+		return video, nil
+	}
+	if !actor.CanModerate() {
+		log.Printf("WARNING: User %d is not allowed to %s video %d of author %d", actor.UserID, action, videoID, video.AuthorID)
+		return nil, ErrForbidden
+	}
+
+	entry := models.AuditLog{
+		ActorID:    actor.UserID,
+		ActorRole:  actor.Role,
+		Action:     action,
+		TargetType: "video",
+		TargetID:   videoID,
+		Details:    fmt.Sprintf("author_id=%d", video.AuthorID),
+	}
+	if s.Audit == nil {
+		return nil, errors.New("audit repository is not configured")
+	}
+	// Без записи в аудит модераторское действие не выполняется
+	if err := s.Audit.Create(ctx, &entry); err != nil {
+		log.Printf("ERROR: Failed to write audit log for %s on video %d by %d: %v", action, videoID, actor.UserID, err)
+		return nil, err
+	}
+	log.Printf("INFO: Moderator %d (%s) override: %s on video %d", actor.UserID, actor.Role, action, videoID)
+	return video, nil
+}
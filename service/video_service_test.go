@@ -1,13 +1,22 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/binary"
 	"errors"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/merinovvvv/momentic-backend/media"
 	"github.com/merinovvvv/momentic-backend/models"
 	"github.com/merinovvvv/momentic-backend/repository"
+	"github.com/merinovvvv/momentic-backend/storage"
 )
 
 var errTestDB = errors.New("DB test error")
@@ -15,12 +24,20 @@ var errTestDB = errors.New("DB test error")
 // MockVideoRepository - структура, имитирующая репозиторий
 type MockVideoRepository struct {
 	// Поля для имитации поведения
-	CreateVideoFn             func(ctx context.Context, video *models.Video) error
-	DeleteVideoFn             func(ctx context.Context, videoID int64) (*models.Video, error)
-	UpdateDescriptionFn       func(ctx context.Context, videoID int64, description string) (int64, error)
-	GetFriendsIDsFn           func(ctx context.Context, userID int64) ([]int64, error)
-	GetTodayVideosByAuthorsFn func(ctx context.Context, authorIDs []int64) ([]models.Video, error)
-	GetVideoByIDFn            func(ctx context.Context, videoID int64) (*models.Video, error)
+	CreateVideoFn        func(ctx context.Context, video *models.Video) error
+	DeleteVideoFn        func(ctx context.Context, videoID int64) (*models.Video, error)
+	UpdateDescriptionFn  func(ctx context.Context, videoID int64, description string) (int64, error)
+	GetFriendsIDsFn      func(ctx context.Context, userID int64) ([]int64, error)
+	GetVideosByAuthorsFn func(ctx context.Context, authorIDs []int64, from, to time.Time) ([]models.Video, error)
+	GetUserTimezoneFn    func(ctx context.Context, userID int64) (string, error)
+	ListFeedFn           func(ctx context.Context, viewerID int64, from, to time.Time, after *models.FeedCursor, limit int) ([]models.FeedItem, error)
+	GetVideoByIDFn       func(ctx context.Context, videoID int64) (*models.Video, error)
+	AreFriendsFn         func(ctx context.Context, userID, otherID int64) (bool, error)
+	// nil - у автора еще нет видео за день
+	GetVideoByMomentDateFn func(ctx context.Context, authorID int64, momentDate time.Time) (*models.Video, error)
+	ReplaceVideoFn         func(ctx context.Context, old *models.Video, video *models.Video) error
+	// nil - загрузки дня считаются по видео из GetVideoByMomentDate
+	CountMomentUploadsFn func(ctx context.Context, authorID int64, momentDate time.Time) (int, error)
 }
 
 // Реализация методов интерфейса Repository
@@ -36,43 +53,175 @@ func (m *MockVideoRepository) UpdateDescription(ctx context.Context, videoID int
 func (m *MockVideoRepository) GetFriendsIDs(ctx context.Context, userID int64) ([]int64, error) {
 	return m.GetFriendsIDsFn(ctx, userID)
 }
-func (m *MockVideoRepository) GetTodayVideosByAuthors(ctx context.Context, authorIDs []int64) ([]models.Video, error) {
-	return m.GetTodayVideosByAuthorsFn(ctx, authorIDs)
+func (m *MockVideoRepository) GetVideosByAuthors(ctx context.Context, authorIDs []int64, from, to time.Time) ([]models.Video, error) {
+	return m.GetVideosByAuthorsFn(ctx, authorIDs, from, to)
+}
+func (m *MockVideoRepository) ListFeed(ctx context.Context, viewerID int64, from, to time.Time, after *models.FeedCursor, limit int) ([]models.FeedItem, error) {
+	return m.ListFeedFn(ctx, viewerID, from, to, after, limit)
+}
+func (m *MockVideoRepository) GetUserTimezone(ctx context.Context, userID int64) (string, error) {
+	if m.GetUserTimezoneFn == nil {
+		return models.DefaultTimezone, nil
+	}
+	return m.GetUserTimezoneFn(ctx, userID)
 }
 func (m *MockVideoRepository) GetVideoByID(ctx context.Context, videoID int64) (*models.Video, error) {
 	return m.GetVideoByIDFn(ctx, videoID)
 }
+func (m *MockVideoRepository) GetVideoByMomentDate(ctx context.Context, authorID int64, momentDate time.Time) (*models.Video, error) {
+	if m.GetVideoByMomentDateFn == nil {
+		return nil, repository.ErrRecordNotFound
+	}
+	return m.GetVideoByMomentDateFn(ctx, authorID, momentDate)
+}
+func (m *MockVideoRepository) CountMomentUploads(ctx context.Context, authorID int64, momentDate time.Time) (int, error) {
+	if m.CountMomentUploadsFn != nil {
+		return m.CountMomentUploadsFn(ctx, authorID, momentDate)
+	}
+	video, err := m.GetVideoByMomentDate(ctx, authorID, momentDate)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return video.RetakeCount + 1, nil
+}
+func (m *MockVideoRepository) ReplaceVideo(ctx context.Context, old *models.Video, video *models.Video) error {
+	return m.ReplaceVideoFn(ctx, old, video)
+}
+func (m *MockVideoRepository) AreFriends(ctx context.Context, userID, otherID int64) (bool, error) {
+	return m.AreFriendsFn(ctx, userID, otherID)
+}
+
+// MockAuditRepository - имитация журнала аудита
+type MockAuditRepository struct {
+	CreateFn func(entry *models.AuditLog) error
+	Calls    int
+}
+
+func (m *MockAuditRepository) Create(ctx context.Context, entry *models.AuditLog) error {
+	m.Calls++
+	if m.CreateFn != nil {
+		return m.CreateFn(entry)
+	}
+	return nil
+}
+
+// MockBlobStore - имитация хранилища файлов
+type MockBlobStore struct {
+	PutErr  error
+	Objects map[string][]byte
+	Deleted []string
+
+	DeletedPrefixes []string
+}
+
+func (m *MockBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if m.PutErr != nil {
+		return m.PutErr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if m.Objects == nil {
+		m.Objects = map[string][]byte{}
+	}
+	m.Objects[key] = data
+	return nil
+}
+func (m *MockBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(string(m.Objects[key]))), nil
+}
+func (m *MockBlobStore) OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	data := m.Objects[key]
+	return io.NopCloser(bytes.NewReader(data[offset : offset+length])), nil
+}
+func (m *MockBlobStore) Stat(ctx context.Context, key string) (storage.ObjectInfo, error) {
+	data, ok := m.Objects[key]
+	if !ok {
+		return storage.ObjectInfo{}, storage.ErrNotFound
+	}
+	return storage.ObjectInfo{Size: int64(len(data)), ModTime: time.Unix(1700000000, 0)}, nil
+}
+func (m *MockBlobStore) Delete(ctx context.Context, key string) error {
+	m.Deleted = append(m.Deleted, key)
+	delete(m.Objects, key)
+	return nil
+}
+func (m *MockBlobStore) DeletePrefix(ctx context.Context, prefix string) error {
+	m.DeletedPrefixes = append(m.DeletedPrefixes, prefix)
+	for key := range m.Objects {
+		if strings.HasPrefix(key, prefix+"/") {
+			delete(m.Objects, key)
+		}
+	}
+	return nil
+}
+
+// testVideoFile собирает минимальный MP4 с одной видеодорожкой (1280x720, avc1)
+func testVideoFile(seconds uint32) []byte {
+	box := func(typ string, payload ...[]byte) []byte {
+		body := bytes.Join(payload, nil)
+		out := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+		return append(append(out, typ...), body...)
+	}
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+	identity := bytes.Join([][]byte{u32(1 << 16), u32(0), u32(0), u32(0), u32(1 << 16), u32(0), u32(0), u32(0), u32(1 << 30)}, nil)
+	tkhd := bytes.Join([][]byte{make([]byte, 40), identity, u32(1280 << 16), u32(720 << 16)}, nil)
+	mdhd := bytes.Join([][]byte{make([]byte, 12), u32(1000), u32(seconds * 1000), u32(0)}, nil)
+	hdlr := bytes.Join([][]byte{u32(0), u32(0), []byte("vide"), make([]byte, 13)}, nil)
+	stsd := box("stsd", u32(0), u32(1), box("avc1", make([]byte, 78)))
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), u32(0), []byte("isom")),
+		box("moov",
+			box("mvhd", make([]byte, 12), u32(1000), u32(seconds*1000), make([]byte, 80)),
+			box("trak", box("tkhd", tkhd), box("mdia", box("mdhd", mdhd), box("hdlr", hdlr), box("minf", box("stbl", stsd))))),
+		box("mdat", make([]byte, 32)),
+	}, nil)
+}
 
 // --- UploadVideo (Создание) ---
 
 func TestVideoService_UploadVideo(t *testing.T) {
 	ctx := context.Background()
+	errStorage := errors.New("storage unavailable")
 
 	tests := []struct {
 		name        string
 		authorID    int64
-		filepath    string
 		description string
+		content     []byte
+		putErr      error
 		mockRepoFn  func(t *testing.T, video *models.Video) error
+		wantStored  bool
 		wantErr     error
 	}{
 		{
 			name:        "Success",
 			authorID:    10,
-			filepath:    "uploads/10_test.mp4",
 			description: "Test video",
 			mockRepoFn: func(t *testing.T, video *models.Video) error {
 				if video.AuthorID != 10 {
 					t.Errorf("Expected AuthorID 10, got %d", video.AuthorID)
 				}
+				if !strings.HasPrefix(video.Filepath, "videos/10_") || !strings.HasSuffix(video.Filepath, ".mp4") {
+					t.Errorf("Unexpected storage key %q", video.Filepath)
+				}
+				if video.DurationMs != 15000 || video.Width != 1280 || video.Height != 720 || video.Codec != "avc1" || video.MimeType != "video/mp4" {
+					t.Errorf("Unexpected metadata: %+v", video)
+				}
 				return nil
 			},
-			wantErr: nil,
+			wantStored: true,
+			wantErr:    nil,
 		},
 		{
 			name:        "Error_MissingAuthorID",
 			authorID:    0,
-			filepath:    "uploads/0_test.mp4",
 			description: "Test video",
 			mockRepoFn: func(t *testing.T, video *models.Video) error {
 				t.Fatalf("Repository should not be called")
@@ -81,14 +230,47 @@ func TestVideoService_UploadVideo(t *testing.T) {
 			wantErr: ErrAuthorIDRequired,
 		},
 		{
-			name:        "Error_DBFailure",
+			name:        "Error_NotAVideo",
+			authorID:    10,
+			description: "Test video",
+			content:     []byte("#!/bin/sh\necho definitely not a video\n"),
+			mockRepoFn: func(t *testing.T, video *models.Video) error {
+				t.Fatalf("Repository should not be called for rejected content")
+				return nil
+			},
+			wantErr: media.ErrUnsupportedFormat,
+		},
+		{
+			name:        "Error_TooLong",
+			authorID:    10,
+			description: "Test video",
+			content:     testVideoFile(45),
+			mockRepoFn: func(t *testing.T, video *models.Video) error {
+				t.Fatalf("Repository should not be called for rejected content")
+				return nil
+			},
+			wantErr: ErrVideoTooLong,
+		},
+		{
+			name:        "Error_StorageFailure",
+			authorID:    10,
+			description: "Test video",
+			putErr:      errStorage,
+			mockRepoFn: func(t *testing.T, video *models.Video) error {
+				t.Fatalf("Repository should not be called when the file was not stored")
+				return nil
+			},
+			wantErr: errStorage,
+		},
+		{
+			name:        "Error_DBFailureRemovesFile",
 			authorID:    10,
-			filepath:    "uploads/10_test.mp4",
 			description: "Test video",
 			mockRepoFn: func(t *testing.T, video *models.Video) error {
 				return errTestDB
 			},
-			wantErr: errTestDB,
+			wantStored: false,
+			wantErr:    errTestDB,
 		},
 	}
 
@@ -99,41 +281,214 @@ func TestVideoService_UploadVideo(t *testing.T) {
 					return tt.mockRepoFn(t, video)
 				},
 			}
-			s := NewVideoService(mockRepo)
+			store := &MockBlobStore{PutErr: tt.putErr}
+			s := NewVideoService(mockRepo, WithBlobStore(store), WithMaxDuration(30*time.Second))
 
-			_, err := s.UploadVideo(ctx, tt.filepath, tt.authorID, tt.description)
+			content := tt.content
+			if content == nil {
+				content = testVideoFile(15)
+			}
+			_, err := s.UploadVideo(ctx, tt.authorID, tt.description, bytes.NewReader(content), int64(len(content)))
 
 			if !errors.Is(err, tt.wantErr) && err != tt.wantErr {
 				t.Errorf("UploadVideo() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if stored := len(store.Objects) > 0; stored != tt.wantStored {
+				t.Errorf("UploadVideo() stored = %v, want %v (objects: %v)", stored, tt.wantStored, store.Objects)
+			}
+		})
+	}
+}
+
+func TestVideoService_UploadVideoRetakes(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	today := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		maxRetakes  int
+		previous    *models.Video
+		replaceErr  error
+		wantRetakes int
+		wantErr     error
+		wantStored  bool
+	}{
+		{name: "FirstMoment", maxRetakes: 2, wantStored: true},
+		{name: "Retake", maxRetakes: 2, previous: &models.Video{VideoID: 7, Filepath: "videos/1_old.mp4", RetakeCount: 1}, wantRetakes: 2, wantStored: true},
+		{name: "LimitReached", maxRetakes: 2, previous: &models.Video{VideoID: 7, Filepath: "videos/1_old.mp4", RetakeCount: 2}, wantErr: ErrDailyMomentLimit},
+		{name: "RetakesDisabled", maxRetakes: 0, previous: &models.Video{VideoID: 7, Filepath: "videos/1_old.mp4"}, wantErr: ErrDailyMomentLimit},
+		{name: "ConcurrentRetake", maxRetakes: 2, previous: &models.Video{VideoID: 7, Filepath: "videos/1_old.mp4"}, replaceErr: repository.ErrMomentTaken, wantErr: ErrMomentConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved *models.Video
+			var replaced *models.Video
+			repo := &MockVideoRepository{
+				CreateVideoFn: func(ctx context.Context, video *models.Video) error {
+					saved = video
+					return nil
+				},
+				ReplaceVideoFn: func(ctx context.Context, old *models.Video, video *models.Video) error {
+					if tt.replaceErr != nil {
+						return tt.replaceErr
+					}
+					replaced, saved = old, video
+					return nil
+				},
+				GetVideoByMomentDateFn: func(ctx context.Context, authorID int64, momentDate time.Time) (*models.Video, error) {
+					if !momentDate.Equal(today) {
+						t.Errorf("momentDate = %v, want %v", momentDate, today)
+					}
+					if tt.previous == nil {
+						return nil, repository.ErrRecordNotFound
+					}
+					return tt.previous, nil
+				},
+			}
+			store := &MockBlobStore{}
+			s := NewVideoService(repo, WithBlobStore(store), WithMaxRetakes(tt.maxRetakes)).(*videoServiceImpl)
+			s.now = func() time.Time { return now }
+
+			data := testVideoFile(5)
+			_, err := s.UploadVideo(context.Background(), 1, "", strings.NewReader(string(data)), int64(len(data)))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UploadVideo() error = %v, want %v", err, tt.wantErr)
+			}
+			stored := 0
+			for key := range store.Objects {
+				if !slices.Contains(store.Deleted, key) {
+					stored++
+				}
+			}
+			if (stored == 1) != tt.wantStored {
+				t.Errorf("stored files = %d, wantStored %v", stored, tt.wantStored)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if saved.MomentDate == nil || !saved.MomentDate.Equal(today) || saved.RetakeCount != tt.wantRetakes {
+				t.Errorf("saved moment %v with %d retakes, want %v with %d", saved.MomentDate, saved.RetakeCount, today, tt.wantRetakes)
+			}
+			if tt.previous != nil {
+				if replaced != tt.previous {
+					t.Errorf("replaced %+v, want previous video", replaced)
+				}
+				if !reflect.DeepEqual(store.Deleted, []string{"videos/1_old.mp4"}) || !reflect.DeepEqual(store.DeletedPrefixes, []string{"hls/7", "previews/7"}) {
+					t.Errorf("deleted %v and %v, want files of the replaced video", store.Deleted, store.DeletedPrefixes)
+				}
+			}
 		})
 	}
 }
 
+func TestVideoService_DeleteDoesNotResetRetakes(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	// Видео дня и счетчик загрузок, который удаление не трогает
+	var current *models.Video
+	uploads := 0
+	nextID := int64(1)
+	save := func(video *models.Video) error {
+		if video.RetakeCount != uploads {
+			return repository.ErrMomentTaken
+		}
+		uploads++
+		video.VideoID = nextID
+		nextID++
+		current = video
+		return nil
+	}
+	repo := &MockVideoRepository{
+		CreateVideoFn: func(ctx context.Context, video *models.Video) error { return save(video) },
+		ReplaceVideoFn: func(ctx context.Context, old *models.Video, video *models.Video) error {
+			return save(video)
+		},
+		GetVideoByMomentDateFn: func(ctx context.Context, authorID int64, momentDate time.Time) (*models.Video, error) {
+			if current == nil {
+				return nil, repository.ErrRecordNotFound
+			}
+			return current, nil
+		},
+		CountMomentUploadsFn: func(ctx context.Context, authorID int64, momentDate time.Time) (int, error) {
+			return uploads, nil
+		},
+		GetVideoByIDFn: func(ctx context.Context, videoID int64) (*models.Video, error) {
+			if current == nil || current.VideoID != videoID {
+				return nil, repository.ErrRecordNotFound
+			}
+			return current, nil
+		},
+		DeleteVideoFn: func(ctx context.Context, videoID int64) (*models.Video, error) {
+			deleted := current
+			current = nil
+			return deleted, nil
+		},
+	}
+	s := NewVideoService(repo, WithBlobStore(&MockBlobStore{}), WithMaxRetakes(1)).(*videoServiceImpl)
+	s.now = func() time.Time { return now }
+	author := Actor{UserID: 1, Role: models.RoleUser}
+	data := testVideoFile(5)
+	upload := func() (*models.Video, error) {
+		return s.UploadVideo(ctx, 1, "", strings.NewReader(string(data)), int64(len(data)))
+	}
+
+	for want := 0; want <= 1; want++ {
+		video, err := upload()
+		if err != nil {
+			t.Fatalf("upload %d: error = %v", want+1, err)
+		}
+		if video.RetakeCount != want {
+			t.Errorf("upload %d: RetakeCount = %d, want %d", want+1, video.RetakeCount, want)
+		}
+		if err := s.DeleteVideo(ctx, author, video.VideoID); err != nil {
+			t.Fatalf("DeleteVideo() error = %v", err)
+		}
+	}
+	if _, err := upload(); !errors.Is(err, ErrDailyMomentLimit) {
+		t.Errorf("upload after deleting the last retake: error = %v, want ErrDailyMomentLimit", err)
+	}
+}
+
 // --- UpdateDescription (Обновление) ---
 
 func TestVideoService_UpdateDescription(t *testing.T) {
 	ctx := context.Background()
 
+	owner := Actor{UserID: 10, Role: models.RoleUser}
+	ownVideo := func() (*models.Video, error) {
+		return &models.Video{VideoID: 101, AuthorID: 10}, nil
+	}
+	foreignVideo := func() (*models.Video, error) {
+		return &models.Video{VideoID: 101, AuthorID: 20}, nil
+	}
+
 	tests := []struct {
 		name          string
+		actor         Actor
 		videoID       int64
 		description   string
 		mockUpdateFn  func() (int64, error)
 		mockGetByIDFn func() (*models.Video, error)
+		mockAuditFn   func(entry *models.AuditLog) error
+		wantAudit     bool
 		wantErr       error
 	}{
 		{
 			name:        "Success_Update",
+			actor:       owner,
 			videoID:     101,
 			description: "New description",
 			mockUpdateFn: func() (int64, error) {
 				return 1, nil
 			},
-			wantErr: nil,
+			mockGetByIDFn: ownVideo,
+			wantErr:       nil,
 		},
 		{
 			name:        "Error_DescriptionTooLong",
+			actor:       owner,
 			videoID:     101,
 			description: "This description is definitely way too long, exceeding the maximum limit of 70 characters.",
 			mockUpdateFn: func() (int64, error) {
@@ -144,6 +499,7 @@ func TestVideoService_UpdateDescription(t *testing.T) {
 		},
 		{
 			name:        "Error_VideoNotFound",
+			actor:       owner,
 			videoID:     999,
 			description: "Short description",
 			mockUpdateFn: func() (int64, error) {
@@ -156,14 +512,72 @@ func TestVideoService_UpdateDescription(t *testing.T) {
 		},
 		{
 			name:        "Error_DBFailure",
+			actor:       owner,
 			videoID:     101,
 			description: "Short description",
 			mockUpdateFn: func() (int64, error) {
 				return 0, errTestDB
 			},
-			mockGetByIDFn: func() (*models.Video, error) { return nil, nil },
+			mockGetByIDFn: ownVideo,
 			wantErr:       errTestDB,
 		},
+		{
+			name:        "Error_ForbiddenNotAuthor",
+			actor:       owner,
+			videoID:     101,
+			description: "Short description",
+			mockUpdateFn: func() (int64, error) {
+				t.Fatalf("Repository should not be called for a foreign video")
+				return 0, nil
+			},
+			mockGetByIDFn: foreignVideo,
+			wantErr:       ErrForbidden,
+		},
+		{
+			name:        "Error_DeletedBeforeUpdate",
+			actor:       owner,
+			videoID:     101,
+			description: "Short description",
+			mockUpdateFn: func() (int64, error) {
+				return 0, nil
+			},
+			mockGetByIDFn: ownVideo,
+			wantErr:       ErrVideoNotFound,
+		},
+		{
+			name:        "Success_ModeratorOverrideAudited",
+			actor:       Actor{UserID: 30, Role: models.RoleModerator},
+			videoID:     101,
+			description: "Moderated",
+			mockUpdateFn: func() (int64, error) {
+				return 1, nil
+			},
+			mockGetByIDFn: foreignVideo,
+			mockAuditFn: func(entry *models.AuditLog) error {
+				if entry.ActorID != 30 || entry.TargetID != 101 || entry.Action != "video.update_description" {
+					t.Errorf("Unexpected audit entry: %+v", entry)
+				}
+				return nil
+			},
+			wantAudit: true,
+			wantErr:   nil,
+		},
+		{
+			name:        "Error_ModeratorAuditFailure",
+			actor:       Actor{UserID: 30, Role: models.RoleModerator},
+			videoID:     101,
+			description: "Moderated",
+			mockUpdateFn: func() (int64, error) {
+				t.Fatalf("Update must not happen when audit fails")
+				return 0, nil
+			},
+			mockGetByIDFn: foreignVideo,
+			mockAuditFn: func(entry *models.AuditLog) error {
+				return errTestDB
+			},
+			wantAudit: true,
+			wantErr:   errTestDB,
+		},
 	}
 
 	for _, tt := range tests {
@@ -179,20 +593,24 @@ func TestVideoService_UpdateDescription(t *testing.T) {
 					return nil, nil
 				},
 			}
-			s := NewVideoService(mockRepo)
+			mockAudit := &MockAuditRepository{CreateFn: tt.mockAuditFn}
+			s := NewVideoService(mockRepo, WithAuditRepository(mockAudit))
 
-			err := s.UpdateDescription(ctx, tt.videoID, tt.description)
+			err := s.UpdateDescription(ctx, tt.actor, tt.videoID, tt.description)
 
 			if !errors.Is(err, tt.wantErr) && (err == nil || tt.wantErr == nil || err.Error() != tt.wantErr.Error()) {
 				t.Errorf("UpdateDescription() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if mockAudit.Calls > 0 != tt.wantAudit {
+				t.Errorf("UpdateDescription() audit calls = %d, wantAudit %v", mockAudit.Calls, tt.wantAudit)
+			}
 		})
 	}
 }
 
-// --- GetTodayFeed (Получение ленты) ---
+// --- GetFeed (Получение ленты) ---
 
-func TestVideoService_GetTodayFeed(t *testing.T) {
+func TestVideoService_GetFeed(t *testing.T) {
 	ctx := context.Background()
 
 	sampleVideos := []models.Video{
@@ -239,7 +657,7 @@ func TestVideoService_GetTodayFeed(t *testing.T) {
 				return []int64{}, nil
 			},
 			mockGetVideosFn: func() ([]models.Video, error) {
-				t.Fatalf("GetTodayVideosByAuthors should not be called")
+				t.Fatalf("GetVideosByAuthors should not be called")
 				return nil, nil
 			},
 			wantLen: 0,
@@ -265,19 +683,243 @@ func TestVideoService_GetTodayFeed(t *testing.T) {
 				GetFriendsIDsFn: func(ctx context.Context, userID int64) ([]int64, error) {
 					return tt.mockFriendsIDsFn()
 				},
-				GetTodayVideosByAuthorsFn: func(ctx context.Context, authorIDs []int64) ([]models.Video, error) {
+				GetVideosByAuthorsFn: func(ctx context.Context, authorIDs []int64, from, to time.Time) ([]models.Video, error) {
 					return tt.mockGetVideosFn()
 				},
 			}
 			s := NewVideoService(mockRepo)
 
-			videos, err := s.GetTodayFeed(ctx, tt.userID)
+			videos, err := s.GetFeed(ctx, tt.userID, "")
 
 			if !errors.Is(err, tt.wantErr) && err != tt.wantErr {
-				t.Errorf("GetTodayFeed() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("GetFeed() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if len(videos) != tt.wantLen {
-				t.Errorf("GetTodayFeed() got %d videos, want %d", len(videos), tt.wantLen)
+				t.Errorf("GetFeed() got %d videos, want %d", len(videos), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestVideoService_GetFeedDayWindow(t *testing.T) {
+	ctx := context.Background()
+	// 22:30 UTC 30 марта: в Минске (UTC+3) уже 31-е, в Лос-Анджелесе (UTC-7) еще 30-е
+	now := time.Date(2025, 3, 30, 22, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		timezone string
+		date     string
+		wantFrom time.Time
+		wantTo   time.Time
+		wantErr  error
+	}{
+		{
+			name:     "Minsk_Today",
+			timezone: "Europe/Minsk",
+			wantFrom: time.Date(2025, 3, 30, 21, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2025, 3, 31, 21, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "LosAngeles_Today",
+			timezone: "America/Los_Angeles",
+			wantFrom: time.Date(2025, 3, 30, 7, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2025, 3, 31, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			// переход на летнее время 30 марта: день длится 23 часа
+			name:     "Berlin_PastDayWithDSTSwitch",
+			timezone: "Europe/Berlin",
+			date:     "2025-03-30",
+			wantFrom: time.Date(2025, 3, 29, 23, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2025, 3, 30, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "InvalidStoredTimezoneFallsBackToUTC",
+			timezone: "Mars/Olympus",
+			wantFrom: time.Date(2025, 3, 30, 0, 0, 0, 0, time.UTC),
+			wantTo:   time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{name: "Error_FutureDate", timezone: "America/Los_Angeles", date: "2025-03-31", wantErr: ErrInvalidFeedDate},
+		{name: "Error_BadFormat", timezone: "UTC", date: "30.03.2025", wantErr: ErrInvalidFeedDate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotFrom, gotTo time.Time
+			mockRepo := &MockVideoRepository{
+				GetUserTimezoneFn: func(ctx context.Context, userID int64) (string, error) {
+					return tt.timezone, nil
+				},
+				GetFriendsIDsFn: func(ctx context.Context, userID int64) ([]int64, error) {
+					return []int64{15}, nil
+				},
+				GetVideosByAuthorsFn: func(ctx context.Context, authorIDs []int64, from, to time.Time) ([]models.Video, error) {
+					gotFrom, gotTo = from, to
+					return nil, nil
+				},
+			}
+			s := NewVideoService(mockRepo).(*videoServiceImpl)
+			s.now = func() time.Time { return now }
+
+			_, err := s.GetFeed(ctx, 10, tt.date)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetFeed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !gotFrom.Equal(tt.wantFrom) || !gotTo.Equal(tt.wantTo) {
+				t.Errorf("GetFeed() window = [%s, %s), want [%s, %s)", gotFrom.UTC(), gotTo.UTC(), tt.wantFrom, tt.wantTo)
+			}
+		})
+	}
+}
+
+// --- GetFeedPage (Лента по курсору) ---
+
+func TestVideoService_GetFeedPage(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 3, 30, 12, 0, 0, 0, time.UTC)
+	// 5 видео друзей, от новых к старым
+	all := make([]models.FeedItem, 5)
+	for i := range all {
+		all[i] = models.FeedItem{VideoID: int64(100 - i), CreatedAt: base.Add(-time.Duration(i) * time.Minute)}
+	}
+	mockRepo := &MockVideoRepository{
+		ListFeedFn: func(ctx context.Context, viewerID int64, from, to time.Time, after *models.FeedCursor, limit int) ([]models.FeedItem, error) {
+			var page []models.FeedItem
+			for _, item := range all {
+				if after != nil && !item.CreatedAt.Before(after.CreatedAt) {
+					continue
+				}
+				if len(page) < limit {
+					page = append(page, item)
+				}
+			}
+			return page, nil
+		},
+	}
+	s := NewVideoService(mockRepo).(*videoServiceImpl)
+	s.now = func() time.Time { return base }
+
+	var got []int64
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatalf("pagination does not terminate, got %v", got)
+		}
+		page, err := s.GetFeedPage(ctx, 10, "", cursor, 2)
+		if err != nil {
+			t.Fatalf("GetFeedPage() error = %v", err)
+		}
+		for _, item := range page.Items {
+			got = append(got, item.VideoID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if !reflect.DeepEqual(got, []int64{100, 99, 98, 97, 96}) {
+		t.Errorf("GetFeedPage() pages = %v, want all 5 videos in order", got)
+	}
+
+	for _, bad := range []string{"not base64!", base64URL("123"), base64URL("abc.1"), base64URL("123.-1")} {
+		if _, err := s.GetFeedPage(ctx, 10, "", bad, 2); !errors.Is(err, ErrInvalidFeedCursor) {
+			t.Errorf("GetFeedPage(cursor=%q) error = %v, want ErrInvalidFeedCursor", bad, err)
+		}
+	}
+}
+
+func TestFeedCursor_RoundTrip(t *testing.T) {
+	want := models.FeedCursor{CreatedAt: time.Date(2025, 3, 30, 12, 0, 0, 123456000, time.UTC), VideoID: 42}
+	got, err := decodeFeedCursor(encodeFeedCursor(want))
+	if err != nil || !got.CreatedAt.Equal(want.CreatedAt) || got.VideoID != want.VideoID {
+		t.Errorf("decodeFeedCursor(encodeFeedCursor(%+v)) = %+v, %v", want, got, err)
+	}
+}
+
+func base64URL(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// --- Post to see (Заглушки до публикации) ---
+
+func TestVideoService_FeedPostToSee(t *testing.T) {
+	ctx := context.Background()
+	errDB := errors.New("db unavailable")
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	posterKey := "previews/5/poster.jpg"
+	video := models.Video{VideoID: 5, AuthorID: 2, Filepath: "videos/2_1.mp4", Description: "Hi", PosterKey: &posterKey, BlurHash: "LKO2?U%2Tw=w", CreatedAt: now.Add(-time.Hour)}
+	item := models.FeedItem{VideoID: 5, Description: "Hi", Filepath: "videos/2_1.mp4", PosterKey: &posterKey, BlurHash: "LKO2?U%2Tw=w", CreatedAt: now.Add(-time.Hour),
+		Author: models.FeedAuthor{UserID: 2, Name: "Ann"}, CommentCount: 3}
+
+	tests := []struct {
+		name       string
+		postToSee  bool
+		date       string
+		posted     bool
+		lookupErr  error
+		wantLocked bool
+		wantErr    error
+	}{
+		{name: "Disabled_NotPosted", postToSee: false, wantLocked: false},
+		{name: "Enabled_NotPosted", postToSee: true, wantLocked: true},
+		{name: "Enabled_Posted", postToSee: true, posted: true, wantLocked: false},
+		{name: "Enabled_TodayByDate", postToSee: true, date: "2026-03-10", wantLocked: true},
+		{name: "Enabled_PastDay", postToSee: true, date: "2026-03-09", wantLocked: false},
+		{name: "Enabled_LookupError", postToSee: true, lookupErr: errDB, wantErr: errDB},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockVideoRepository{
+				GetFriendsIDsFn: func(ctx context.Context, userID int64) ([]int64, error) {
+					return []int64{2}, nil
+				},
+				GetVideosByAuthorsFn: func(ctx context.Context, authorIDs []int64, from, to time.Time) ([]models.Video, error) {
+					return []models.Video{video}, nil
+				},
+				ListFeedFn: func(ctx context.Context, viewerID int64, from, to time.Time, after *models.FeedCursor, limit int) ([]models.FeedItem, error) {
+					return []models.FeedItem{item}, nil
+				},
+				GetVideoByMomentDateFn: func(ctx context.Context, authorID int64, momentDate time.Time) (*models.Video, error) {
+					if authorID != 10 || momentDate.Format("2006-01-02") != "2026-03-10" {
+						t.Errorf("looked up moment of %d on %s, want viewer's today", authorID, momentDate.Format("2006-01-02"))
+					}
+					if tt.lookupErr != nil {
+						return nil, tt.lookupErr
+					}
+					if !tt.posted {
+						return nil, repository.ErrRecordNotFound
+					}
+					return &models.Video{VideoID: 9, AuthorID: 10}, nil
+				},
+			}
+			s := NewVideoService(mockRepo, WithPostToSee(tt.postToSee)).(*videoServiceImpl)
+			s.now = func() time.Time { return now }
+
+			videos, err := s.GetFeed(ctx, 10, tt.date)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetFeed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			page, pageErr := s.GetFeedPage(ctx, 10, tt.date, "", 10)
+			if !errors.Is(pageErr, tt.wantErr) {
+				t.Fatalf("GetFeedPage() error = %v, wantErr %v", pageErr, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			wantVideo, wantItem := video, item
+			if tt.wantLocked {
+				wantVideo = models.Video{AuthorID: 2, CreatedAt: video.CreatedAt, BlurHash: video.BlurHash, Locked: true}
+				wantItem = models.FeedItem{Author: item.Author, CreatedAt: item.CreatedAt, BlurHash: item.BlurHash, Locked: true}
+			}
+			if !reflect.DeepEqual(videos, []models.Video{wantVideo}) {
+				t.Errorf("GetFeed() = %+v, want %+v", videos, wantVideo)
+			}
+			if page.Locked != tt.wantLocked || !reflect.DeepEqual(page.Items, []models.FeedItem{wantItem}) {
+				t.Errorf("GetFeedPage() = locked %v %+v, want locked %v %+v", page.Locked, page.Items, tt.wantLocked, wantItem)
 			}
 		})
 	}
@@ -288,52 +930,237 @@ func TestVideoService_GetTodayFeed(t *testing.T) {
 func TestVideoService_DeleteVideo(t *testing.T) {
 	ctx := context.Background()
 
+	owner := Actor{UserID: 10, Role: models.RoleUser}
+	ownVideo := func() (*models.Video, error) {
+		return &models.Video{VideoID: 101, AuthorID: 10, Filepath: "videos/101_exists.mp4"}, nil
+	}
+	foreignVideo := func() (*models.Video, error) {
+		return &models.Video{VideoID: 101, AuthorID: 20, Filepath: "videos/101_exists.mp4"}, nil
+	}
+
 	tests := []struct {
-		name         string
-		videoID      int64
-		mockDeleteFn func() (*models.Video, error)
-		wantErr      error
+		name          string
+		actor         Actor
+		videoID       int64
+		mockGetByIDFn func() (*models.Video, error)
+		mockDeleteFn  func() (*models.Video, error)
+		wantAudit     bool
+		wantErr       error
 	}{
 		{
-			name:    "Success_Deletion",
-			videoID: 101,
-			mockDeleteFn: func() (*models.Video, error) {
-				return &models.Video{VideoID: 101, Filepath: "uploads/101_exists.mp4"}, nil
-			},
-			wantErr: nil,
+			name:          "Success_Deletion",
+			actor:         owner,
+			videoID:       101,
+			mockGetByIDFn: ownVideo,
+			mockDeleteFn:  ownVideo,
+			wantErr:       nil,
 		},
 		{
 			name:    "Error_VideoNotFound",
+			actor:   owner,
 			videoID: 999,
-			mockDeleteFn: func() (*models.Video, error) {
+			mockGetByIDFn: func() (*models.Video, error) {
 				return nil, repository.ErrRecordNotFound
 			},
+			mockDeleteFn: func() (*models.Video, error) {
+				t.Fatalf("DeleteVideo should not be called for a missing video")
+				return nil, nil
+			},
 			wantErr: ErrVideoNotFound,
 		},
 		{
-			name:    "Error_DBFailure",
-			videoID: 101,
+			name:          "Error_DBFailure",
+			actor:         owner,
+			videoID:       101,
+			mockGetByIDFn: ownVideo,
 			mockDeleteFn: func() (*models.Video, error) {
 				return nil, errors.New("DB delete failed")
 			},
 			wantErr: errors.New("DB delete failed"),
 		},
+		{
+			name:          "Error_ForbiddenNotAuthor",
+			actor:         owner,
+			videoID:       101,
+			mockGetByIDFn: foreignVideo,
+			mockDeleteFn: func() (*models.Video, error) {
+				t.Fatalf("DeleteVideo should not be called for a foreign video")
+				return nil, nil
+			},
+			wantErr: ErrForbidden,
+		},
+		{
+			name:          "Success_ModeratorOverrideAudited",
+			actor:         Actor{UserID: 30, Role: models.RoleModerator},
+			videoID:       101,
+			mockGetByIDFn: foreignVideo,
+			mockDeleteFn:  foreignVideo,
+			wantAudit:     true,
+			wantErr:       nil,
+		},
+		{
+			name:          "Success_AdminOverrideAudited",
+			actor:         Actor{UserID: 1, Role: models.RoleAdmin},
+			videoID:       101,
+			mockGetByIDFn: foreignVideo,
+			mockDeleteFn:  foreignVideo,
+			wantAudit:     true,
+			wantErr:       nil,
+		},
+		{
+			name:          "Success_ModeratorOwnVideoNotAudited",
+			actor:         Actor{UserID: 10, Role: models.RoleModerator},
+			videoID:       101,
+			mockGetByIDFn: ownVideo,
+			mockDeleteFn:  ownVideo,
+			wantAudit:     false,
+			wantErr:       nil,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := &MockVideoRepository{
+				GetVideoByIDFn: func(ctx context.Context, videoID int64) (*models.Video, error) {
+					return tt.mockGetByIDFn()
+				},
 				DeleteVideoFn: func(ctx context.Context, videoID int64) (*models.Video, error) {
 					return tt.mockDeleteFn()
 				},
 			}
-			s := NewVideoService(mockRepo)
+			mockAudit := &MockAuditRepository{}
+			store := &MockBlobStore{}
+			s := NewVideoService(mockRepo, WithAuditRepository(mockAudit), WithBlobStore(store))
 
-			err := s.DeleteVideo(ctx, tt.videoID)
+			err := s.DeleteVideo(ctx, tt.actor, tt.videoID)
 
 			if !errors.Is(err, tt.wantErr) && (err == nil || tt.wantErr == nil || err.Error() != tt.wantErr.Error()) {
 				t.Errorf("DeleteVideo() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if mockAudit.Calls > 0 != tt.wantAudit {
+				t.Errorf("DeleteVideo() audit calls = %d, wantAudit %v", mockAudit.Calls, tt.wantAudit)
+			}
+			if removed := len(store.Deleted) > 0; removed != (tt.wantErr == nil) {
+				t.Errorf("DeleteVideo() removed files = %v, want removal %v", store.Deleted, tt.wantErr == nil)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(store.DeletedPrefixes, []string{"hls/101", "previews/101"}) {
+				t.Errorf("DeleteVideo() removed derived files = %v, want hls/101 and previews/101", store.DeletedPrefixes)
+			}
+		})
+	}
+}
+
+// --- OpenStream (Просмотр) ---
+
+func TestVideoService_OpenStream(t *testing.T) {
+	ctx := context.Background()
+	video := &models.Video{VideoID: 101, AuthorID: 10, Filepath: "videos/10_1.mp4", MimeType: "video/mp4", Status: models.VideoReady}
+
+	tests := []struct {
+		name     string
+		viewerID int64
+		friends  bool
+		stored   bool
+		wantErr  error
+	}{
+		{name: "Success_Author", viewerID: 10, stored: true},
+		{name: "Success_Friend", viewerID: 20, friends: true, stored: true},
+		{name: "Error_NotFriend", viewerID: 30, stored: true, wantErr: ErrForbidden},
+		{name: "Error_FileMissing", viewerID: 10, stored: false, wantErr: ErrVideoNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &MockVideoRepository{
+				GetVideoByIDFn: func(ctx context.Context, videoID int64) (*models.Video, error) {
+					return video, nil
+				},
+				AreFriendsFn: func(ctx context.Context, userID, otherID int64) (bool, error) {
+					if otherID != 10 {
+						t.Errorf("AreFriends called with author %d, want 10", otherID)
+					}
+					return tt.friends, nil
+				},
+			}
+			store := &MockBlobStore{}
+			if tt.stored {
+				store.Objects = map[string][]byte{video.Filepath: []byte("0123456789")}
+			}
+			s := NewVideoService(mockRepo, WithBlobStore(store))
+
+			stream, err := s.OpenStream(ctx, tt.viewerID, 101)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OpenStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer stream.Content.Close()
+			if _, err := stream.Content.Seek(4, io.SeekStart); err != nil {
+				t.Fatalf("Seek: %v", err)
+			}
+			rest, _ := io.ReadAll(stream.Content)
+			if string(rest) != "456789" || stream.ETag == "" {
+				t.Errorf("OpenStream() content after seek = %q, etag %q", rest, stream.ETag)
+			}
+		})
+	}
+}
+
+func TestVideoService_OpenStreamPostToSee(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	today := now.Add(-time.Hour)
+	yesterday := now.Add(-24 * time.Hour)
+
+	tests := []struct {
+		name      string
+		viewerID  int64
+		createdAt time.Time
+		posted    bool
+		postToSee bool
+		wantErr   error
+	}{
+		{name: "LockedToday", viewerID: 20, createdAt: today, postToSee: true, wantErr: ErrForbidden},
+		{name: "UnlockedAfterPosting", viewerID: 20, createdAt: today, posted: true, postToSee: true},
+		{name: "PastDayOpen", viewerID: 20, createdAt: yesterday, postToSee: true},
+		{name: "AuthorAlwaysSees", viewerID: 10, createdAt: today, postToSee: true},
+		{name: "RuleDisabled", viewerID: 20, createdAt: today},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video := &models.Video{VideoID: 101, AuthorID: 10, Filepath: "videos/10_1.mp4", Status: models.VideoReady, CreatedAt: tt.createdAt}
+			mockRepo := &MockVideoRepository{
+				GetVideoByIDFn: func(ctx context.Context, videoID int64) (*models.Video, error) {
+					return video, nil
+				},
+				AreFriendsFn: func(ctx context.Context, userID, otherID int64) (bool, error) {
+					return true, nil
+				},
+				GetVideoByMomentDateFn: func(ctx context.Context, authorID int64, momentDate time.Time) (*models.Video, error) {
+					if authorID == 20 && tt.posted {
+						return &models.Video{VideoID: 202, AuthorID: 20}, nil
+					}
+					return nil, repository.ErrRecordNotFound
+				},
+			}
+			store := &MockBlobStore{Objects: map[string][]byte{video.Filepath: []byte("0123456789")}}
+			s := NewVideoService(mockRepo, WithBlobStore(store), WithPostToSee(tt.postToSee)).(*videoServiceImpl)
+			s.now = func() time.Time { return now }
+
+			stream, err := s.OpenStream(ctx, tt.viewerID, 101)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OpenStream() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				stream.Content.Close()
+			}
+			// Комментарии и реакции проверяют то же правило
+			if err := s.CheckUnlocked(ctx, tt.viewerID, 101); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckUnlocked() error = %v, wantErr %v", err, tt.wantErr)
+			}
 		})
 	}
 }
@@ -28,12 +28,16 @@ CREATE TABLE users (
     user_id BIGSERIAL PRIMARY KEY,
     nickname VARCHAR(50) NOT NULL UNIQUE,
     email VARCHAR(255) NOT NULL UNIQUE,
+    role VARCHAR(16) NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'moderator', 'admin')),
     rating INTEGER NOT NULL DEFAULT 0 CHECK (rating >= 0),
     max_streak INTEGER NOT NULL DEFAULT 0 CHECK (max_streak >= 0),
     current_streak INTEGER NOT NULL DEFAULT 0 CHECK (current_streak >= 0),
+    streak_freezes INTEGER NOT NULL DEFAULT 0 CHECK (streak_freezes >= 0),
+    last_streak_date DATE,
     max_reactions INTEGER NOT NULL DEFAULT 0 CHECK (max_reactions >= 0),
     avatar_filepath TEXT,
     bio VARCHAR(100) NOT NULL DEFAULT '',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
     created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
@@ -44,10 +48,27 @@ CREATE TABLE videos (
     filepath TEXT NOT NULL,
     author_id BIGINT REFERENCES users(user_id) ON DELETE CASCADE,
     description VARCHAR(70) NOT NULL DEFAULT '',
+    mime_type VARCHAR(32) NOT NULL DEFAULT 'video/mp4',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    width INT NOT NULL DEFAULT 0,
+    height INT NOT NULL DEFAULT 0,
+    rotation SMALLINT NOT NULL DEFAULT 0 CHECK (rotation IN (0, 90, 180, 270)),
+    codec VARCHAR(8) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'ready' CHECK (status IN ('processing', 'ready', 'failed')),
+    playlist_key TEXT,
+    poster_key TEXT,
+    preview_key TEXT,
+    blurhash VARCHAR(64) NOT NULL DEFAULT '',
+    moment_date DATE,
+    posted_late BOOLEAN NOT NULL DEFAULT false,
+    retake_count INT NOT NULL DEFAULT 0 CHECK (retake_count >= 0),
     created_at TIMESTAMPTZ NOT NULL DEFAULT now()
 );
 
-CREATE INDEX IF NOT EXISTS idx_videos_author ON videos(author_id);
+-- Лента: видео друзей за день, keyset по created_at
+CREATE INDEX IF NOT EXISTS idx_videos_author_created ON videos(author_id, created_at);
+-- Один момент на пользователя в локальный день (старые видео без moment_date не ограничены)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_videos_author_moment ON videos(author_id, moment_date);
 
 CREATE TABLE friendships (
     user_id1 BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
@@ -59,6 +80,7 @@ CREATE TABLE friendships (
 );
 
 CREATE INDEX IF NOT EXISTS idx_friendships_user1 ON friendships(user_id1);
+CREATE INDEX IF NOT EXISTS idx_friendships_user2 ON friendships(user_id2);
 
 CREATE TABLE reactions (
     user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
@@ -71,13 +93,214 @@ CREATE TABLE reactions (
 CREATE INDEX IF NOT EXISTS idx_reactions_video ON reactions(video_id);
 CREATE INDEX IF NOT EXISTS idx_reactions_user ON reactions(user_id);
 
+CREATE TABLE auth_sessions (
+    session_id VARCHAR(32) PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_user ON auth_sessions(user_id);
+
+CREATE TABLE refresh_tokens (
+    refresh_token_id BIGSERIAL PRIMARY KEY,
+    session_id VARCHAR(32) NOT NULL REFERENCES auth_sessions(session_id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session ON refresh_tokens(session_id);
+
+CREATE TABLE password_resets (
+    email VARCHAR(255) PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE email_verifications (
+    email VARCHAR(255) PRIMARY KEY,
+    code VARCHAR(64) NOT NULL, -- HMAC-SHA256 от кода
+    expires_at TIMESTAMPTZ NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE audit_log (
+    audit_id BIGSERIAL PRIMARY KEY,
+    actor_id BIGINT NOT NULL,
+    actor_role VARCHAR(16) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL,
+    target_id BIGINT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id);
+
+CREATE TABLE friend_suggestion_dismissals (
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    dismissed_user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, dismissed_user_id)
+);
+
+CREATE TABLE invites (
+    invite_id BIGSERIAL PRIMARY KEY,
+    inviter_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    max_uses INT NOT NULL CHECK (max_uses > 0),
+    uses INT NOT NULL DEFAULT 0 CHECK (uses >= 0),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_invites_inviter ON invites(inviter_id);
+
+-- Атрибуция регистраций: один приглашенный - одна запись
+CREATE TABLE invite_redemptions (
+    invitee_id BIGINT PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+    invite_id BIGINT NOT NULL REFERENCES invites(invite_id) ON DELETE CASCADE,
+    inviter_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    befriended_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_invite_redemptions_inviter ON invite_redemptions(inviter_id);
+
+-- Незавершенные resumable-загрузки (tus); строки старше expires_at удаляет фоновая очистка
+CREATE TABLE uploads (
+    upload_id VARCHAR(32) PRIMARY KEY,
+    author_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    upload_length BIGINT NOT NULL CHECK (upload_length > 0),
+    upload_offset BIGINT NOT NULL DEFAULT 0 CHECK (upload_offset >= 0 AND upload_offset <= upload_length),
+    description VARCHAR(70) NOT NULL DEFAULT '',
+    video_id BIGINT, -- опубликованное видео; без FK, чтобы удаление видео не "воскрешало" загрузку
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_author ON uploads(author_id);
+CREATE INDEX IF NOT EXISTS idx_uploads_expires ON uploads(expires_at);
+
+-- Очередь перекодирования в HLS; воркеры забирают задачи через FOR UPDATE SKIP LOCKED
+CREATE TABLE transcode_jobs (
+    job_id BIGSERIAL PRIMARY KEY,
+    video_id BIGINT NOT NULL UNIQUE REFERENCES videos(video_id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    attempts INT NOT NULL DEFAULT 0 CHECK (attempts >= 0),
+    max_attempts INT NOT NULL DEFAULT 5 CHECK (max_attempts > 0),
+    run_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    locked_at TIMESTAMPTZ,
+    locked_by VARCHAR(64) NOT NULL DEFAULT '',
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_transcode_jobs_ready ON transcode_jobs(run_at) WHERE status IN ('pending', 'running');
+
+-- Момент дня: одно случайное окно на локальный день каждого часового пояса
+CREATE TABLE daily_moments (
+    timezone VARCHAR(64) NOT NULL,
+    local_date DATE NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    deadline TIMESTAMPTZ NOT NULL CHECK (deadline > starts_at),
+    notified_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (timezone, local_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_daily_moments_pending ON daily_moments(starts_at) WHERE notified_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_timezone ON users(timezone);
+
+-- Загрузки момента за локальный день. Счетчик переживает удаление видео,
+-- поэтому удаление и повторная загрузка расходуют лимит пересъемок.
+CREATE TABLE moment_uploads (
+    author_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    moment_date DATE NOT NULL,
+    uploads INTEGER NOT NULL CHECK (uploads > 0),
+    PRIMARY KEY (author_id, moment_date)
+);
+
+-- История серий: засчитанные локальные дни (публикация или заморозка)
+CREATE TABLE streak_days (
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    local_date DATE NOT NULL,
+    kind VARCHAR(8) NOT NULL CHECK (kind IN ('posted', 'frozen')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, local_date)
+);
+
+-- Рейтинги: материализованы, чтобы запросы не сканировали users.
+-- Обновляются LeaderboardService.RunRefresher (REFRESH ... CONCURRENTLY
+-- требует уникальный индекс).
+CREATE MATERIALIZED VIEW leaderboard_all_time AS
+SELECT user_id, rating::BIGINT AS score, RANK() OVER (ORDER BY rating DESC) AS rank
+FROM users;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_leaderboard_all_time_user ON leaderboard_all_time(user_id);
+CREATE INDEX IF NOT EXISTS idx_leaderboard_all_time_keyset ON leaderboard_all_time(score DESC, user_id);
+
+-- Журнал рейтинга: только INSERT (UPDATE и DELETE отклоняет trg_rating_events_append_only),
+-- users.rating пересчитывается из него
+CREATE TABLE rating_events (
+    event_id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('post', 'streak', 'reaction')),
+    points INTEGER NOT NULL,
+    video_id BIGINT,
+    actor_id BIGINT,
+    moment_date DATE, -- локальный день публикации для kind = 'post'
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rating_events_user ON rating_events(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_rating_events_reaction ON rating_events(video_id, actor_id) WHERE kind = 'reaction';
+-- Очки за публикацию - один раз за день, сколько бы видео ни удалялось и ни загружалось заново
+CREATE UNIQUE INDEX IF NOT EXISTS idx_rating_events_post_day ON rating_events(user_id, moment_date) WHERE kind = 'post';
+CREATE INDEX IF NOT EXISTS idx_rating_events_created ON rating_events(created_at);
+
+-- Недельный рейтинг: очки журнала рейтинга, начисленные за текущую ISO-неделю
+-- (UTC), без затухания. Снятие реакции на этой неделе уменьшает счет; в
+-- таблицу попадают только пользователи с положительной суммой.
+CREATE MATERIALIZED VIEW leaderboard_weekly AS
+SELECT user_id, SUM(points)::BIGINT AS score, RANK() OVER (ORDER BY SUM(points) DESC) AS rank
+FROM rating_events
+WHERE created_at >= date_trunc('week', now() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+GROUP BY user_id
+HAVING SUM(points) > 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_leaderboard_weekly_user ON leaderboard_weekly(user_id);
+CREATE INDEX IF NOT EXISTS idx_leaderboard_weekly_keyset ON leaderboard_weekly(score DESC, user_id);
+
 -- ---------------------------------------------------------
 --  FUNCTIONS
 -- ---------------------------------------------------------
 
 --TODO: write functions for working with friendships table
 
+-- Журнал рейтинга только дополняется. Исключение - каскадное удаление
+-- вместе с пользователем: оно идет из триггера внешнего ключа (глубина > 1).
+CREATE OR REPLACE FUNCTION rating_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF pg_trigger_depth() > 1 THEN
+        RETURN OLD;
+    END IF;
+    RAISE EXCEPTION 'rating_events is append-only: % is not allowed', TG_OP;
+END;
+$$ LANGUAGE plpgsql;
+
 -- ---------------------------------------------------------
 --  TRIGGERS
 -- ---------------------------------------------------------
 
+CREATE TRIGGER trg_rating_events_append_only
+    BEFORE UPDATE OR DELETE ON rating_events
+    FOR EACH ROW EXECUTE FUNCTION rating_events_append_only();
+
@@ -0,0 +1,22 @@
+-- ---------------------------------------------------------
+--  Разовая миграция: ключи BlobStore для старых файлов
+-- ---------------------------------------------------------
+--
+-- До BlobStore пути хранились вместе с каталогом: videos.filepath =
+-- 'uploads/<файл>', users.avatar_filepath = 'uploads/avatars/<id>.jpg'.
+-- Теперь это ключи относительно корня хранилища (STORAGE_LOCAL_ROOT,
+-- по умолчанию ./uploads), поэтому префикс 'uploads/' убирается.
+-- Скрипт можно запускать повторно: уже переведенные строки не затрагиваются.
+--
+-- Для STORAGE_DRIVER=s3 содержимое каталога uploads нужно скопировать в
+-- бакет с теми же ключами, например: mc cp --recursive uploads/ minio/momentic/
+
+BEGIN;
+
+UPDATE videos SET filepath = substr(filepath, 9)
+WHERE filepath LIKE 'uploads/%';
+
+UPDATE users SET avatar_filepath = substr(avatar_filepath, 9)
+WHERE avatar_filepath LIKE 'uploads/%';
+
+COMMIT;
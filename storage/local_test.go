@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore_RoundTrip(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "avatars/7.jpg", strings.NewReader("jpeg"), 4, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := store.Open(ctx, "avatars/7.jpg")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(got) != "jpeg" {
+		t.Errorf("Open returned %q, want %q", got, "jpeg")
+	}
+
+	if err := store.Delete(ctx, "avatars/7.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Open(ctx, "avatars/7.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after delete: got %v, want ErrNotFound", err)
+	}
+	// Удаление отсутствующего объекта - не ошибка
+	if err := store.Delete(ctx, "avatars/7.jpg"); err != nil {
+		t.Errorf("Delete missing: %v", err)
+	}
+}
+
+func TestLocalStore_RejectsPathTraversal(t *testing.T) {
+	store, _ := NewLocalStore(t.TempDir())
+	if err := store.Put(context.Background(), "../escape", strings.NewReader("x"), 1, ""); err == nil {
+		t.Error("Put(../escape): expected error")
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Seeker читает объект как io.ReadSeeker: при каждом Seek открывается
+// новый диапазон, поэтому http.ServeContent отдает Range-запросы,
+// не загружая объект целиком.
+type Seeker struct {
+	ctx   context.Context
+	store BlobStore
+	key   string
+	size  int64
+
+	pos  int64
+	body io.ReadCloser
+}
+
+func NewSeeker(ctx context.Context, store BlobStore, key string, size int64) *Seeker {
+	return &Seeker{ctx: ctx, store: store, key: key, size: size}
+}
+
+func (s *Seeker) Read(p []byte) (int, error) {
+	if s.pos >= s.size {
+		return 0, io.EOF
+	}
+	if s.body == nil {
+		body, err := s.store.OpenRange(s.ctx, s.key, s.pos, s.size-s.pos)
+		if err != nil {
+			return 0, err
+		}
+		s.body = body
+	}
+	n, err := s.body.Read(p)
+	s.pos += int64(n)
+	if err == io.EOF && s.pos < s.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (s *Seeker) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = s.pos + offset
+	case io.SeekEnd:
+		pos = s.size + offset
+	default:
+		return 0, errors.New("storage: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("storage: negative position")
+	}
+	if pos != s.pos {
+		s.closeBody()
+		s.pos = pos
+	}
+	return pos, nil
+}
+
+func (s *Seeker) Close() error {
+	s.closeBody()
+	return nil
+}
+
+func (s *Seeker) closeBody() {
+	if s.body != nil {
+		s.body.Close()
+		s.body = nil
+	}
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner_SignAndVerify(t *testing.T) {
+	store, _ := NewLocalStore(t.TempDir())
+	signer := NewURLSigner([]byte("secret"), time.Minute, store)
+	now := time.Unix(1700000000, 0)
+	signer.now = func() time.Time { return now }
+
+	link, err := signer.URL(context.Background(), "videos/10_1.mp4", 20)
+	if err != nil {
+		t.Fatalf("URL: %v", err)
+	}
+	u, _ := url.Parse(link)
+	if !strings.HasPrefix(u.Path, MediaPathPrefix) {
+		t.Fatalf("unexpected path %q", u.Path)
+	}
+	key := strings.TrimPrefix(u.Path, MediaPathPrefix)
+
+	if viewer, _, err := signer.Verify(key, u.Query()); err != nil || viewer != 20 {
+		t.Errorf("Verify = %d, %v; want viewer 20", viewer, err)
+	}
+
+	// Ссылку нельзя переделать на другой объект или другого зрителя
+	if _, _, err := signer.Verify("videos/11_1.mp4", u.Query()); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify other key: got %v, want ErrInvalidSignature", err)
+	}
+	tampered := u.Query()
+	tampered.Set("uid", "30")
+	if _, _, err := signer.Verify(key, tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify other viewer: got %v, want ErrInvalidSignature", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, _, err := signer.Verify(key, u.Query()); !errors.Is(err, ErrURLExpired) {
+		t.Errorf("Verify expired: got %v, want ErrURLExpired", err)
+	}
+}
+
+func TestURLSigner_PresignsWithS3(t *testing.T) {
+	store, _ := newTestS3Store(t)
+	signer := NewURLSigner([]byte("secret"), time.Minute, store)
+
+	link, err := signer.URL(context.Background(), "avatars/7.jpg", 20)
+	if err != nil {
+		t.Fatalf("URL: %v", err)
+	}
+	u, _ := url.Parse(link)
+	if u.Path != "/momentic/avatars/7.jpg" || u.Query().Get("X-Amz-Signature") == "" || u.Query().Get("X-Amz-Expires") != "60" {
+		t.Errorf("expected presigned S3 URL, got %s", link)
+	}
+}
+
+func TestURLSigner_SignPlaylist(t *testing.T) {
+	store, _ := newTestS3Store(t)
+	signer := NewURLSigner([]byte("secret"), time.Minute, store)
+	ctx := context.Background()
+
+	// Плейлист всегда отдается через /media/, даже при S3
+	link, err := signer.URL(ctx, "hls/7/master.m3u8", 20)
+	if err != nil || !strings.HasPrefix(link, MediaPathPrefix+"hls/7/master.m3u8?") {
+		t.Fatalf("URL(playlist) = %q, %v", link, err)
+	}
+
+	master := "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nv0/index.m3u8\n"
+	signed, err := signer.SignPlaylist(ctx, "hls/7/master.m3u8", 20, []byte(master))
+	if err != nil {
+		t.Fatalf("SignPlaylist: %v", err)
+	}
+	lines := strings.Split(string(signed), "\n")
+	if lines[1] != "#EXT-X-STREAM-INF:BANDWIDTH=800000" || !strings.HasPrefix(lines[2], MediaPathPrefix+"hls/7/v0/index.m3u8?") {
+		t.Errorf("master playlist not rewritten: %q", signed)
+	}
+
+	media := "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4.0,\nseg_000.ts\n#EXT-X-ENDLIST\n"
+	signed, err = signer.SignPlaylist(ctx, "hls/7/v0/index.m3u8", 20, []byte(media))
+	if err != nil {
+		t.Fatalf("SignPlaylist: %v", err)
+	}
+	lines = strings.Split(string(signed), "\n")
+	if !strings.Contains(lines[1], "/momentic/hls/7/v0/init.mp4?") || !strings.HasSuffix(lines[1], "\"") {
+		t.Errorf("URI attribute not presigned: %q", lines[1])
+	}
+	if u, _ := url.Parse(lines[3]); u.Path != "/momentic/hls/7/v0/seg_000.ts" || u.Query().Get("X-Amz-Signature") == "" {
+		t.Errorf("segment not presigned: %q", lines[3])
+	}
+	if lines[4] != "#EXT-X-ENDLIST" {
+		t.Errorf("tags must be kept: %q", lines[4])
+	}
+}
+
+func TestMediaURLKey_DiffersFromServerSecret(t *testing.T) {
+	store, _ := NewLocalStore(t.TempDir())
+	derived := NewURLSigner(MediaURLKey([]byte("secret")), time.Minute, store)
+	raw := NewURLSigner([]byte("secret"), time.Minute, store)
+
+	link, err := derived.URL(context.Background(), "videos/10_1.mp4", 20)
+	if err != nil {
+		t.Fatalf("URL: %v", err)
+	}
+	u, _ := url.Parse(link)
+	key := strings.TrimPrefix(u.Path, MediaPathPrefix)
+	if _, _, err := raw.Verify(key, u.Query()); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with the raw secret: got %v, want ErrInvalidSignature", err)
+	}
+	if _, _, err := derived.Verify(key, u.Query()); err != nil {
+		t.Errorf("Verify with the derived key: %v", err)
+	}
+}
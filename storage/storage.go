@@ -0,0 +1,97 @@
+// Package storage хранит загруженные пользователями файлы (видео, аватары).
+// Драйвер выбирается переменной окружения STORAGE_DRIVER: local или s3.
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// BlobStore - хранилище объектов по ключу вида "videos/1_1700000000.mp4".
+// Ключи всегда относительные и разделены "/".
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// OpenRange читает length байт начиная с offset
+	OpenRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// DeletePrefix удаляет все объекты, ключ которых начинается с prefix/
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+type ObjectInfo struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// NewFromEnv создает хранилище по переменным окружения:
+//
+//	STORAGE_DRIVER      local (по умолчанию) или s3
+//	STORAGE_LOCAL_ROOT  каталог для local, по умолчанию ./uploads
+//	STORAGE_ENDPOINT    host:port S3-совместимого сервера (MinIO)
+//	STORAGE_PUBLIC_ENDPOINT host:port для presigned-ссылок, если клиенты видят хранилище по другому адресу
+//	STORAGE_ACCESS_KEY, STORAGE_SECRET_KEY
+//	STORAGE_BUCKET      по умолчанию momentic
+//	STORAGE_REGION      по умолчанию us-east-1
+//	STORAGE_USE_SSL     true, чтобы ходить по https
+func NewFromEnv(ctx context.Context) (BlobStore, error) {
+	switch driver := strings.ToLower(os.Getenv("STORAGE_DRIVER")); driver {
+	case "", "local":
+		root := os.Getenv("STORAGE_LOCAL_ROOT")
+		if root == "" {
+			root = "uploads"
+		}
+		return NewLocalStore(root)
+	case "s3":
+		cfg := S3Config{
+			Endpoint:       os.Getenv("STORAGE_ENDPOINT"),
+			PublicEndpoint: os.Getenv("STORAGE_PUBLIC_ENDPOINT"),
+			AccessKey:      os.Getenv("STORAGE_ACCESS_KEY"),
+			SecretKey:      os.Getenv("STORAGE_SECRET_KEY"),
+			Bucket:         os.Getenv("STORAGE_BUCKET"),
+			Region:         os.Getenv("STORAGE_REGION"),
+			UseSSL:         strings.EqualFold(os.Getenv("STORAGE_USE_SSL"), "true"),
+		}
+		if cfg.Bucket == "" {
+			cfg.Bucket = "momentic"
+		}
+		store, err := NewS3Store(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := store.EnsureBucket(ctx); err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}
+
+// ETag - слабо меняющийся идентификатор версии объекта для HTTP-кеширования
+func ETag(key string, info ObjectInfo) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d", key, info.Size, info.ModTime.UnixNano())))
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid object key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid object key %q", key)
+		}
+	}
+	return nil
+}
@@ -1,25 +1,45 @@
 package util
 
 import (
+	"crypto/rand"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
 	"math"
-	"math/rand"
+	"math/big"
 	"os"
 	"strings"
 	"time"
 
 	"net"
 	"net/smtp"
-
-	"github.com/merinovvvv/momentic-backend/initializers"
-	"github.com/merinovvvv/momentic-backend/models"
-	// "gorm.io/gorm"
 )
 
+// SendVerificationEmail сохраняет хеш кода (заменяя предыдущий) и отправляет код письмом
 func SendVerificationEmail(receiver, code string) error {
+	if err := SaveVerificationCode(receiver, code); err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("Your Momentic code is %s", code)
+	body := "If you haven't registred a Momentic account, ignore this email"
+	return sendMail(receiver, subject, body)
+}
+
+// SendPasswordResetEmail отправляет одноразовый код сброса пароля
+func SendPasswordResetEmail(receiver, code string) error {
+	subject := "Momentic password reset"
+	body := fmt.Sprintf("Your Momentic password reset code is %s\n"+
+		"It expires in %d minutes. If you didn't request a password reset, ignore this email",
+		code, int(PasswordResetTTL.Minutes()))
+	return sendMail(receiver, subject, body)
+}
+
+const smtpTimeout = 30 * time.Second
+
+// sendMail отправляет письмо через SMTP-аккаунт из EMAIL/EMAIL_PASSWORD
+func sendMail(receiver, subject, body string) error {
 	sender := strings.TrimSpace(os.Getenv("EMAIL"))
 	senderPassword := os.Getenv("EMAIL_PASSWORD")
 	if sender == "" || senderPassword == "" {
@@ -40,9 +60,6 @@ func SendVerificationEmail(receiver, code string) error {
 	}
 	addr := net.JoinHostPort(smtpHost, smtpPort)
 
-	subject := fmt.Sprintf("Your Momentic code is %s", code)
-	body := "If you haven't registred a Momentic account, ignore this email"
-
 	msg := "From: " + sender + "\n" +
 		"To: " + receiver + "\n" +
 		"Subject: " + subject + "\n\n" +
@@ -54,6 +71,10 @@ func SendVerificationEmail(receiver, code string) error {
 		return fmt.Errorf("smtp dial %s: %w", addr, err)
 	}
 	defer conn.Close()
+	// Общий срок на весь SMTP-диалог, чтобы зависший сервер не держал воркер
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return fmt.Errorf("smtp deadline: %w", err)
+	}
 
 	client, err := smtp.NewClient(conn, smtpHost)
 	if err != nil {
@@ -97,38 +118,21 @@ func SendVerificationEmail(receiver, code string) error {
 	if err := client.Quit(); err != nil {
 		return fmt.Errorf("smtp quit: %w", err)
 	}
-    
-	// TODO: hash code
-	emailVerification := models.EmailVerification {
-		Email: receiver,
-		Code: code,
-		ExpiresAt: time.Now().Add(time.Minute * 15),
-	}
-	if err := initializers.DB.Create(&emailVerification).Error; err != nil {
-		return fmt.Errorf("failed to create email verification: %w", err)
-	}
-	// if err := initializers.DB.First(&emailVerification, "email = ?", receiver).Error; err != nil {
-	// 	if errors.Is(err, gorm.ErrRecordNotFound) {
-	// 		if err := initializers.DB.Create(&emailVerification).Error; err != nil {
-	// 			return fmt.Errorf("failed to create email verification: %w", err)
-	// 		}
-	// 	} else {
-	// 		return fmt.Errorf("failed to write email verification to db: %w", err)
-	// 	}
-	// }
+
 	log.Printf("Email sent from %s to %s", sender, receiver)
 	return nil
 }
 
-// Generates a random number of given length
-func GenerateVerificationCode(length int) (error, string) {
+// GenerateVerificationCode генерирует числовой код заданной длины с помощью crypto/rand
+func GenerateVerificationCode(length int) (string, error) {
 	if length < 1 || length > 9 {
-		return errors.New("length must be between 1 and 9"), ""
-	}
-    var max int
-    for i := range length {
-        max += 9*int((math.Pow(10, float64(i))))
-    }
-	
-	return nil, fmt.Sprintf("%0*d",length, rand.Intn(max))
+		return "", errors.New("length must be between 1 and 9")
+	}
+	max := big.NewInt(int64(math.Pow10(length)))
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", fmt.Errorf("generate verification code: %w", err)
+	}
+
+	return fmt.Sprintf("%0*d", length, n.Int64()), nil
 }
@@ -0,0 +1,197 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/initializers"
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultInviteMaxUses = 5
+	MaxInviteMaxUses     = 50
+	DefaultInviteTTL     = time.Hour * 24 * 7
+	MaxInviteTTL         = time.Hour * 24 * 30
+
+	inviteSignatureSize = 12
+)
+
+var (
+	ErrInvalidInvite  = errors.New("invalid or expired invite code")
+	ErrInviteNotFound = errors.New("invite not found")
+)
+
+// SignInviteCode строит код приглашения: base64url(invite_id | expires_at | hmac).
+// Код детерминирован, поэтому в БД его хранить не нужно.
+func SignInviteCode(inviteID int64, expiresAt time.Time) string {
+	payload := make([]byte, 16, 16+inviteSignatureSize)
+	binary.BigEndian.PutUint64(payload[:8], uint64(inviteID))
+	binary.BigEndian.PutUint64(payload[8:], uint64(expiresAt.Unix()))
+	return base64.RawURLEncoding.EncodeToString(append(payload, inviteSignature(payload)...))
+}
+
+// CreateInvite выпускает приглашение пользователя inviterID.
+func CreateInvite(inviterID int64, maxUses int, ttl time.Duration) (models.Invite, string, error) {
+	invite := models.Invite{
+		InviterID: inviterID,
+		MaxUses:   maxUses,
+		// Срок хранится с точностью до секунды, как и в подписанном коде
+		ExpiresAt: time.Now().Add(ttl).Truncate(time.Second),
+	}
+	if err := initializers.DB.Create(&invite).Error; err != nil {
+		return models.Invite{}, "", fmt.Errorf("create invite: %w", err)
+	}
+	return invite, SignInviteCode(invite.InviteID, invite.ExpiresAt), nil
+}
+
+// ListInvites возвращает приглашения пользователя, новые первыми.
+func ListInvites(inviterID int64) ([]models.Invite, error) {
+	var invites []models.Invite
+	err := initializers.DB.
+		Where("inviter_id = ?", inviterID).
+		Order("created_at DESC").
+		Find(&invites).Error
+	return invites, err
+}
+
+// RevokeInvite отзывает приглашение; уже зарегистрированных пользователей это не затрагивает.
+func RevokeInvite(inviterID, inviteID int64) error {
+	res := initializers.DB.Model(&models.Invite{}).
+		Where("invite_id = ? AND inviter_id = ? AND revoked_at IS NULL", inviteID, inviterID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+// RedeemInvite списывает одно использование приглашения и записывает,
+// кто пригласил inviteeID. Вызывается в транзакции регистрации, чтобы
+// при ошибке создания пользователя использование не пропадало.
+func RedeemInvite(tx *gorm.DB, code string, inviteeID int64) error {
+	inviteID, expiresAt, err := verifyInviteCode(code, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Проверка лимита и списание одним UPDATE: параллельные регистрации
+	// по одному коду не превысят max_uses.
+	var invite models.Invite
+	res := tx.Model(&invite).
+		Clauses(clause.Returning{}).
+		Where("invite_id = ? AND expires_at = ? AND revoked_at IS NULL AND expires_at > now() AND uses < max_uses",
+			inviteID, expiresAt).
+		Update("uses", gorm.Expr("uses + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidInvite
+	}
+
+	redemption := models.InviteRedemption{
+		InviteeID: inviteeID,
+		InviteID:  invite.InviteID,
+		InviterID: invite.InviterID,
+	}
+	if err := tx.Create(&redemption).Error; err != nil {
+		return fmt.Errorf("store invite redemption: %w", err)
+	}
+	return nil
+}
+
+// BefriendInviter делает пригласившего и приглашенного друзьями после
+// подтверждения email. Встречная заявка в любую сторону принимается,
+// блокировка сохраняется. Повторный вызов ничего не делает.
+func BefriendInviter(inviteeID int64) error {
+	return initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var redemption models.InviteRedemption
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("invitee_id = ? AND befriended_at IS NULL", inviteeID).
+			First(&redemption).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		userID1, userID2 := models.CanonicalPair(redemption.InviterID, inviteeID)
+		err = tx.Exec(`
+			INSERT INTO friendships (user_id1, user_id2, status)
+			VALUES (?, ?, ?)
+			ON CONFLICT (user_id1, user_id2) DO UPDATE
+			SET status = EXCLUDED.status, created_at = now()
+			WHERE friendships.status IN (?, ?)`,
+			userID1, userID2, models.StatusFriends, models.StatusPending1, models.StatusPending2,
+		).Error
+		if err != nil {
+			return fmt.Errorf("befriend inviter: %w", err)
+		}
+
+		return tx.Model(&redemption).Update("befriended_at", time.Now()).Error
+	})
+}
+
+// InviteStats - пользователи, приведшие больше всего регистраций.
+func InviteStats(limit int) ([]models.InviteStat, error) {
+	var stats []models.InviteStat
+	err := initializers.DB.Raw(`
+		SELECT r.inviter_id, u.name, u.surname,
+			COUNT(*) AS signups,
+			COUNT(*) FILTER (WHERE invitee.verified) AS verified_signups
+		FROM invite_redemptions r
+		JOIN users u ON u.user_id = r.inviter_id
+		JOIN users invitee ON invitee.user_id = r.invitee_id
+		GROUP BY r.inviter_id, u.name, u.surname
+		ORDER BY signups DESC, r.inviter_id
+		LIMIT ?`, limit).Scan(&stats).Error
+	return stats, err
+}
+
+// verifyInviteCode проверяет подпись и срок кода на момент now.
+// Отзыв и лимит использований проверяются в БД при списании.
+func verifyInviteCode(code string, now time.Time) (int64, time.Time, error) {
+	inviteID, expiresAt, err := parseInviteCode(code)
+	if err != nil || now.After(expiresAt) {
+		return 0, time.Time{}, ErrInvalidInvite
+	}
+	return inviteID, expiresAt, nil
+}
+
+func parseInviteCode(code string) (int64, time.Time, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(code)
+	if err != nil || len(raw) != 16+inviteSignatureSize {
+		return 0, time.Time{}, ErrInvalidInvite
+	}
+	payload, signature := raw[:16], raw[16:]
+	if !hmac.Equal(signature, inviteSignature(payload)) {
+		return 0, time.Time{}, ErrInvalidInvite
+	}
+	inviteID := int64(binary.BigEndian.Uint64(payload[:8]))
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(payload[8:])), 0)
+	return inviteID, expiresAt, nil
+}
+
+func inviteSignature(payload []byte) []byte {
+	secret := os.Getenv("INVITE_SECRET")
+	if secret == "" {
+		secret = os.Getenv("SECRET")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("invite:"))
+	mac.Write(payload)
+	return mac.Sum(nil)[:inviteSignatureSize]
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSignInviteCode_RoundTrip(t *testing.T) {
+	t.Setenv("INVITE_SECRET", "invite-secret")
+	expiresAt := time.Now().Add(DefaultInviteTTL).Truncate(time.Second)
+
+	code := SignInviteCode(42, expiresAt)
+	inviteID, gotExpiry, err := verifyInviteCode(code, time.Now())
+	if err != nil {
+		t.Fatalf("verifyInviteCode() error = %v", err)
+	}
+	if inviteID != 42 || !gotExpiry.Equal(expiresAt) {
+		t.Errorf("verifyInviteCode() = %d, %v; want 42, %v", inviteID, gotExpiry, expiresAt)
+	}
+	if SignInviteCode(42, expiresAt) != code {
+		t.Error("invite code is not deterministic")
+	}
+}
+
+func TestVerifyInviteCode_Rejects(t *testing.T) {
+	t.Setenv("INVITE_SECRET", "invite-secret")
+	now := time.Now()
+	expiresAt := now.Add(time.Hour).Truncate(time.Second)
+	code := SignInviteCode(42, expiresAt)
+	raw, _ := base64.RawURLEncoding.DecodeString(code)
+
+	// Другой invite_id с исходной подписью
+	forgedID := append([]byte(nil), raw...)
+	forgedID[7] ^= 1
+	// Продленный срок с исходной подписью
+	forgedExpiry := append([]byte(nil), raw...)
+	forgedExpiry[15] ^= 0x80
+	// Испорченная подпись
+	badSignature := append([]byte(nil), raw...)
+	badSignature[len(badSignature)-1] ^= 1
+
+	tests := []struct {
+		name string
+		code string
+		now  time.Time
+	}{
+		{"Expired", code, expiresAt.Add(time.Second)},
+		{"ForgedID", base64.RawURLEncoding.EncodeToString(forgedID), now},
+		{"ForgedExpiry", base64.RawURLEncoding.EncodeToString(forgedExpiry), now},
+		{"BadSignature", base64.RawURLEncoding.EncodeToString(badSignature), now},
+		{"TruncatedPayload", base64.RawURLEncoding.EncodeToString(raw[:20]), now},
+		{"NotBase64", "!!" + code, now},
+		{"Empty", "", now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := verifyInviteCode(tt.code, tt.now); !errors.Is(err, ErrInvalidInvite) {
+				t.Errorf("verifyInviteCode() error = %v, want ErrInvalidInvite", err)
+			}
+		})
+	}
+
+	// Код, подписанный другим секретом, не принимается
+	t.Setenv("INVITE_SECRET", "rotated")
+	if _, _, err := verifyInviteCode(code, now); !errors.Is(err, ErrInvalidInvite) {
+		t.Errorf("verifyInviteCode() with another secret error = %v, want ErrInvalidInvite", err)
+	}
+}
@@ -0,0 +1,138 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/initializers"
+	"github.com/merinovvvv/momentic-backend/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const PasswordResetTTL = time.Minute * 30
+
+const (
+	// Письма сброса отправляют столько воркеров, а ждать в очереди может
+	// не больше passwordResetQueueSize запросов; остальные отбрасываются
+	passwordResetWorkers   = 4
+	passwordResetQueueSize = 64
+)
+
+var (
+	passwordResetQueue = make(chan string, passwordResetQueueSize)
+	passwordResetOnce  sync.Once
+)
+
+var ErrInvalidResetCode = errors.New("invalid or expired reset code")
+
+// CreatePasswordReset выпускает новый код сброса для email, заменяя предыдущий.
+func CreatePasswordReset(email string) (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate reset code: %w", err)
+	}
+	code := base32.StdEncoding.EncodeToString(buf)
+
+	reset := models.PasswordReset{
+		Email:     email,
+		TokenHash: hashToken(code),
+		ExpiresAt: time.Now().Add(PasswordResetTTL),
+		CreatedAt: time.Now(),
+	}
+	err := initializers.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "email"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token_hash", "expires_at", "created_at"}),
+	}).Create(&reset).Error
+	if err != nil {
+		return "", fmt.Errorf("store password reset: %w", err)
+	}
+	return code, nil
+}
+
+// QueuePasswordReset ставит отправку кода сброса на email в очередь.
+// Поиск пользователя и отправка письма идут в фоне, чтобы время ответа не
+// зависело от того, есть ли такой аккаунт. false - очередь переполнена.
+func QueuePasswordReset(email string) bool {
+	passwordResetOnce.Do(func() {
+		for i := 0; i < passwordResetWorkers; i++ {
+			go func() {
+				for email := range passwordResetQueue {
+					sendPasswordReset(email)
+				}
+			}()
+		}
+	})
+	select {
+	case passwordResetQueue <- email:
+		return true
+	default:
+		return false
+	}
+}
+
+func sendPasswordReset(email string) {
+	var user models.User
+	if err := initializers.DB.First(&user, "email = ?", email).Error; err != nil {
+		return
+	}
+	code, err := CreatePasswordReset(user.Email)
+	if err != nil {
+		log.Printf("ERROR: Failed to create password reset for user %d: %v", user.ID, err)
+		return
+	}
+	if err := SendPasswordResetEmail(user.Email, code); err != nil {
+		log.Printf("ERROR: Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+}
+
+// ResetPassword погашает код сброса, меняет пароль и закрывает все сессии.
+// Любая ошибка проверки кода возвращается как ErrInvalidResetCode.
+func ResetPassword(email, code, newPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), 10)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	return initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordReset
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&reset, "email = ?", email).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetCode
+			}
+			return err
+		}
+		if reset.ExpiresAt.Before(time.Now()) ||
+			subtle.ConstantTimeCompare([]byte(reset.TokenHash), []byte(hashToken(code))) != 1 {
+			return ErrInvalidResetCode
+		}
+		// Код одноразовый
+		if err := tx.Delete(&reset).Error; err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, "email = ?", email).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetCode
+			}
+			return err
+		}
+		if err := tx.Model(&user).Update("password", string(hash)).Error; err != nil {
+			return err
+		}
+		// Сессии закрываются в той же транзакции, что и смена пароля
+		return revokeSessions(tx.Where("user_id = ?", user.ID))
+	})
+}
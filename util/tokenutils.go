@@ -0,0 +1,302 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/merinovvvv/momentic-backend/initializers"
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	AccessTokenTTL  = time.Hour * 24
+	RefreshTokenTTL = time.Hour * 24 * 30
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+// AccessClaims - claims access-токена. SessionID позволяет middleware
+// отклонять access-токены отозванных сессий до истечения их срока,
+// Role используется middleware.RequireRole без обращения к БД.
+type AccessClaims struct {
+	SessionID string      `json:"sid,omitempty"`
+	Role      models.Role `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type TokenPair struct {
+	Access  string `json:"access"`
+	Refresh string `json:"refresh"`
+}
+
+// IssueTokens открывает новую сессию (family) и выдает для нее пару токенов.
+func IssueTokens(user models.User, userAgent, ip string) (TokenPair, error) {
+	sessionID, err := randomHex(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	session := models.Session{
+		ID:        sessionID,
+		UserID:    user.ID,
+		UserAgent: truncate(userAgent, 255),
+		IP:        truncate(ip, 64),
+	}
+
+	var pair TokenPair
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return fmt.Errorf("create session: %w", err)
+		}
+		pair, err = issuePair(tx, user, sessionID)
+		return err
+	})
+	return pair, err
+}
+
+// RotateRefreshToken меняет refresh-токен на новую пару в той же сессии.
+// Если токен уже был использован, сессия целиком отзывается.
+func RotateRefreshToken(refresh string) (TokenPair, error) {
+	claims, err := parseRefreshToken(refresh)
+	if err != nil {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	var pair TokenPair
+	reusedSessionID := ""
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var stored models.RefreshToken
+		if err := tx.First(&stored, "token_hash = ?", hashToken(refresh)).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		var session models.Session
+		if err := tx.First(&session, "session_id = ?", stored.SessionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+		now := time.Now()
+		if err := checkRefreshToken(stored, session, claims.Subject, now); err != nil {
+			if errors.Is(err, ErrRefreshTokenReused) {
+				reusedSessionID = session.ID
+			}
+			return err
+		}
+
+		// Помечаем токен использованным атомарно: из двух параллельных
+		// запросов с одним токеном пройдет только один.
+		res := tx.Model(&models.RefreshToken{}).
+			Where("refresh_token_id = ? AND used_at IS NULL", stored.ID).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			reusedSessionID = session.ID
+			return ErrRefreshTokenReused
+		}
+
+		var user models.User
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if err := tx.Model(&session).Update("last_used_at", now).Error; err != nil {
+			return err
+		}
+		pair, err = issuePair(tx, user, session.ID)
+		return err
+	})
+
+	if reusedSessionID != "" {
+		// Отзыв делается вне откатившейся транзакции
+		if err := revokeSessions(initializers.DB.Where("session_id = ?", reusedSessionID)); err != nil {
+			log.Printf("ERROR: Failed to revoke session after refresh token reuse: %v", err)
+		}
+		log.Printf("WARNING: Refresh token reuse detected for user %s, session revoked", claims.Subject)
+	}
+	return pair, err
+}
+
+// RevokeSessionByRefreshToken закрывает сессию, которой принадлежит токен (logout).
+func RevokeSessionByRefreshToken(refresh string) error {
+	if _, err := parseRefreshToken(refresh); err != nil {
+		return ErrInvalidRefreshToken
+	}
+	var stored models.RefreshToken
+	if err := initializers.DB.First(&stored, "token_hash = ?", hashToken(refresh)).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+	return revokeSessions(initializers.DB.Where("session_id = ?", stored.SessionID))
+}
+
+// RevokeSession закрывает одну сессию пользователя.
+func RevokeSession(userID uint64, sessionID string) error {
+	res := initializers.DB.Model(&models.Session{}).
+		Where("session_id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeAllSessions закрывает все сессии пользователя (logout-all, смена пароля).
+func RevokeAllSessions(userID uint64) error {
+	return revokeSessions(initializers.DB.Where("user_id = ?", userID))
+}
+
+// ListSessions возвращает активные сессии пользователя, новые первыми.
+func ListSessions(userID uint64) ([]models.Session, error) {
+	var sessions []models.Session
+	err := initializers.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND last_used_at > ?", userID, time.Now().Add(-RefreshTokenTTL)).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// IsSessionActive сообщает, не была ли сессия отозвана.
+func IsSessionActive(sessionID string) (bool, error) {
+	var count int64
+	err := initializers.DB.Model(&models.Session{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ParseAccessToken проверяет подпись и срок access-токена.
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		return []byte(os.Getenv("SECRET")), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid access token")
+	}
+	return claims, nil
+}
+
+func issuePair(tx *gorm.DB, user models.User, sessionID string) (TokenPair, error) {
+	now := time.Now()
+	role := user.Role
+	if !role.IsValid() {
+		role = models.RoleUser
+	}
+	accessClaims := AccessClaims{
+		SessionID: sessionID,
+		Role:      role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(user.ID, 10),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	accessString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims).
+		SignedString([]byte(os.Getenv("SECRET")))
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("sign access token: %w", err)
+	}
+
+	jti, err := randomHex(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refreshClaims := jwt.RegisteredClaims{
+		ID:        jti,
+		Subject:   strconv.FormatUint(user.ID, 10),
+		ExpiresAt: jwt.NewNumericDate(now.Add(RefreshTokenTTL)),
+		IssuedAt:  jwt.NewNumericDate(now),
+	}
+	refreshString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).
+		SignedString([]byte(os.Getenv("REFRESH_SECRET")))
+	if err != nil {
+		return TokenPair{}, fmt.Errorf("sign refresh token: %w", err)
+	}
+
+	stored := models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: hashToken(refreshString),
+		ExpiresAt: refreshClaims.ExpiresAt.Time,
+	}
+	if err := tx.Create(&stored).Error; err != nil {
+		return TokenPair{}, fmt.Errorf("store refresh token: %w", err)
+	}
+
+	return TokenPair{Access: accessString, Refresh: refreshString}, nil
+}
+
+func parseRefreshToken(refresh string) (*jwt.RegisteredClaims, error) {
+	claims := &jwt.RegisteredClaims{}
+	token, err := jwt.ParseWithClaims(refresh, claims, func(token *jwt.Token) (any, error) {
+		return []byte(os.Getenv("REFRESH_SECRET")), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidRefreshToken
+	}
+	return claims, nil
+}
+
+// checkRefreshToken решает, можно ли обменять сохраненный refresh-токен
+// сессии session, выданный subject. ErrRefreshTokenReused означает, что
+// токен уже обменян, и сессию нужно отозвать целиком.
+func checkRefreshToken(stored models.RefreshToken, session models.Session, subject string, now time.Time) error {
+	if session.RevokedAt != nil || fmt.Sprint(session.UserID) != subject {
+		return ErrInvalidRefreshToken
+	}
+	if stored.UsedAt != nil {
+		return ErrRefreshTokenReused
+	}
+	if stored.ExpiresAt.Before(now) {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+func revokeSessions(scope *gorm.DB) error {
+	return scope.Model(&models.Session{}).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate random id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
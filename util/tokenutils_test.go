@@ -0,0 +1,95 @@
+package util
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/merinovvvv/momentic-backend/models"
+)
+
+func signRefresh(t *testing.T, method jwt.SigningMethod, key any, expiresAt time.Time) string {
+	t.Helper()
+	claims := jwt.RegisteredClaims{
+		ID:        "jti",
+		Subject:   "7",
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func TestParseRefreshToken(t *testing.T) {
+	t.Setenv("SECRET", "access-secret")
+	t.Setenv("REFRESH_SECRET", "refresh-secret")
+	valid := signRefresh(t, jwt.SigningMethodHS256, []byte("refresh-secret"), time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"Valid", valid, false},
+		{"TamperedSignature", valid[:len(valid)-2] + "xx", true},
+		{"Truncated", valid[:len(valid)/2], true},
+		// Access-ключ не подходит для refresh-токенов
+		{"AccessSecret", signRefresh(t, jwt.SigningMethodHS256, []byte("access-secret"), time.Now().Add(time.Hour)), true},
+		{"Expired", signRefresh(t, jwt.SigningMethodHS256, []byte("refresh-secret"), time.Now().Add(-time.Minute)), true},
+		{"AlgNone", signRefresh(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, time.Now().Add(time.Hour)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := parseRefreshToken(tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRefreshToken) {
+					t.Errorf("parseRefreshToken() error = %v, want ErrInvalidRefreshToken", err)
+				}
+				return
+			}
+			if err != nil || claims.Subject != "7" {
+				t.Errorf("parseRefreshToken() = %v, %v; want subject 7", claims, err)
+			}
+		})
+	}
+
+	// Refresh-токен не принимается как access-токен
+	if _, err := ParseAccessToken(valid); err == nil {
+		t.Error("ParseAccessToken accepted a refresh token")
+	}
+}
+
+func TestCheckRefreshToken(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Minute)
+	session := models.Session{ID: "s1", UserID: 7}
+	revoked := models.Session{ID: "s1", UserID: 7, RevokedAt: &earlier}
+	fresh := models.RefreshToken{SessionID: "s1", ExpiresAt: now.Add(time.Hour)}
+	used := models.RefreshToken{SessionID: "s1", ExpiresAt: now.Add(time.Hour), UsedAt: &earlier}
+
+	tests := []struct {
+		name    string
+		stored  models.RefreshToken
+		session models.Session
+		subject string
+		want    error
+	}{
+		{"Fresh", fresh, session, "7", nil},
+		// Повторный обмен - признак кражи: вызывающий отзывает всю сессию
+		{"Reused", used, session, "7", ErrRefreshTokenReused},
+		{"ReusedAfterRevoke", used, revoked, "7", ErrInvalidRefreshToken},
+		{"RevokedSession", fresh, revoked, "7", ErrInvalidRefreshToken},
+		{"OtherSubject", fresh, session, "8", ErrInvalidRefreshToken},
+		{"Expired", models.RefreshToken{SessionID: "s1", ExpiresAt: earlier}, session, "7", ErrInvalidRefreshToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkRefreshToken(tt.stored, tt.session, tt.subject, now); !errors.Is(err, tt.want) {
+				t.Errorf("checkRefreshToken() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/initializers"
+	"github.com/merinovvvv/momentic-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	VerificationCodeLength = 5
+	VerificationCodeTTL    = time.Minute * 15
+	// VerificationLockout - блокировка email после исчерпания попыток
+	VerificationLockout = time.Hour
+
+	defaultVerificationMaxAttempts = 5
+)
+
+var (
+	ErrVerificationNotFound    = errors.New("no pending verification for this email")
+	ErrVerificationExpired     = errors.New("verification code expired")
+	ErrInvalidVerificationCode = errors.New("invalid verification code")
+	ErrTooManyAttempts         = errors.New("too many verification attempts")
+)
+
+// SaveVerificationCode сохраняет HMAC кода для email, заменяя предыдущий код.
+// Счетчик неверных попыток переживает повторную отправку, иначе лимит
+// обходился бы запросом нового кода; пока email заблокирован после
+// VerificationMaxAttempts() попыток, возвращается ErrTooManyAttempts.
+func SaveVerificationCode(email, code string) error {
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var existing *models.EmailVerification
+		var stored models.EmailVerification
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&stored, "email = ?", email).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		case err != nil:
+			return err
+		default:
+			existing = &stored
+		}
+		attempts, err := carriedAttempts(existing, now)
+		if err != nil {
+			return err
+		}
+
+		emailVerification := models.EmailVerification{
+			Email:     email,
+			Code:      hashVerificationCode(email, code),
+			ExpiresAt: now.Add(VerificationCodeTTL),
+			Attempts:  attempts,
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "email"}},
+			DoUpdates: clause.AssignmentColumns([]string{"code", "expires_at", "attempts"}),
+		}).Create(&emailVerification).Error
+	})
+	if errors.Is(err, ErrTooManyAttempts) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save email verification: %w", err)
+	}
+	return nil
+}
+
+// CheckVerificationCode сверяет код за константное время. Неверный ввод
+// увеличивает счетчик попыток; после VerificationMaxAttempts() email
+// блокируется на VerificationLockout. Успешно проверенный код удаляется.
+func CheckVerificationCode(email, code string) error {
+	var result error
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var emailVerification models.EmailVerification
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&emailVerification, "email = ?", email).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				result = ErrVerificationNotFound
+				return nil
+			}
+			return err
+		}
+
+		if emailVerification.ExpiresAt.Before(time.Now()) {
+			result = ErrVerificationExpired
+			return tx.Delete(&emailVerification).Error
+		}
+
+		if emailVerification.Attempts >= VerificationMaxAttempts() {
+			result = ErrTooManyAttempts
+			return nil
+		}
+
+		expected := []byte(emailVerification.Code)
+		actual := []byte(hashVerificationCode(email, strings.TrimSpace(code)))
+		if hmac.Equal(expected, actual) {
+			return tx.Delete(&emailVerification).Error
+		}
+
+		updates, failure := failedAttempt(emailVerification, time.Now())
+		result = failure
+		return tx.Model(&emailVerification).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// carriedAttempts - счетчик неверных попыток для нового кода. Незаконченная
+// запись existing передает свой счетчик, а исчерпанный лимит запрещает
+// отправку до конца блокировки; истекшая запись или ее отсутствие - 0.
+func carriedAttempts(existing *models.EmailVerification, now time.Time) (int, error) {
+	if existing == nil || !existing.ExpiresAt.After(now) {
+		return 0, nil
+	}
+	if existing.Attempts >= VerificationMaxAttempts() {
+		return 0, ErrTooManyAttempts
+	}
+	return existing.Attempts, nil
+}
+
+// failedAttempt - изменения записи после неверного ввода и ошибка для
+// пользователя. На последней попытке email блокируется на VerificationLockout:
+// запись остается до конца блокировки, чтобы повторная отправка не обнулила
+// счетчик.
+func failedAttempt(v models.EmailVerification, now time.Time) (map[string]interface{}, error) {
+	attempts := v.Attempts + 1
+	updates := map[string]interface{}{"attempts": attempts}
+	if attempts >= VerificationMaxAttempts() {
+		updates["expires_at"] = now.Add(VerificationLockout)
+		return updates, ErrTooManyAttempts
+	}
+	return updates, ErrInvalidVerificationCode
+}
+
+// VerificationMaxAttempts - лимит неверных вводов (VERIFICATION_MAX_ATTEMPTS, по умолчанию 5)
+func VerificationMaxAttempts() int {
+	if n, err := strconv.Atoi(os.Getenv("VERIFICATION_MAX_ATTEMPTS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultVerificationMaxAttempts
+}
+
+// hashVerificationCode - HMAC-SHA256 с секретом сервера; email входит в сообщение,
+// чтобы одинаковые коды разных пользователей давали разные хеши.
+func hashVerificationCode(email, code string) string {
+	secret := os.Getenv("VERIFICATION_SECRET")
+	if secret == "" {
+		secret = os.Getenv("SECRET")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.ToLower(email)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
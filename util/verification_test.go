@@ -0,0 +1,82 @@
+package util
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/merinovvvv/momentic-backend/models"
+)
+
+func TestHashVerificationCode(t *testing.T) {
+	t.Setenv("VERIFICATION_SECRET", "verification-secret")
+
+	hash := hashVerificationCode("User@Example.com", "12345")
+	if len(hash) != 64 || strings.Contains(hash, "12345") {
+		t.Fatalf("hashVerificationCode() = %q, want 64 hex chars without the code", hash)
+	}
+	if got := hashVerificationCode("user@example.com", "12345"); got != hash {
+		t.Error("hash depends on email case")
+	}
+	if hashVerificationCode("other@example.com", "12345") == hash {
+		t.Error("same code of different users gives the same hash")
+	}
+	if hashVerificationCode("user@example.com", "12346") == hash {
+		t.Error("different codes give the same hash")
+	}
+	t.Setenv("VERIFICATION_SECRET", "rotated")
+	if hashVerificationCode("user@example.com", "12345") == hash {
+		t.Error("hash does not depend on the secret")
+	}
+}
+
+// Счетчик попыток и блокировка переживают повторную отправку кода
+func TestVerificationAttemptsSurviveResend(t *testing.T) {
+	t.Setenv("VERIFICATION_MAX_ATTEMPTS", "3")
+	now := time.Now()
+	v := models.EmailVerification{Email: "user@example.com", ExpiresAt: now.Add(VerificationCodeTTL)}
+
+	fail := func(want error) {
+		t.Helper()
+		updates, err := failedAttempt(v, now)
+		if !errors.Is(err, want) {
+			t.Fatalf("failedAttempt() error = %v, want %v", err, want)
+		}
+		v.Attempts = updates["attempts"].(int)
+		if expiresAt, ok := updates["expires_at"].(time.Time); ok {
+			v.ExpiresAt = expiresAt
+		}
+	}
+	resend := func(want error) int {
+		t.Helper()
+		attempts, err := carriedAttempts(&v, now)
+		if !errors.Is(err, want) {
+			t.Fatalf("carriedAttempts() error = %v, want %v", err, want)
+		}
+		if err == nil {
+			v.Attempts, v.ExpiresAt = attempts, now.Add(VerificationCodeTTL)
+		}
+		return attempts
+	}
+
+	if got, _ := carriedAttempts(nil, now); got != 0 {
+		t.Errorf("carriedAttempts(nil) = %d, want 0", got)
+	}
+	fail(ErrInvalidVerificationCode)
+	fail(ErrInvalidVerificationCode)
+	if got := resend(nil); got != 2 {
+		t.Fatalf("attempts after resend = %d, want 2", got)
+	}
+	// Последняя попытка блокирует email, новый код не выдается
+	fail(ErrTooManyAttempts)
+	if want := now.Add(VerificationLockout); !v.ExpiresAt.Equal(want) {
+		t.Errorf("lockout until %v, want %v", v.ExpiresAt, want)
+	}
+	resend(ErrTooManyAttempts)
+
+	// После блокировки счет начинается заново
+	if got, err := carriedAttempts(&v, now.Add(VerificationLockout+time.Second)); err != nil || got != 0 {
+		t.Errorf("carriedAttempts() after lockout = %d, %v; want 0, nil", got, err)
+	}
+}
@@ -1,14 +1,17 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/merinovvvv/momentic-backend/middleware"
 )
 
 type ChatMessage struct {
@@ -19,16 +22,29 @@ type ChatMessage struct {
 }
 
 type chatClient struct {
-	conn *websocket.Conn
-	send chan []byte
+	userID int64
+	conn   *websocket.Conn
+	send   chan []byte
 }
 
+// chatBroadcast - сообщение и пользователи, которым его нельзя доставлять.
+// onlyTo != 0 - доставить только этому пользователю.
+type chatBroadcast struct {
+	payload []byte
+	hidden  map[int64]bool
+	onlyTo  int64
+}
+
+// BlockLookup возвращает всех, кто связан с пользователем блокировкой
+type BlockLookup func(ctx context.Context, userID int64) ([]int64, error)
+
 type ChatHub struct {
-	clients    map[*chatClient]bool
-	broadcast  chan []byte
-	register   chan *chatClient
-	unregister chan *chatClient
-	mu         sync.Mutex
+	clients     map[*chatClient]bool
+	broadcast   chan chatBroadcast
+	register    chan *chatClient
+	unregister  chan *chatClient
+	mu          sync.Mutex
+	blockLookup BlockLookup
 }
 
 var chatHubInstance *ChatHub
@@ -38,7 +54,7 @@ func GetChatHub() *ChatHub {
 	chatOnce.Do(func() {
 		chatHubInstance = &ChatHub{
 			clients:    make(map[*chatClient]bool),
-			broadcast:  make(chan []byte),
+			broadcast:  make(chan chatBroadcast),
 			register:   make(chan *chatClient),
 			unregister: make(chan *chatClient),
 		}
@@ -64,8 +80,11 @@ func (hub *ChatHub) run() {
 		case msg := <-hub.broadcast:
 			hub.mu.Lock()
 			for client := range hub.clients {
+				if msg.hidden[client.userID] || msg.onlyTo != 0 && client.userID != msg.onlyTo {
+					continue
+				}
 				select {
-				case client.send <- msg:
+				case client.send <- msg.payload:
 				default:
 					close(client.send)
 					delete(hub.clients, client)
@@ -76,17 +95,56 @@ func (hub *ChatHub) run() {
 	}
 }
 
+// SetBlockLookup включает фильтрацию: сообщения не доходят до тех,
+// кто заблокировал отправителя или заблокирован им
+func (hub *ChatHub) SetBlockLookup(lookup BlockLookup) {
+	hub.mu.Lock()
+	hub.blockLookup = lookup
+	hub.mu.Unlock()
+}
+
+// broadcastFrom вычисляет получателей до отправки в hub, чтобы не ходить в БД
+// из цикла run. Если блокировки загрузить не удалось, сообщение получает
+// только отправитель: лучше не доставить его, чем доставить заблокировавшим.
+func (hub *ChatHub) broadcastFrom(ctx context.Context, senderID int64, payload []byte) chatBroadcast {
+	msg := chatBroadcast{payload: payload}
+	hub.mu.Lock()
+	lookup := hub.blockLookup
+	hub.mu.Unlock()
+	if lookup == nil {
+		return msg
+	}
+	ids, err := lookup(ctx, senderID)
+	if err != nil {
+		log.Printf("ERROR: Failed to load blocks for chat sender %d, message not delivered to others: %v", senderID, err)
+		msg.onlyTo = senderID
+		return msg
+	}
+	msg.hidden = make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		msg.hidden[id] = true
+	}
+	return msg
+}
+
 var chatUpgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
 func ServeChatWs(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+	author := strings.TrimSpace(user.Name + " " + user.Surname)
+
 	conn, err := chatUpgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println("Upgrade error:", err)
 		return
 	}
-	client := &chatClient{conn: conn, send: make(chan []byte, 256)}
+	client := &chatClient{userID: int64(user.ID), conn: conn, send: make(chan []byte, 256)}
 	hub := GetChatHub()
 	hub.register <- client
 
@@ -109,11 +167,13 @@ func ServeChatWs(c *gin.Context) {
 			log.Println("Invalid chat message", err)
 			continue
 		}
+		// Автор всегда берется из токена, а не из тела сообщения
+		chatMsg.Author = author
 		if chatMsg.CreatedAt.IsZero() {
 			chatMsg.CreatedAt = time.Now().UTC()
 		}
 		msgOut, _ := json.Marshal(chatMsg)
-		hub.broadcast <- msgOut
+		hub.broadcast <- hub.broadcastFrom(c.Request.Context(), client.userID, msgOut)
 	}
 
 	hub.unregister <- client
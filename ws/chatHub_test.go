@@ -0,0 +1,31 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestChatHub_BroadcastFromFailsClosed(t *testing.T) {
+	ctx := context.Background()
+	hub := &ChatHub{}
+
+	if msg := hub.broadcastFrom(ctx, 1, nil); msg.onlyTo != 0 || msg.hidden != nil {
+		t.Errorf("broadcastFrom() without lookup = %+v, want delivery to everyone", msg)
+	}
+
+	hub.SetBlockLookup(func(ctx context.Context, userID int64) ([]int64, error) {
+		return []int64{2, 3}, nil
+	})
+	msg := hub.broadcastFrom(ctx, 1, nil)
+	if msg.onlyTo != 0 || !msg.hidden[2] || !msg.hidden[3] || msg.hidden[4] {
+		t.Errorf("broadcastFrom() = %+v, want users 2 and 3 hidden", msg)
+	}
+
+	hub.SetBlockLookup(func(ctx context.Context, userID int64) ([]int64, error) {
+		return nil, errors.New("db is down")
+	})
+	if msg := hub.broadcastFrom(ctx, 1, nil); msg.onlyTo != 1 {
+		t.Errorf("broadcastFrom() on lookup error = %+v, want delivery to the sender only", msg)
+	}
+}
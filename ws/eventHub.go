@@ -0,0 +1,144 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/merinovvvv/momentic-backend/middleware"
+	"github.com/merinovvvv/momentic-backend/push"
+)
+
+// EventHub доставляет события конкретным пользователям (например, "пора
+// публиковать момент"). У пользователя может быть несколько соединений.
+// Реализует push.Notifier.
+type EventHub struct {
+	mu      sync.RWMutex
+	clients map[int64]map[*eventClient]bool
+}
+
+type eventClient struct {
+	userID int64
+	conn   *websocket.Conn
+	send   chan []byte
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{clients: make(map[int64]map[*eventClient]bool)}
+}
+
+var eventUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// ServeWs - GET /ws/events
+func (h *EventHub) ServeWs(c *gin.Context) {
+	user, ok := middleware.GetUser(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization failed"})
+		return
+	}
+
+	conn, err := eventUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Println("Upgrade error:", err)
+		return
+	}
+	client := &eventClient{userID: int64(user.ID), conn: conn, send: make(chan []byte, 16)}
+	h.register(client)
+
+	go func() {
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case msg, ok := <-client.send:
+				conn.SetWriteDeadline(time.Now().Add(writeWait))
+				if !ok {
+					conn.WriteMessage(websocket.CloseMessage, []byte{})
+					return
+				}
+				if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+					return
+				}
+			case <-ticker.C:
+				conn.SetWriteDeadline(time.Now().Add(writeWait))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// Клиент ничего не присылает, чтение нужно для pong и закрытия соединения
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+	h.unregister(client)
+	conn.Close()
+}
+
+// Notify отправляет событие всем соединениям пользователей userIDs.
+// Пользователи без соединения его не получат - для них есть push.
+func (h *EventHub) Notify(ctx context.Context, userIDs []int64, n push.Notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delivered := 0
+	for _, userID := range userIDs {
+		for client := range h.clients[userID] {
+			select {
+			case client.send <- payload:
+				delivered++
+			default:
+				// медленный клиент отключается, как в остальных хабах
+				h.removeLocked(client)
+			}
+		}
+	}
+	log.Printf("INFO: Event %q delivered to %d connections", n.Type, delivered)
+	return nil
+}
+
+func (h *EventHub) register(client *eventClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[client.userID] == nil {
+		h.clients[client.userID] = make(map[*eventClient]bool)
+	}
+	h.clients[client.userID][client] = true
+}
+
+func (h *EventHub) unregister(client *eventClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(client)
+}
+
+func (h *EventHub) removeLocked(client *eventClient) {
+	clients, ok := h.clients[client.userID]
+	if !ok || !clients[client] {
+		return
+	}
+	delete(clients, client)
+	close(client.send)
+	if len(clients) == 0 {
+		delete(h.clients, client.userID)
+	}
+}